@@ -5,11 +5,20 @@ import (
 	"io"
 	"log"
 	"os"
+	"regexp"
+	"slices"
 	"sort"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/w31r4/dotm/config"
+	"github.com/w31r4/dotm/pkg/crypt"
+	"github.com/w31r4/dotm/pkg/dotrepo"
+	"github.com/w31r4/dotm/pkg/executor"
+	"github.com/w31r4/dotm/pkg/pkgmgr"
+	"github.com/w31r4/dotm/pkg/semver"
+	"github.com/w31r4/dotm/pkg/toolchain"
+	"github.com/w31r4/dotm/pkg/xcmd"
 	"gopkg.in/yaml.v3"
 )
 
@@ -91,7 +100,11 @@ This checks for:
 - YAML syntax errors
 - Missing required fields
 - Invalid module references in dependencies
-- Circular dependencies`,
+- Circular dependencies
+
+With --strict, install commands that pipe a remote script straight into a
+shell (curl ... | sh) are reported as errors; use the script: install type
+instead.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		cfg, err := config.LoadConfig(configPath)
 		if err != nil {
@@ -99,6 +112,9 @@ This checks for:
 		}
 
 		errors := validateConfig(cfg)
+		if strict, _ := cmd.Flags().GetBool("strict"); strict {
+			errors = append(errors, strictValidateConfig(cfg)...)
+		}
 		if len(errors) > 0 {
 			fmt.Println("❌ Configuration validation failed with the following errors:")
 			for i, err := range errors {
@@ -169,22 +185,144 @@ func showModuleDetails(name string, module config.Module) {
 		fmt.Printf("\nCheck Command: %s\n", module.Check)
 	}
 
+	if module.Version != "" {
+		fmt.Printf("Required Version: %s\n", module.Version)
+	}
+	if module.VersionCmd != "" {
+		fmt.Printf("Version Command: %s\n", module.VersionCmd)
+	}
+	if module.VersionRegex != "" {
+		fmt.Printf("Version Regex: %s\n", module.VersionRegex)
+	}
+
+	if module.Shell != "" {
+		fmt.Printf("\nShell: %s\n", module.Shell)
+	}
+
+	if module.Workdir != "" {
+		fmt.Printf("Working Directory: %s\n", module.Workdir)
+	}
+
+	if len(module.Env) > 0 {
+		fmt.Printf("\nEnvironment:\n")
+		keys := make([]string, 0, len(module.Env))
+		for k := range module.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Printf("  %s=%s\n", k, module.Env[k])
+		}
+	}
+
 	if len(module.Install) > 0 {
 		fmt.Printf("\nInstall Commands:\n")
-		for os, cmds := range module.Install {
-			fmt.Printf("  %s:\n", os)
-			for _, cmd := range cmds {
-				fmt.Printf("    - %s\n", cmd)
+		printModuleCommands(module, module.Install)
+	}
+
+	if len(module.Update) > 0 {
+		fmt.Printf("\nUpdate Commands:\n")
+		printModuleCommands(module, module.Update)
+	}
+
+	if len(module.Packages) > 0 {
+		fmt.Printf("\nPackages:\n")
+		for _, manager := range pkgmgr.Names {
+			if pkgs, ok := module.Packages[manager]; ok {
+				fmt.Printf("  %s: %s\n", manager, strings.Join(pkgs, ", "))
+			}
+		}
+	}
+
+	if module.XCmd != nil {
+		fmt.Printf("\nx-cmd Package: %s\n", xcmd.Spec(module.XCmd.Package, module.XCmd.Version))
+	}
+
+	tools := module.Tools()
+	for _, kind := range toolchain.Names {
+		if t, ok := tools[kind]; ok {
+			version := t.Version
+			if version == "" {
+				version = "latest"
 			}
+			fmt.Printf("\nTool (%s): %s %s\n", kind, t.Package, version)
 		}
 	}
 
+	if g := module.Git; g != nil {
+		fmt.Printf("\nGit Checkout:\n")
+		fmt.Printf("  Repo: %s\n", g.Repo)
+		fmt.Printf("  Dest: %s\n", g.Dest)
+		if g.Ref != "" {
+			fmt.Printf("  Ref: %s\n", g.Ref)
+		}
+		if g.Depth > 0 {
+			fmt.Printf("  Depth: %d\n", g.Depth)
+		}
+	}
+
+	if sc := module.Script; sc != nil {
+		fmt.Printf("\nInstaller Script:\n")
+		fmt.Printf("  URL: %s\n", sc.URL)
+		if sc.SHA256 != "" {
+			fmt.Printf("  SHA256: %s\n", sc.SHA256)
+		} else {
+			fmt.Printf("  SHA256: (not pinned)\n")
+		}
+		if len(sc.Args) > 0 {
+			fmt.Printf("  Args: %s\n", strings.Join(sc.Args, " "))
+		}
+	}
+
+	if a := module.Archive; a != nil {
+		fmt.Printf("\nArchive:\n")
+		fmt.Printf("  URL: %s\n", a.URL)
+		fmt.Printf("  Version: %s\n", a.Version)
+		if len(a.Files) > 0 {
+			fmt.Printf("  Files: %s\n", strings.Join(a.Files, ", "))
+		}
+		if a.BinDir != "" {
+			fmt.Printf("  Bin Dir: %s\n", a.BinDir)
+		}
+		platforms := make([]string, 0, len(a.SHA256))
+		for platform := range a.SHA256 {
+			platforms = append(platforms, platform)
+		}
+		sort.Strings(platforms)
+		fmt.Printf("  Verified Platforms: %s\n", strings.Join(platforms, ", "))
+	}
+
 	if len(module.Apply) > 0 {
 		fmt.Printf("\nPost-Install Configuration:\n")
 		for _, step := range module.Apply {
 			fmt.Printf("  Strategy: %s\n", step.Strategy)
 			fmt.Printf("    Target: %s\n", step.Target)
-			fmt.Printf("    Line: %s\n", step.Line)
+			if step.Source != "" {
+				fmt.Printf("    Source: %s\n", step.Source)
+			} else {
+				fmt.Printf("    Line: %s\n", step.Line)
+			}
+		}
+	}
+}
+
+// printModuleCommands prints per-OS commands with their run options.
+func printModuleCommands(module config.Module, commands map[string][]config.Command) {
+	for os, cmds := range commands {
+		fmt.Printf("  %s:\n", os)
+		for _, cmd := range cmds {
+			var flags []string
+			if isPrivileged(module, cmd) {
+				flags = append(flags, "privileged")
+			}
+			if cmd.Interactive {
+				flags = append(flags, "interactive")
+			}
+			if len(flags) > 0 {
+				fmt.Printf("    - %s (%s)\n", cmd, strings.Join(flags, ", "))
+			} else {
+				fmt.Printf("    - %s\n", cmd)
+			}
 		}
 	}
 }
@@ -231,8 +369,91 @@ func validateConfig(cfg *config.Config) []string {
 		}
 
 		// Check for missing install commands
-		if len(module.Install) == 0 {
-			errors = append(errors, fmt.Sprintf("Module '%s' has no install commands defined", name))
+		if len(module.Install) == 0 && len(module.Packages) == 0 && len(moduleInstallers(name, module)) == 0 {
+			errors = append(errors, fmt.Sprintf("Module '%s' has no install commands, packages or install type defined", name))
+		}
+
+		// Validate install types
+		if module.XCmd != nil && module.XCmd.Package == "" {
+			errors = append(errors, fmt.Sprintf("Module '%s' xcmd install is missing a package", name))
+		}
+		for kind, t := range module.Tools() {
+			if t.Package == "" {
+				errors = append(errors, fmt.Sprintf("Module '%s' %s install is missing a package", name, kind))
+			}
+		}
+		if g := module.Git; g != nil {
+			if g.Repo == "" {
+				errors = append(errors, fmt.Sprintf("Module '%s' git install is missing a repo", name))
+			}
+			if g.Dest == "" {
+				errors = append(errors, fmt.Sprintf("Module '%s' git install is missing a dest", name))
+			}
+			if g.Depth < 0 {
+				errors = append(errors, fmt.Sprintf("Module '%s' git install has a negative depth", name))
+			}
+		}
+		if sc := module.Script; sc != nil {
+			if sc.URL == "" {
+				errors = append(errors, fmt.Sprintf("Module '%s' script install is missing a url", name))
+			}
+			if sc.Shell != "" && !slices.Contains(executor.SupportedShells, sc.Shell) {
+				errors = append(errors, fmt.Sprintf("Module '%s' script uses unsupported shell '%s' (supported: %s)", name, sc.Shell, strings.Join(executor.SupportedShells, ", ")))
+			}
+		}
+		if a := module.Archive; a != nil {
+			if a.URL == "" {
+				errors = append(errors, fmt.Sprintf("Module '%s' archive install is missing a url", name))
+			}
+			if a.Version == "" {
+				errors = append(errors, fmt.Sprintf("Module '%s' archive install is missing a version", name))
+			}
+			if len(a.SHA256) == 0 {
+				errors = append(errors, fmt.Sprintf("Module '%s' archive install has no sha256 checksums", name))
+			}
+		}
+
+		// Validate package managers
+		for manager := range module.Packages {
+			if !slices.Contains(pkgmgr.Names, manager) {
+				errors = append(errors, fmt.Sprintf("Module '%s' uses unknown package manager '%s' (supported: %s)", name, manager, strings.Join(pkgmgr.Names, ", ")))
+			}
+		}
+
+		// Validate install commands
+		for os, cmds := range module.Install {
+			for i, cmd := range cmds {
+				if strings.TrimSpace(cmd.Run) == "" {
+					errors = append(errors, fmt.Sprintf("Module '%s' install command %d for '%s' is empty", name, i, os))
+				}
+			}
+		}
+		for os, cmds := range module.Update {
+			for i, cmd := range cmds {
+				if strings.TrimSpace(cmd.Run) == "" {
+					errors = append(errors, fmt.Sprintf("Module '%s' update command %d for '%s' is empty", name, i, os))
+				}
+			}
+		}
+
+		// Validate version requirements
+		if module.Version != "" {
+			if _, err := semver.ParseConstraint(module.Version); err != nil {
+				errors = append(errors, fmt.Sprintf("Module '%s' has an invalid version constraint: %v", name, err))
+			}
+			if module.VersionCmd == "" && module.XCmd == nil && module.Archive == nil && module.Git == nil && len(module.Tools()) == 0 {
+				errors = append(errors, fmt.Sprintf("Module '%s' requires a version but has no version_cmd to read it", name))
+			}
+		}
+		if module.VersionRegex != "" {
+			if _, err := regexp.Compile(module.VersionRegex); err != nil {
+				errors = append(errors, fmt.Sprintf("Module '%s' has an invalid version_regex: %v", name, err))
+			}
+		}
+
+		// Validate shell selection
+		if module.Shell != "" && !slices.Contains(executor.SupportedShells, module.Shell) {
+			errors = append(errors, fmt.Sprintf("Module '%s' uses unsupported shell '%s' (supported: %s)", name, module.Shell, strings.Join(executor.SupportedShells, ", ")))
 		}
 
 		// Validate dependencies exist
@@ -250,6 +471,42 @@ func validateConfig(cfg *config.Config) []string {
 			if step.Target == "" {
 				errors = append(errors, fmt.Sprintf("Module '%s' apply step %d is missing target", name, i))
 			}
+			if step.Strategy == "decrypt" && step.Source == "" {
+				errors = append(errors, fmt.Sprintf("Module '%s' apply step %d is missing source", name, i))
+			}
+		}
+	}
+
+	if cfg.Repo != nil && cfg.Repo.Backend != "" && !slices.Contains(dotrepo.Backends, cfg.Repo.Backend) {
+		errors = append(errors, fmt.Sprintf("repo.backend '%s' is not supported (supported: %s)", cfg.Repo.Backend, strings.Join(dotrepo.Backends, ", ")))
+	}
+	if cfg.Repo != nil && cfg.Repo.Encryption != nil && !slices.Contains(crypt.Tools, cfg.Repo.Encryption.Tool) {
+		errors = append(errors, fmt.Sprintf("repo.encryption uses unsupported tool '%s' (supported: %s)", cfg.Repo.Encryption.Tool, strings.Join(crypt.Tools, ", ")))
+	}
+	seenRepos := make(map[string]bool)
+	for i, r := range cfg.Repos {
+		switch {
+		case r.Name == "":
+			errors = append(errors, fmt.Sprintf("repos entry %d is missing a name", i))
+		case strings.ContainsAny(r.Name, `/\`) || strings.HasPrefix(r.Name, "."):
+			errors = append(errors, fmt.Sprintf("repos entry '%s' has an invalid name", r.Name))
+		case seenRepos[r.Name]:
+			errors = append(errors, fmt.Sprintf("repos entry '%s' is defined more than once", r.Name))
+		}
+		seenRepos[r.Name] = true
+		if r.Backend != "" && !slices.Contains(dotrepo.Backends, r.Backend) {
+			errors = append(errors, fmt.Sprintf("repos entry '%s' uses unsupported backend '%s' (supported: %s)", r.Name, r.Backend, strings.Join(dotrepo.Backends, ", ")))
+		}
+		if r.Encryption != nil && !slices.Contains(crypt.Tools, r.Encryption.Tool) {
+			errors = append(errors, fmt.Sprintf("repos entry '%s' uses unsupported encryption tool '%s' (supported: %s)", r.Name, r.Encryption.Tool, strings.Join(crypt.Tools, ", ")))
+		}
+		if r.Dir != "" && cfg.Repo != nil && r.Dir == cfg.Repo.Dir {
+			errors = append(errors, fmt.Sprintf("repos entry '%s' uses the same dir as repo: (%s)", r.Name, r.Dir))
+		}
+		for _, other := range cfg.Repos[:i] {
+			if r.Dir != "" && r.Dir == other.Dir {
+				errors = append(errors, fmt.Sprintf("repos entries '%s' and '%s' use the same dir (%s)", other.Name, r.Name, r.Dir))
+			}
 		}
 	}
 
@@ -263,6 +520,52 @@ func validateConfig(cfg *config.Config) []string {
 	return errors
 }
 
+// pipeToShellPatterns match install commands that run a remote script
+// without saving or verifying it first.
+var pipeToShellPatterns = []*regexp.Regexp{
+	// curl ... | sh, wget -O- ... | sudo bash
+	regexp.MustCompile(`\b(curl|wget)\b[^|;&]*\|\s*(sudo\s+(-\S+\s+)*)?(ba|z|da|k)?sh\b`),
+	// sh -c "$(curl ...)"
+	regexp.MustCompile(`\b(ba|z|da|k)?sh\s+-c\s+["']?\$\(\s*(curl|wget)\b`),
+}
+
+// strictValidateConfig reports install commands that pipe remote scripts
+// into a shell.
+func strictValidateConfig(cfg *config.Config) []string {
+	var errors []string
+	names := make([]string, 0, len(cfg.Modules))
+	for name := range cfg.Modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		module := cfg.Modules[name]
+		oses := make([]string, 0, len(module.Install))
+		for os := range module.Install {
+			oses = append(oses, os)
+		}
+		sort.Strings(oses)
+		for _, os := range oses {
+			for _, cmd := range module.Install[os] {
+				if isPipeToShell(cmd.Run) {
+					errors = append(errors, fmt.Sprintf("Module '%s' pipes a remote script into a shell on '%s': %s (use the script: install type with a pinned sha256)", name, os, cmd.Run))
+				}
+			}
+		}
+	}
+	return errors
+}
+
+func isPipeToShell(command string) bool {
+	for _, re := range pipeToShellPatterns {
+		if re.MatchString(command) {
+			return true
+		}
+	}
+	return false
+}
+
 func hasCyclicDependency(moduleName string, modules map[string]config.Module, visiting, visited map[string]bool) bool {
 	if visiting[moduleName] {
 		return true
@@ -275,7 +578,7 @@ func hasCyclicDependency(moduleName string, modules map[string]config.Module, vi
 
 	module, ok := modules[moduleName]
 	if ok {
-		for _, dep := range module.Dependencies {
+		for _, dep := range moduleDependencies(modules, moduleName, module) {
 			if hasCyclicDependency(dep, modules, visiting, visited) {
 				return true
 			}
@@ -292,14 +595,14 @@ func generateModuleTemplate(moduleName string) {
   %s:
     description: "Description of %s"
     dependencies: []
-    check: "command -v %s"
-    install:
-      debian: ["sudo apt-get install -y %s"]
-      macos: ["brew install %s"]
-      default: []
+    packages:
+      apt: [%s]
+      brew: [%s]
+      pacman: [%s]
+      dnf: [%s]
     apply:
       - { strategy: "inject", target: "~/.zshrc", line: "# Configure %s here" }
-`, moduleName, moduleName, moduleName, moduleName, moduleName, moduleName)
+`, moduleName, moduleName, moduleName, moduleName, moduleName, moduleName, moduleName)
 
 	fmt.Println("Module Template:")
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
@@ -311,16 +614,20 @@ func generateModuleTemplate(moduleName string) {
 }
 
 func generateConfigTemplate() {
-	template := `modules:
+	template := `# The dotfiles repository used by 'dotm repo'
+repo:
+  url: git@github.com:your-username/your-dotfiles.git
+
+modules:
   example-tool:
     description: "An example tool to demonstrate the configuration structure"
     dependencies: []
-    check: "command -v example-tool"
-    install:
-      debian: ["sudo apt-get update", "sudo apt-get install -y example-tool"]
-      macos: ["brew install example-tool"]
-      arch: ["sudo pacman -S --noconfirm example-tool"]
-      default: []
+    # Installed with the system package manager (apt, brew, pacman or dnf)
+    packages:
+      apt: [example-tool]
+      brew: [example-tool]
+      pacman: [example-tool]
+      dnf: [example-tool]
     apply:
       - { strategy: "inject", target: "~/.zshrc", line: "# Configure example-tool" }
 `
@@ -360,4 +667,5 @@ func init() {
 	configCmd.AddCommand(validateCmd)
 	configCmd.AddCommand(templateCmd)
 	configCmd.AddCommand(copyCmd)
+	validateCmd.Flags().Bool("strict", false, "Also flag unverified `curl ... | sh` install commands")
 }
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/w31r4/dotm/config"
+)
+
+func TestIsPipeToShell(t *testing.T) {
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{"curl -fsSL https://pyenv.run | bash", true},
+		{"curl -LsSf https://astral.sh/uv/install.sh | sh", true},
+		{"wget -qO- https://example.com/install.sh | sudo -E bash", true},
+		{`sh -c "$(curl -fsSL https://get.x-cmd.com)"`, true},
+		{`bash -c "$(wget -qO- https://example.com/i.sh)"`, true},
+		{"curl -fsSLo /tmp/go.tgz https://go.dev/dl/go.tgz", false},
+		{"curl -s https://example.com | grep shasum", false},
+		{"git clone https://github.com/zsh-users/zsh-autosuggestions", false},
+	}
+	for _, tt := range tests {
+		if got := isPipeToShell(tt.command); got != tt.want {
+			t.Errorf("isPipeToShell(%q) = %v, want %v", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestStrictValidateConfig(t *testing.T) {
+	cfg := &config.Config{Modules: map[string]config.Module{
+		"uv": {Install: map[string][]config.Command{
+			"default": config.Commands("curl -LsSf https://astral.sh/uv/install.sh | sh"),
+		}},
+		"uv-script": {Script: &config.ScriptSpec{URL: "https://astral.sh/uv/install.sh"}},
+	}}
+	errs := strictValidateConfig(cfg)
+	if len(errs) != 1 {
+		t.Fatalf("errors = %v, want exactly one for the inline pipe", errs)
+	}
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/w31r4/dotm/config"
+	"github.com/w31r4/dotm/pkg/executor"
+	"github.com/w31r4/dotm/pkg/pkgimport"
+)
+
+var importFrom string
+
+var importCmd = &cobra.Command{
+	Use:   "import <file|->",
+	Short: "Create modules from a Brewfile or package list",
+	Long: `Create a module for every package in a Brewfile or an exported package
+list and append them to config.yaml. Use "-" to read from stdin.
+
+Supported formats (--from):
+  brewfile  a Brewfile; brew and cask entries are imported
+  apt       output of 'apt-mark showmanual'
+  pacman    output of 'pacman -Qqe'
+  pipx      output of 'pipx list --short' (imported as uv_tool modules)
+
+Packages that already have a module, or that another module already
+installs, are skipped. Existing comments and formatting in config.yaml
+are kept.`,
+	Example: `  dotm module import --from brewfile ~/Brewfile
+  apt-mark showmanual | dotm module import --from apt -`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !slices.Contains(pkgimport.Formats, importFrom) {
+			log.Fatalf("--from must be one of: %s", strings.Join(pkgimport.Formats, ", "))
+		}
+
+		var in io.Reader = os.Stdin
+		if args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				log.Fatalf("Error opening %s: %v", args[0], err)
+			}
+			defer f.Close()
+			in = f
+		}
+		pkgs, err := pkgimport.Parse(importFrom, in)
+		if err != nil {
+			log.Fatalf("Error reading %s: %v", args[0], err)
+		}
+
+		cfg, err := config.LoadConfig(configPath)
+		if os.IsNotExist(err) {
+			cfg = &config.Config{Modules: make(map[string]config.Module)}
+		} else if err != nil {
+			log.Fatalf("Error loading config from %s: %v", configPath, err)
+		}
+
+		names, modules, skipped := importModules(cfg, pkgs)
+		if len(skipped) > 0 {
+			fmt.Printf("Skipping %d already defined: %s\n", len(skipped), strings.Join(skipped, ", "))
+		}
+		if len(names) == 0 {
+			fmt.Println("Nothing to import.")
+			return
+		}
+
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if dryRun {
+			fmt.Printf("[DRY RUN] Would add %d modules: %s\n", len(names), strings.Join(names, ", "))
+			return
+		}
+		comment := fmt.Sprintf("Imported from %s by `dotm module import`", importSource(importFrom))
+		if err := config.AppendModules(configPath, names, modules, comment); err != nil {
+			log.Fatalf("Error writing config file: %v", err)
+		}
+		fmt.Printf("Successfully added %d modules: %s\n", len(names), strings.Join(names, ", "))
+	},
+}
+
+// importModules turns imported packages into modules, leaving out packages
+// the config already covers. It returns the new module names in input
+// order and the names of the packages it skipped.
+func importModules(cfg *config.Config, pkgs []pkgimport.Package) (names []string, modules map[string]config.Module, skipped []string) {
+	modules = make(map[string]config.Module)
+	for _, p := range pkgs {
+		name := p.ModuleName()
+		_, defined := cfg.Modules[name]
+		_, imported := modules[name]
+		if defined || imported || packageDefined(cfg, p) {
+			skipped = append(skipped, p.Name)
+			continue
+		}
+		modules[name] = importedModule(p)
+		names = append(names, name)
+	}
+	return names, modules, skipped
+}
+
+// importedModule is the module generated for an imported package. Most
+// use a built-in install type, which also serves as their check.
+func importedModule(p pkgimport.Package) config.Module {
+	m := config.Module{Description: fmt.Sprintf("%s (imported from %s)", p.ModuleName(), importSource(p.Source))}
+	switch p.Source {
+	case "cask":
+		m.Check = fmt.Sprintf("brew list --cask %s >/dev/null 2>&1", executor.ShellQuote(p.Name))
+		m.Install = map[string][]config.Command{"macos": config.Commands("brew install --cask " + executor.ShellQuote(p.Name))}
+	case "pipx":
+		m.UvTool = &config.ToolSpec{Package: p.Name}
+	default:
+		m.Packages = map[string][]string{p.Source: {p.Name}}
+	}
+	return m
+}
+
+// packageDefined reports whether an existing module already installs p.
+func packageDefined(cfg *config.Config, p pkgimport.Package) bool {
+	for _, module := range cfg.Modules {
+		switch p.Source {
+		case "cask":
+			for _, cmds := range module.Install {
+				for _, c := range cmds {
+					if strings.Contains(c.Run, "--cask "+p.Name) || strings.Contains(c.Run, "--cask "+executor.ShellQuote(p.Name)) {
+						return true
+					}
+				}
+			}
+		case "pipx":
+			if module.UvTool != nil && module.UvTool.Package == p.Name {
+				return true
+			}
+		default:
+			if slices.Contains(module.Packages[p.Source], p.Name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// importSource names where a package came from in generated descriptions.
+func importSource(source string) string {
+	switch source {
+	case "brewfile", "brew", "cask":
+		return "Brewfile"
+	default:
+		return source
+	}
+}
+
+func init() {
+	moduleCmd.AddCommand(importCmd)
+	importCmd.Flags().StringVar(&importFrom, "from", "", "Input format: "+strings.Join(pkgimport.Formats, ", "))
+	importCmd.Flags().Bool("dry-run", false, "List the modules that would be added without changing config.yaml")
+	importCmd.MarkFlagRequired("from")
+}
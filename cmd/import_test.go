@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/w31r4/dotm/config"
+	"github.com/w31r4/dotm/pkg/pkgimport"
+)
+
+func TestImportModules(t *testing.T) {
+	cfg := &config.Config{Modules: map[string]config.Module{
+		"git":   {Packages: map[string][]string{"brew": {"git"}}},
+		"shell": {Packages: map[string][]string{"brew": {"zsh"}}},
+		"ruff":  {UvTool: &config.ToolSpec{Package: "ruff"}},
+	}}
+	pkgs := []pkgimport.Package{
+		{Name: "git", Source: "brew"},     // module exists
+		{Name: "zsh", Source: "brew"},     // installed by another module
+		{Name: "jq", Source: "brew"},      // new
+		{Name: "jq", Source: "cask"},      // same module name as above
+		{Name: "firefox", Source: "cask"}, // new
+		{Name: "black", Source: "pipx"},   // new
+		{Name: "ruff", Source: "pipx"},    // module exists
+	}
+	names, modules, skipped := importModules(cfg, pkgs)
+	if want := []string{"jq", "firefox", "black"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("names = %v, want %v", names, want)
+	}
+	if want := []string{"git", "zsh", "jq", "ruff"}; !reflect.DeepEqual(skipped, want) {
+		t.Fatalf("skipped = %v, want %v", skipped, want)
+	}
+	if got := modules["jq"].Packages["brew"]; !reflect.DeepEqual(got, []string{"jq"}) {
+		t.Errorf("jq packages = %v", got)
+	}
+	if modules["firefox"].Check == "" || len(modules["firefox"].Install["macos"]) != 1 {
+		t.Errorf("firefox module = %+v, want a check and a macOS install command", modules["firefox"])
+	}
+	if u := modules["black"].UvTool; u == nil || u.Package != "black" {
+		t.Errorf("black module = %+v, want uv_tool: black", modules["black"])
+	}
+}
@@ -1,17 +1,62 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 	"github.com/w31r4/dotm/config"
 	"github.com/w31r4/dotm/pkg/executor"
 	"github.com/w31r4/dotm/pkg/fileutil"
+	"github.com/w31r4/dotm/pkg/lock"
+	"github.com/w31r4/dotm/pkg/pkgmgr"
+	"github.com/w31r4/dotm/pkg/state"
 )
 
 var dryRun bool
-var installedModules = make(map[string]bool)
+var keepGoing bool
+var resume bool
+var noSudo bool
+var reviewScripts bool
+var installLocked bool
+
+// privilege runs the privileged commands of the current install run.
+var privilege = &executor.Privilege{}
+
+// installStatus describes what happened to a module during an install run.
+type installStatus string
+
+const (
+	statusInstalled installStatus = "installed"
+	statusPresent   installStatus = "already present"
+	statusSkipped   installStatus = "skipped"
+	statusFailed    installStatus = "failed"
+)
+
+// installResult records the outcome of a single module in the current run.
+type installResult struct {
+	Name   string
+	Status installStatus
+	Err    error
+}
+
+// installResults holds the outcome of every module visited in this run,
+// and installOrder keeps them in the order they were resolved.
+var installResults = make(map[string]*installResult)
+var installOrder []string
+
+// journal checkpoints the current run. It is nil during a dry run.
+var journal *state.Journal
+
+// errDependencyFailed marks a module that was skipped because one of its
+// dependencies could not be installed.
+var errDependencyFailed = errors.New("dependency failed")
 
 // installCmd represents the install command
 var installCmd = &cobra.Command{
@@ -19,85 +64,374 @@ var installCmd = &cobra.Command{
 	Short: "Install and configure one or more modules",
 	Long: `Install modules defined in the config.yaml file.
 This command will check for dependencies, install the required software,
-and apply the necessary dotfile configurations.`,
-	Args: cobra.MinimumNArgs(1),
+and apply the necessary dotfile configurations.
+
+With --keep-going, a failing module does not stop the run: it is skipped
+together with every module that depends on it, the remaining modules are
+installed, and a summary is printed at the end.
+
+Every completed check and install command is recorded in a run journal.
+If a run is interrupted, 'dotm install --resume' continues the last
+unfinished plan and skips modules the journal already marks as done,
+unless their definition in config.yaml has changed since.
+
+With --locked, git checkouts, installer scripts, x-cmd versions,
+language tool versions and apt or dnf package versions are pinned to what
+dotm.lock recorded, and the install fails if a module in the plan changed
+in config.yaml since the lock was written. Anything the lock cannot pin
+(brew and pacman packages, install commands) is listed in a warning.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if resume {
+			if len(args) > 0 {
+				return fmt.Errorf("--resume continues the previous plan and takes no module arguments")
+			}
+			return nil
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
+		journalPath, err := state.JournalPath()
+		if err != nil {
+			log.Fatalf("Error locating run journal: %v", err)
+		}
+
+		if resume {
+			journal, err = state.LoadJournal(journalPath)
+			if os.IsNotExist(err) {
+				log.Fatalf("No interrupted install to resume.")
+			} else if err != nil {
+				log.Fatalf("Error reading run journal %s: %v", journalPath, err)
+			}
+			if journal.Finished {
+				log.Fatalf("The last install run (%s) finished; nothing to resume.", journal.StartedAt.Format("2006-01-02 15:04"))
+			}
+			if !cmd.Flags().Changed("config") {
+				configPath = journal.ConfigPath
+			}
+			args = journal.Modules
+			fmt.Printf("Resuming install of: %v\n", args)
+		}
+
 		cfg, err := config.LoadConfig(configPath)
 		if err != nil {
 			log.Fatalf("Error loading config from %s: %v", configPath, err)
 		}
 
+		if installLocked {
+			lockPath := lock.Path(configPath)
+			lockFile, err := lock.Load(lockPath)
+			if os.IsNotExist(err) {
+				log.Fatalf("--locked needs %s; run `dotm lock` first", lockPath)
+			} else if err != nil {
+				log.Fatalf("Error reading %s: %v", lockPath, err)
+			}
+			if err := applyLock(cfg, resolvePlan(cfg, args), lockFile); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		if dryRun {
+			journal = nil
+		} else if journal == nil {
+			absConfigPath, err := filepath.Abs(configPath)
+			if err != nil {
+				absConfigPath = configPath
+			}
+			journal = state.NewJournal(journalPath, absConfigPath, slices.Clone(args))
+			saveJournal()
+		}
+
+		if err := acquirePrivileges(privilegedSteps(cfg, resolvePlan(cfg, args)), dryRun); err != nil {
+			log.Fatal(err)
+		}
+		defer privilege.Release()
+
+		batchInstallPackages(cfg, resolvePlan(cfg, args), dryRun)
+
 		for _, moduleName := range args {
 			if err := installModule(moduleName, cfg, dryRun); err != nil {
-				log.Fatalf("Failed to install module %s: %v", moduleName, err)
+				if !keepGoing {
+					// log.Fatalf skips deferred calls.
+					privilege.Release()
+					log.Fatalf("Failed to install module %s: %v", moduleName, err)
+				}
+				fmt.Printf("Module %s did not install: %v (continuing)\n", moduleName, err)
 			}
 		}
+
+		if journal != nil {
+			journal.Finished = !installFailed()
+			saveJournal()
+		}
+
+		if keepGoing {
+			printInstallSummary()
+			if installFailed() {
+				privilege.Release()
+				os.Exit(1)
+			}
+			return
+		}
 		fmt.Println("\nAll requested modules installed successfully!")
 	},
 }
 
 func installModule(name string, cfg *config.Config, dryRun bool) error {
-	if installedModules[name] {
-		return nil // Already installed in this run
+	if result, ok := installResults[name]; ok {
+		switch result.Status {
+		case statusFailed, statusSkipped:
+			return fmt.Errorf("module '%s' %s earlier in this run: %w", name, result.Status, errDependencyFailed)
+		default:
+			return nil // Already handled in this run
+		}
 	}
 
 	fmt.Printf("--- Installing module: %s ---\n", name)
 
 	module, ok := cfg.Modules[name]
 	if !ok {
-		return fmt.Errorf("module '%s' not found in config.yaml", name)
+		err := fmt.Errorf("module '%s' not found in config.yaml", name)
+		recordResult(name, statusFailed, err)
+		return err
 	}
 
 	// 1. Handle dependencies first
-	if len(module.Dependencies) > 0 {
+	if deps := moduleDependencies(cfg.Modules, name, module); len(deps) > 0 {
 		fmt.Println("Checking dependencies...")
-		for _, depName := range module.Dependencies {
+		for _, depName := range deps {
 			if err := installModule(depName, cfg, dryRun); err != nil {
+				recordResult(name, statusSkipped, fmt.Errorf("dependency '%s' failed", depName))
 				return fmt.Errorf("dependency '%s' for module '%s' failed to install: %w", depName, name, err)
 			}
 		}
 	}
 
+	status, err := installModuleSteps(name, module, dryRun)
+	if err != nil {
+		recordResult(name, statusFailed, err)
+		return err
+	}
+	recordResult(name, status, nil)
+	return nil
+}
+
+// installModuleSteps runs the check, install and apply phases for a module
+// whose dependencies are already satisfied.
+func installModuleSteps(name string, module config.Module, dryRun bool) (installStatus, error) {
+	entry := journalEntry(name, module)
+	if entry.CheckPassed || entry.Installed {
+		fmt.Println("Module completed in a previous run (from journal). Skipping.")
+		return statusPresent, nil
+	}
+
+	session, err := newModuleSession(name, module, dryRun)
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	manager, pkgs, err := modulePackages(module)
+	if err != nil {
+		return "", fmt.Errorf("module '%s' declares packages: %w", name, err)
+	}
+	installCmds, hasCmds := moduleInstallCommands(module)
+	installers := moduleInstallers(name, module)
+	// builtinOnly modules are installed entirely by dotm's backends, so
+	// their presence can be checked without a check command.
+	builtinOnly := !hasCmds && (len(pkgs) > 0 || len(installers) > 0)
+
+	alreadyInstalled := func() (installStatus, error) {
+		fmt.Println("Module is already installed. Skipping installation.")
+		// Even if installed, we might want to re-apply configs
+		if err := applyConfiguration(module, dryRun); err != nil {
+			return "", err
+		}
+		entry.CheckPassed = true
+		saveJournal()
+		return statusPresent, nil
+	}
+
 	// 2. Check if the module is already installed
+	var missing []string
+	if len(pkgs) > 0 && !batchedModules[name] {
+		if missing, err = pkgmgr.Missing(manager, pkgs); err != nil {
+			return "", fmt.Errorf("query %s packages: %w", manager.Name(), err)
+		}
+	}
+	present := false
 	if module.Check != "" {
 		fmt.Printf("Running check: %s\n", module.Check)
-		if err := executor.Execute(module.Check, dryRun); err == nil {
-			fmt.Println("Module is already installed. Skipping installation.")
-			installedModules[name] = true
-			// Even if installed, we might want to re-apply configs
-			return applyConfiguration(module, dryRun)
+		if err := session.Run(module.Check); err == nil {
+			present = true
+		} else {
+			fmt.Println("Module not found, proceeding with installation.")
+		}
+	} else if builtinOnly && len(missing) == 0 && !batchedModules[name] {
+		if present, err = installersPresent(installers); err != nil {
+			return "", err
+		}
+	}
+	if present {
+		installed, ok, err := versionSatisfied(session, name, module)
+		if err != nil {
+			return "", err
 		}
-		fmt.Println("Module not found, proceeding with installation.")
+		if ok {
+			return alreadyInstalled()
+		}
+		fmt.Printf("Installed version %s does not satisfy %s, proceeding with installation.\n", dashIfEmpty(installed), module.Version)
 	}
 
 	// 3. Install the software
-	os := executor.GetOS()
-	installCmds, ok := module.Install[os]
-	if !ok {
-		// If no specific command for the OS, check for a "default"
-		installCmds, ok = module.Install["default"]
-		if !ok {
-			return fmt.Errorf("no install command found for OS '%s' or default in module '%s'", os, name)
+	if !builtinOnly && !hasCmds {
+		return "", fmt.Errorf("no install command found for OS '%s' or default in module '%s'", executor.GetOS(), name)
+	}
+
+	if len(missing) > 0 {
+		fmt.Printf("Installing %s packages for %s...\n", manager.Name(), name)
+		if err := manager.Install(session, missing); err != nil {
+			return "", fmt.Errorf("installing %s packages failed: %w", manager.Name(), err)
 		}
 	}
 
-	fmt.Printf("Running install commands for %s...\n", name)
-	for _, cmd := range installCmds {
-		if err := executor.Execute(cmd, dryRun); err != nil {
-			return fmt.Errorf("installation command '%s' failed: %w", cmd, err)
+	for _, inst := range installers {
+		fmt.Printf("Installing %s with %s...\n", name, inst.kind())
+		if err := inst.install(session); err != nil {
+			return "", fmt.Errorf("%s install failed: %w", inst.kind(), err)
+		}
+	}
+
+	if hasCmds {
+		fmt.Printf("Running install commands for %s...\n", name)
+	}
+	if entry.CommandsDone > 0 {
+		// Later commands may depend on what the skipped ones exported.
+		session.RestoreEnv(entry.Env, entry.Unset)
+	}
+	for i, cmd := range installCmds {
+		if i < entry.CommandsDone {
+			fmt.Printf("Skipping command completed in a previous run: %s\n", cmd)
+			continue
+		}
+		if err := session.RunWith(cmd.Run, executor.RunOptions{
+			Privileged:  isPrivileged(module, cmd),
+			Interactive: cmd.Interactive,
+		}); err != nil {
+			return "", fmt.Errorf("installation command '%s' failed: %w", cmd, err)
+		}
+		entry.CommandsDone = i + 1
+		entry.Env, entry.Unset = session.EnvChanges()
+		saveJournal()
+	}
+
+	if module.Version != "" && !dryRun {
+		if installed, ok, err := versionSatisfied(session, name, module); err == nil && !ok {
+			fmt.Printf("Warning: after installing, version %s still does not satisfy %s\n", dashIfEmpty(installed), module.Version)
 		}
 	}
 
 	// 4. Apply dotfile configurations
 	if err := applyConfiguration(module, dryRun); err != nil {
-		return err
+		return "", err
 	}
 
-	installedModules[name] = true
+	entry.Installed = true
+	saveJournal()
+
 	fmt.Printf("--- Successfully installed module: %s ---\n", name)
+	return statusInstalled, nil
+}
+
+// acquirePrivileges asks for sudo once when the plan contains privileged
+// steps, or fails early listing them when sudo is not allowed.
+func acquirePrivileges(steps []privilegedStep, dryRun bool) error {
+	privilege.DryRun = dryRun
+	if len(steps) == 0 || !privilege.NeedsSudo() {
+		return nil
+	}
+
+	if noSudo {
+		var b strings.Builder
+		b.WriteString("--no-sudo is set, but these steps need root privileges:\n")
+		for _, step := range steps {
+			fmt.Fprintf(&b, "  - [%s] %s\n", step.Module, step.Command)
+		}
+		b.WriteString("Run dotm as root, drop --no-sudo, or remove these modules from the plan.")
+		return errors.New(b.String())
+	}
+
+	if err := privilege.Acquire(); err != nil {
+		return err
+	}
 	return nil
 }
 
+// newModuleSession prepares the shell, working directory, environment and
+// scratch directory shared by a module's commands.
+func newModuleSession(name string, module config.Module, dryRun bool) (*executor.Session, error) {
+	session, err := executor.NewSession(executor.SessionOptions{
+		Name:      name,
+		Shell:     module.Shell,
+		Dir:       module.Workdir,
+		Env:       module.Env,
+		DryRun:    dryRun,
+		Privilege: privilege,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("prepare environment for module '%s': %w", name, err)
+	}
+	return session, nil
+}
+
+// journalEntry returns the journal checkpoint for a module. During a dry run
+// it returns a throwaway entry so callers need not special-case it.
+func journalEntry(name string, module config.Module) *state.JournalEntry {
+	if journal == nil {
+		return &state.JournalEntry{}
+	}
+	return journal.Entry(name, module.Hash())
+}
+
+func saveJournal() {
+	if journal == nil {
+		return
+	}
+	if err := journal.Save(); err != nil {
+		fmt.Printf("Warning: could not write run journal: %v\n", err)
+	}
+}
+
+func recordResult(name string, status installStatus, err error) {
+	if _, ok := installResults[name]; !ok {
+		installOrder = append(installOrder, name)
+	}
+	installResults[name] = &installResult{Name: name, Status: status, Err: err}
+}
+
+func installFailed() bool {
+	for _, result := range installResults {
+		if result.Status == statusFailed || result.Status == statusSkipped {
+			return true
+		}
+	}
+	return false
+}
+
+func printInstallSummary() {
+	fmt.Println("\nInstall summary:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MODULE\tSTATUS\tDETAIL")
+	for _, name := range installOrder {
+		result := installResults[name]
+		detail := ""
+		if result.Err != nil {
+			detail = result.Err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", name, result.Status, detail)
+	}
+	w.Flush()
+}
+
 func applyConfiguration(module config.Module, dryRun bool) error {
 	if len(module.Apply) > 0 {
 		fmt.Println("Applying configurations...")
@@ -107,6 +441,10 @@ func applyConfiguration(module config.Module, dryRun bool) error {
 				if err := fileutil.InjectLine(step.Target, step.Line, dryRun); err != nil {
 					return fmt.Errorf("failed to apply inject strategy on '%s': %w", step.Target, err)
 				}
+			case "decrypt":
+				if err := applyDecrypt(step, dryRun); err != nil {
+					return fmt.Errorf("failed to apply decrypt strategy on '%s': %w", step.Target, err)
+				}
 			default:
 				return fmt.Errorf("unknown apply strategy: '%s'", step.Strategy)
 			}
@@ -118,4 +456,9 @@ func applyConfiguration(module config.Module, dryRun bool) error {
 func init() {
 	rootCmd.AddCommand(installCmd)
 	installCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Simulate the installation without making any changes")
+	installCmd.Flags().BoolVar(&keepGoing, "keep-going", false, "Continue with independent modules when one fails and print a summary")
+	installCmd.Flags().BoolVar(&resume, "resume", false, "Resume the last interrupted install run from its journal")
+	installCmd.Flags().BoolVar(&reviewScripts, "review", false, "Show downloaded installer scripts and ask before running them")
+	installCmd.Flags().BoolVar(&installLocked, "locked", false, "Install what dotm.lock pins, warning about what it cannot; fail if config.yaml changed since")
+	installCmd.Flags().BoolVar(&noSudo, "no-sudo", false, "Never use sudo; fail early if the plan has privileged steps")
 }
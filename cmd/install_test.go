@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/w31r4/dotm/config"
+	"github.com/w31r4/dotm/pkg/state"
+)
+
+func resetInstallRun(t *testing.T) {
+	t.Helper()
+	installResults = make(map[string]*installResult)
+	installOrder = nil
+	batchedModules = make(map[string]bool)
+}
+
+func TestInstallModule_FailureSkipsDependents(t *testing.T) {
+	resetInstallRun(t)
+	cfg := &config.Config{Modules: map[string]config.Module{
+		"broken": {Install: map[string][]config.Command{"default": config.Commands("false")}},
+		"child":  {Dependencies: []string{"broken"}, Install: map[string][]config.Command{"default": config.Commands("true")}},
+		"other":  {Check: "true", Install: map[string][]config.Command{"default": config.Commands("false")}},
+		"fresh":  {Install: map[string][]config.Command{"default": config.Commands("true")}},
+	}}
+
+	for _, name := range []string{"child", "other", "fresh"} {
+		_ = installModule(name, cfg, false)
+	}
+
+	want := map[string]installStatus{
+		"broken": statusFailed,
+		"child":  statusSkipped,
+		"other":  statusPresent,
+		"fresh":  statusInstalled,
+	}
+	for name, status := range want {
+		got, ok := installResults[name]
+		if !ok {
+			t.Fatalf("no result recorded for %s", name)
+		}
+		if got.Status != status {
+			t.Errorf("%s: got status %q, want %q", name, got.Status, status)
+		}
+	}
+	if !installFailed() {
+		t.Fatalf("installFailed() = false, want true")
+	}
+}
+
+func TestInstallModule_RevisitFailedDependency(t *testing.T) {
+	resetInstallRun(t)
+	cfg := &config.Config{Modules: map[string]config.Module{
+		"broken": {Install: map[string][]config.Command{"default": config.Commands("false")}},
+		"a":      {Dependencies: []string{"broken"}, Install: map[string][]config.Command{"default": config.Commands("true")}},
+		"b":      {Dependencies: []string{"broken"}, Install: map[string][]config.Command{"default": config.Commands("true")}},
+	}}
+
+	if err := installModule("a", cfg, false); err == nil {
+		t.Fatalf("expected error for a")
+	}
+	if err := installModule("b", cfg, false); err == nil {
+		t.Fatalf("expected error for b")
+	}
+	if got := installResults["b"].Status; got != statusSkipped {
+		t.Fatalf("b: got status %q, want %q", got, statusSkipped)
+	}
+	if len(installOrder) != 3 {
+		t.Fatalf("installOrder = %v, want 3 entries", installOrder)
+	}
+}
+
+func TestInstallModule_ResumeSkipsCompletedCommands(t *testing.T) {
+	resetInstallRun(t)
+	module := config.Module{Install: map[string][]config.Command{"default": config.Commands("false", "true")}}
+	cfg := &config.Config{Modules: map[string]config.Module{"partial": module}}
+
+	journal = state.NewJournal(filepath.Join(t.TempDir(), "journal.json"), "config.yaml", []string{"partial"})
+	t.Cleanup(func() { journal = nil })
+	journal.Entry("partial", module.Hash()).CommandsDone = 1
+
+	if err := installModule("partial", cfg, false); err != nil {
+		t.Fatalf("installModule: %v", err)
+	}
+	if !journal.Entries["partial"].Installed {
+		t.Fatalf("journal entry not marked installed")
+	}
+}
+
+func TestInstallModule_ResumeRestoresExports(t *testing.T) {
+	resetInstallRun(t)
+	marker := filepath.Join(t.TempDir(), "ready")
+	module := config.Module{Install: map[string][]config.Command{"default": config.Commands(
+		"export STEP=done; unset HOME",
+		`test "$STEP" = done && test -z "$HOME" && test -e `+marker,
+	)}}
+	cfg := &config.Config{Modules: map[string]config.Module{"staged": module}}
+
+	journal = state.NewJournal(filepath.Join(t.TempDir(), "journal.json"), "config.yaml", []string{"staged"})
+	t.Cleanup(func() { journal = nil })
+	if err := installModule("staged", cfg, false); err == nil {
+		t.Fatal("expected the second command to fail")
+	}
+	if got := journal.Entries["staged"].CommandsDone; got != 1 {
+		t.Fatalf("CommandsDone = %d, want 1", got)
+	}
+
+	// The resumed run skips the export, so it has to restore it.
+	resetInstallRun(t)
+	writeTestFile(t, marker, "")
+	if err := installModule("staged", cfg, false); err != nil {
+		t.Fatalf("resumed installModule: %v", err)
+	}
+}
+
+func TestPrivilegedSteps(t *testing.T) {
+	cfg := &config.Config{Modules: map[string]config.Module{
+		"base": {Install: map[string][]config.Command{"default": {
+			{Run: "echo unprivileged"},
+			{Run: "apt-get update", Privileged: true},
+		}}},
+		"tool": {Dependencies: []string{"base"}, Privileged: true, Install: map[string][]config.Command{"default": config.Commands("make install")}},
+	}}
+
+	got := privilegedSteps(cfg, resolvePlan(cfg, []string{"tool"}))
+	want := []privilegedStep{{Module: "base", Command: "apt-get update"}, {Module: "tool", Command: "make install"}}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("step %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestInstallModule_UnsatisfiedVersionReinstalls(t *testing.T) {
+	resetInstallRun(t)
+	marker := filepath.Join(t.TempDir(), "installed")
+	cfg := &config.Config{Modules: map[string]config.Module{
+		"old": {
+			Check:      "true",
+			Version:    ">=1.25 <2",
+			VersionCmd: "echo go version go1.19.13 linux/amd64",
+			Install:    map[string][]config.Command{"default": config.Commands("touch " + marker)},
+		},
+		"current": {
+			Check:        "true",
+			Version:      ">=1.25 <2",
+			VersionCmd:   "echo go version go1.25.3 linux/amd64",
+			VersionRegex: `go(\d+\.\d+(?:\.\d+)?)`,
+			Install:      map[string][]config.Command{"default": config.Commands("false")},
+		},
+	}}
+
+	for _, name := range []string{"old", "current"} {
+		if err := installModule(name, cfg, false); err != nil {
+			t.Fatalf("installModule(%s): %v", name, err)
+		}
+	}
+	if got := installResults["old"].Status; got != statusInstalled {
+		t.Errorf("old: got %q, want %q", got, statusInstalled)
+	}
+	if got := installResults["current"].Status; got != statusPresent {
+		t.Errorf("current: got %q, want %q", got, statusPresent)
+	}
+}
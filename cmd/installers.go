@@ -0,0 +1,530 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/w31r4/dotm/config"
+	"github.com/w31r4/dotm/pkg/archive"
+	"github.com/w31r4/dotm/pkg/executor"
+	"github.com/w31r4/dotm/pkg/gitrepo"
+	"github.com/w31r4/dotm/pkg/script"
+	"github.com/w31r4/dotm/pkg/state"
+	"github.com/w31r4/dotm/pkg/toolchain"
+	"github.com/w31r4/dotm/pkg/xcmd"
+)
+
+// installer is a built-in install type declared on a module, such as
+// `xcmd:`. Packages are handled separately so they can be batched.
+type installer interface {
+	// kind is the config key of the install type.
+	kind() string
+	// installed reports whether the module is present and matches any
+	// pinned version.
+	installed() (bool, error)
+	// install installs (or re-pins) the module.
+	install(s *executor.Session) error
+	// version reports the installed version, or "" if unknown.
+	version() (string, error)
+}
+
+// updater is implemented by install types that can bring an existing
+// installation up to date.
+type updater interface {
+	// outdated describes the available update, or returns "" when the
+	// installation is current or missing.
+	outdated() (string, error)
+	// update upgrades an existing installation; it does nothing when the
+	// module is not installed.
+	update(s *executor.Session) error
+}
+
+// uninstaller is implemented by install types that can remove what they
+// installed.
+type uninstaller interface {
+	uninstall(s *executor.Session) error
+}
+
+// moduleInstallers returns the built-in install types a module declares.
+func moduleInstallers(name string, module config.Module) []installer {
+	var installers []installer
+	if module.XCmd != nil {
+		installers = append(installers, &xcmdInstaller{spec: *module.XCmd, client: xcmd.New()})
+	}
+	if module.Archive != nil {
+		installers = append(installers, &archiveInstaller{name: name, spec: *module.Archive})
+	}
+	if module.Script != nil {
+		installers = append(installers, &scriptInstaller{name: name, spec: *module.Script})
+	}
+	if module.Git != nil {
+		installers = append(installers, &gitInstaller{name: name, spec: *module.Git, env: module.Env})
+	}
+	tools := module.Tools()
+	for _, kind := range toolchain.Names {
+		if spec, ok := tools[kind]; ok {
+			backend, _ := toolchain.New(kind)
+			installers = append(installers, &toolInstaller{name: name, spec: spec, backend: backend})
+		}
+	}
+	return installers
+}
+
+// installersPresent reports whether every installer reports the module as
+// installed.
+func installersPresent(installers []installer) (bool, error) {
+	for _, inst := range installers {
+		ok, err := inst.installed()
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", inst.kind(), err)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// xcmdInstaller installs a package with `x env use`.
+type xcmdInstaller struct {
+	spec   config.XCmdSpec
+	client *xcmd.Client
+}
+
+func (x *xcmdInstaller) kind() string { return "xcmd" }
+
+func (x *xcmdInstaller) installed() (bool, error) {
+	installed, err := x.client.Installed()
+	if err == xcmd.ErrNotBootstrapped {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	v, ok := installed[x.spec.Package]
+	return ok && xcmd.VersionMatches(v, x.spec.Version), nil
+}
+
+func (x *xcmdInstaller) install(s *executor.Session) error {
+	err := x.client.Use(s, x.spec.Package, x.spec.Version)
+	if errors.Is(err, xcmd.ErrNotBootstrapped) {
+		return fmt.Errorf("%w; add a module named %s that installs it with the script: install type (pinned by sha256) and install it first", err, xcmd.ProviderModule)
+	}
+	return err
+}
+
+func (x *xcmdInstaller) version() (string, error) {
+	v, err := x.client.Version(x.spec.Package)
+	if err == xcmd.ErrNotBootstrapped {
+		return "", nil
+	}
+	return v, err
+}
+
+// archiveInstaller downloads a release archive and installs its binaries.
+type archiveInstaller struct {
+	name string
+	spec config.ArchiveSpec
+}
+
+func (a *archiveInstaller) kind() string { return "archive" }
+
+func (a *archiveInstaller) installed() (bool, error) {
+	rec, err := installedRecord(a.name)
+	if err != nil || rec == nil {
+		return false, err
+	}
+	if rec.Type != a.kind() || rec.Version != a.spec.Version {
+		return false, nil
+	}
+	for _, file := range rec.Files {
+		if _, err := os.Stat(file); err != nil {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (a *archiveInstaller) install(s *executor.Session) error {
+	vars := archive.NewVars(a.spec.Version, a.spec.Platforms)
+	url, err := archive.Expand(a.spec.URL, vars)
+	if err != nil {
+		return err
+	}
+	want, ok := a.spec.SHA256[archive.Platform()]
+	if !ok {
+		return fmt.Errorf("no sha256 for platform %s; refusing to install an unverified download", archive.Platform())
+	}
+	patterns := a.spec.Files
+	if len(patterns) == 0 {
+		patterns = []string{a.name}
+	}
+	for i, pattern := range patterns {
+		if patterns[i], err = archive.Expand(pattern, vars); err != nil {
+			return err
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("get home dir: %w", err)
+	}
+	binDir, err := expandHomePath(a.binDir(), home)
+	if err != nil {
+		return err
+	}
+
+	if s.DryRun {
+		fmt.Printf("[DRY RUN] Would download %s, verify sha256 %s and install %s into %s\n", url, want, strings.Join(patterns, ", "), binDir)
+		return nil
+	}
+
+	fmt.Printf("Downloading %s\n", url)
+	// Name the download after the URL path so the format can be detected.
+	assetName := path.Base(strings.SplitN(url, "?", 2)[0])
+	download := filepath.Join(s.TempDir(), assetName)
+	got, err := archive.Download(url, download)
+	if err != nil {
+		return err
+	}
+	if err := archive.Verify(got, want); err != nil {
+		return fmt.Errorf("%s: %w", url, err)
+	}
+
+	files, err := archive.Install(download, archive.Format(assetName), patterns, binDir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		fmt.Printf("Installed %s\n", file)
+	}
+
+	return recordInstalled(a.name, &state.InstalledModule{
+		Type:    a.kind(),
+		Version: a.spec.Version,
+		URL:     url,
+		SHA256:  got,
+		Files:   files,
+	})
+}
+
+func (a *archiveInstaller) version() (string, error) {
+	rec, err := installedRecord(a.name)
+	if err != nil || rec == nil {
+		return "", err
+	}
+	return rec.Version, nil
+}
+
+// outdated reports a version bump in config.yaml. Archives are pinned by
+// version and checksum, so newer upstream releases are not discovered.
+func (a *archiveInstaller) outdated() (string, error) {
+	rec, err := installedRecord(a.name)
+	if err != nil || rec == nil || rec.Type != a.kind() {
+		return "", err
+	}
+	if rec.Version != a.spec.Version {
+		return fmt.Sprintf("%s -> %s", rec.Version, a.spec.Version), nil
+	}
+	return "", nil
+}
+
+// update reinstalls the archive when the configured version changed.
+func (a *archiveInstaller) update(s *executor.Session) error {
+	rec, err := installedRecord(a.name)
+	if err != nil {
+		return err
+	}
+	if rec == nil || rec.Type != a.kind() {
+		fmt.Printf("%s was not installed from an archive; run `dotm install %s`\n", a.name, a.name)
+		return nil
+	}
+	if ok, err := a.installed(); err != nil || ok {
+		return err
+	}
+	return a.install(s)
+}
+
+func (a *archiveInstaller) binDir() string {
+	if a.spec.BinDir != "" {
+		return a.spec.BinDir
+	}
+	return "~/.local/bin"
+}
+
+// scriptInstaller downloads an installer script to the cache, verifies
+// it and runs it from there.
+type scriptInstaller struct {
+	name string
+	spec config.ScriptSpec
+}
+
+func (sc *scriptInstaller) kind() string { return "script" }
+
+// installed reports whether this exact script already ran successfully.
+// Modules usually pair a script with a check command, which takes
+// precedence.
+func (sc *scriptInstaller) installed() (bool, error) {
+	rec, err := installedRecord(sc.name)
+	if err != nil || rec == nil {
+		return false, err
+	}
+	return rec.Type == sc.kind() && rec.URL == sc.spec.URL && (sc.spec.SHA256 == "" || strings.EqualFold(rec.SHA256, sc.spec.SHA256)), nil
+}
+
+func (sc *scriptInstaller) install(s *executor.Session) error {
+	if s.DryRun {
+		fmt.Printf("[DRY RUN] Would download %s to the script cache and run it\n", sc.spec.URL)
+		return nil
+	}
+
+	path, digest, err := script.Fetch(sc.spec.URL, sc.spec.SHA256)
+	if err != nil {
+		return err
+	}
+	if sc.spec.SHA256 == "" {
+		fmt.Printf("Script %s is not pinned; add `sha256: %s` to pin it.\n", sc.spec.URL, digest)
+	}
+
+	if reviewScripts {
+		if err := reviewScript(sc.spec.URL, path, digest); err != nil {
+			return err
+		}
+	}
+
+	shell := sc.spec.Shell
+	if shell == "" {
+		shell = "sh"
+	}
+	command := shell + " " + executor.ShellQuote(path)
+	for _, arg := range sc.spec.Args {
+		command += " " + executor.ShellQuote(arg)
+	}
+	if err := s.RunWith(command, executor.RunOptions{Privileged: sc.spec.Privileged, Interactive: sc.spec.Interactive}); err != nil {
+		return err
+	}
+
+	return recordInstalled(sc.name, &state.InstalledModule{
+		Type:   sc.kind(),
+		URL:    sc.spec.URL,
+		SHA256: digest,
+	})
+}
+
+func (sc *scriptInstaller) version() (string, error) {
+	return "", nil
+}
+
+// gitInstaller clones a repository and keeps it on the configured ref.
+type gitInstaller struct {
+	name string
+	spec config.GitSpec
+	// env is the module's environment, used to expand dest.
+	env map[string]string
+}
+
+func (g *gitInstaller) kind() string { return "git" }
+
+// dest expands the checkout directory with the module's environment.
+func (g *gitInstaller) dest(s *executor.Session) string {
+	return s.Expand(g.spec.Dest)
+}
+
+// probeDest expands the checkout directory outside of an install run.
+func (g *gitInstaller) probeDest() (string, error) {
+	s, err := executor.NewSession(executor.SessionOptions{Name: g.name, Env: g.env})
+	if err != nil {
+		return "", err
+	}
+	defer s.Close()
+	return g.dest(s), nil
+}
+
+func (g *gitInstaller) installed() (bool, error) {
+	dest, err := g.probeDest()
+	if err != nil {
+		return false, err
+	}
+	if !gitrepo.IsRepo(dest) {
+		return false, nil
+	}
+	return gitrepo.AtRef(dest, g.spec.Ref)
+}
+
+// install clones the repository, or moves an existing checkout to the
+// configured ref.
+func (g *gitInstaller) install(s *executor.Session) error {
+	dest := g.dest(s)
+	if gitrepo.IsRepo(dest) {
+		return gitrepo.Update(s, dest, g.spec.Ref, g.spec.Depth)
+	}
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("%s exists and is not a git checkout", dest)
+	}
+	return gitrepo.Clone(s, g.spec.Repo, dest, g.spec.Ref, g.spec.Depth)
+}
+
+func (g *gitInstaller) outdated() (string, error) {
+	dest, err := g.probeDest()
+	if err != nil || !gitrepo.IsRepo(dest) {
+		return "", err
+	}
+	if ok, err := gitrepo.AtRef(dest, g.spec.Ref); err != nil {
+		return "", err
+	} else if !ok {
+		return fmt.Sprintf("checkout is not at %s", g.spec.Ref), nil
+	}
+	behind, err := gitrepo.Behind(dest)
+	if err != nil || behind == 0 {
+		return "", err
+	}
+	return fmt.Sprintf("%d new commit(s)", behind), nil
+}
+
+// update fetches the configured ref and fast-forwards or checks it out.
+func (g *gitInstaller) update(s *executor.Session) error {
+	dest := g.dest(s)
+	if !gitrepo.IsRepo(dest) {
+		fmt.Printf("%s is not cloned yet; run `dotm install %s`\n", dest, g.name)
+		return nil
+	}
+	return gitrepo.Update(s, dest, g.spec.Ref, g.spec.Depth)
+}
+
+// version reports the checked-out commit.
+func (g *gitInstaller) version() (string, error) {
+	dest, err := g.probeDest()
+	if err != nil || !gitrepo.IsRepo(dest) {
+		return "", err
+	}
+	head, err := gitrepo.Head(dest)
+	if err != nil {
+		return "", err
+	}
+	if len(head) > 12 {
+		head = head[:12]
+	}
+	return head, nil
+}
+
+// toolInstaller installs a tool with a language package manager.
+type toolInstaller struct {
+	name    string
+	spec    config.ToolSpec
+	backend toolchain.Backend
+}
+
+func (t *toolInstaller) kind() string { return t.backend.Name() }
+
+func (t *toolInstaller) installed() (bool, error) {
+	v, err := t.backend.Version(t.spec.Package)
+	if err != nil {
+		return false, err
+	}
+	return v != "" && toolchain.VersionMatches(v, t.spec.Version), nil
+}
+
+func (t *toolInstaller) install(s *executor.Session) error {
+	return t.backend.Install(s, t.spec.Package, t.spec.Version)
+}
+
+func (t *toolInstaller) version() (string, error) {
+	return t.backend.Version(t.spec.Package)
+}
+
+// outdated reports a changed pin, or a newer release for unpinned tools.
+func (t *toolInstaller) outdated() (string, error) {
+	v, err := t.backend.Version(t.spec.Package)
+	if err != nil || v == "" {
+		return "", err
+	}
+	want := t.spec.Version
+	if want == "" {
+		if want, err = t.backend.Latest(t.spec.Package); err != nil || want == "" {
+			return "", err
+		}
+	}
+	if toolchain.VersionMatches(v, want) {
+		return "", nil
+	}
+	return fmt.Sprintf("%s -> %s", v, want), nil
+}
+
+// update moves a pinned tool to its pin and upgrades an unpinned one.
+func (t *toolInstaller) update(s *executor.Session) error {
+	v, err := t.backend.Version(t.spec.Package)
+	if err != nil {
+		return err
+	}
+	if v == "" {
+		fmt.Printf("%s is not installed yet; run `dotm install %s`\n", t.spec.Package, t.name)
+		return nil
+	}
+	if t.spec.Version != "" {
+		if toolchain.VersionMatches(v, t.spec.Version) {
+			return nil
+		}
+		return t.backend.Install(s, t.spec.Package, t.spec.Version)
+	}
+	return t.backend.Upgrade(s, t.spec.Package)
+}
+
+func (t *toolInstaller) uninstall(s *executor.Session) error {
+	v, err := t.backend.Version(t.spec.Package)
+	if err != nil || v == "" {
+		return err
+	}
+	return t.backend.Uninstall(s, t.spec.Package)
+}
+
+// reviewScript prints a script and asks for confirmation before it runs.
+func reviewScript(url, path, digest string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	fmt.Printf("Script: %s\n", url)
+	fmt.Printf("SHA256: %s\n", digest)
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	for i, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		fmt.Printf("%4d  %s\n", i+1, line)
+	}
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	if !confirm("Run this script?") {
+		return fmt.Errorf("script %s rejected during review", url)
+	}
+	return nil
+}
+
+// installedRecord returns what a built-in install type recorded for a
+// module, or nil if nothing was recorded.
+func installedRecord(name string) (*state.InstalledModule, error) {
+	path, err := state.InstalledPath()
+	if err != nil {
+		return nil, err
+	}
+	installed, err := state.LoadInstalled(path)
+	if err != nil {
+		return nil, err
+	}
+	return installed.Modules[name], nil
+}
+
+// recordInstalled stores the install record of a module.
+func recordInstalled(name string, rec *state.InstalledModule) error {
+	path, err := state.InstalledPath()
+	if err != nil {
+		return err
+	}
+	installed, err := state.LoadInstalled(path)
+	if err != nil {
+		return err
+	}
+	rec.InstalledAt = time.Now()
+	installed.Modules[name] = rec
+	return installed.Save()
+}
@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/w31r4/dotm/config"
+	"github.com/w31r4/dotm/pkg/archive"
+	"github.com/w31r4/dotm/pkg/executor"
+	"github.com/w31r4/dotm/pkg/xcmd"
+)
+
+func TestArchiveInstaller(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "tool-1.0.0/tool", Mode: 0755, Size: 2, Typeflag: tar.TypeReg})
+	tw.Write([]byte("ok"))
+	tw.Close()
+	gz.Close()
+	data := buf.Bytes()
+	digest := sha256.Sum256(data)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1.0.0/tool.tar.gz" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	binDir := filepath.Join(t.TempDir(), "bin")
+	inst := &archiveInstaller{name: "tool", spec: config.ArchiveSpec{
+		URL:     srv.URL + "/v{{.Version}}/tool.tar.gz",
+		Version: "1.0.0",
+		SHA256:  map[string]string{archive.Platform(): hex.EncodeToString(digest[:])},
+		BinDir:  binDir,
+	}}
+
+	if ok, err := inst.installed(); err != nil || ok {
+		t.Fatalf("installed() before install = %v, %v; want false, nil", ok, err)
+	}
+
+	s, err := executor.NewSession(executor.SessionOptions{Name: "tool"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := inst.install(s); err != nil {
+		t.Fatalf("install: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(binDir, "tool")); err != nil {
+		t.Fatalf("binary not installed: %v", err)
+	}
+	if ok, err := inst.installed(); err != nil || !ok {
+		t.Fatalf("installed() after install = %v, %v; want true, nil", ok, err)
+	}
+	if v, _ := inst.version(); v != "1.0.0" {
+		t.Fatalf("version() = %q, want %q", v, "1.0.0")
+	}
+
+	inst.spec.Version = "1.1.0"
+	if ok, _ := inst.installed(); ok {
+		t.Fatalf("installed() should be false once the configured version changes")
+	}
+}
+
+func TestArchiveInstaller_ChecksumMismatch(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tampered"))
+	}))
+	defer srv.Close()
+
+	binDir := filepath.Join(t.TempDir(), "bin")
+	inst := &archiveInstaller{name: "tool", spec: config.ArchiveSpec{
+		URL:     srv.URL + "/tool",
+		Version: "1.0.0",
+		SHA256:  map[string]string{archive.Platform(): "0000"},
+		BinDir:  binDir,
+	}}
+	s, err := executor.NewSession(executor.SessionOptions{Name: "tool"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := inst.install(s); err == nil {
+		t.Fatalf("expected checksum mismatch error")
+	}
+	if _, err := os.Stat(filepath.Join(binDir, "tool")); !os.IsNotExist(err) {
+		t.Fatalf("binary installed despite checksum mismatch")
+	}
+}
+
+func TestGitInstaller(t *testing.T) {
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_AUTHOR_NAME", "dotm")
+	t.Setenv("GIT_AUTHOR_EMAIL", "dotm@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "dotm")
+	t.Setenv("GIT_COMMITTER_EMAIL", "dotm@example.com")
+	git := func(dir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	dir := t.TempDir()
+	bare := filepath.Join(dir, "plugin.git")
+	work := filepath.Join(dir, "work")
+	git(dir, "init", "--bare", "--initial-branch=main", bare)
+	git(dir, "init", "--initial-branch=main", work)
+	os.WriteFile(filepath.Join(work, "plugin.zsh"), []byte("# v1\n"), 0644)
+	git(work, "add", ".")
+	git(work, "commit", "-m", "v1")
+	git(work, "remote", "add", "origin", bare)
+	git(work, "push", "origin", "main")
+
+	inst := &gitInstaller{name: "plugin", spec: config.GitSpec{
+		Repo: bare,
+		Dest: "${PLUGINS:-/nonexistent}/plugin",
+	}, env: map[string]string{"PLUGINS": filepath.Join(dir, "plugins")}}
+
+	if ok, err := inst.installed(); err != nil || ok {
+		t.Fatalf("installed() before install = %v, %v; want false, nil", ok, err)
+	}
+	s, err := executor.NewSession(executor.SessionOptions{Name: "plugin", Env: inst.env})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := inst.install(s); err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	if ok, err := inst.installed(); err != nil || !ok {
+		t.Fatalf("installed() after install = %v, %v; want true, nil", ok, err)
+	}
+
+	os.WriteFile(filepath.Join(work, "plugin.zsh"), []byte("# v2\n"), 0644)
+	git(work, "commit", "-am", "v2")
+	git(work, "push", "origin", "main")
+	head := git(work, "rev-parse", "HEAD")
+
+	if err := inst.update(s); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if v, _ := inst.version(); v != head[:12] {
+		t.Fatalf("version() = %q, want %q", v, head[:12])
+	}
+}
+
+func TestToolInstaller(t *testing.T) {
+	// A fake npm that keeps the installed version of one package in a file.
+	dir := t.TempDir()
+	versionFile := filepath.Join(dir, "installed")
+	script := `#!/bin/sh
+case "$1" in
+  ls)
+    if [ -f "` + versionFile + `" ]; then
+      printf '{"dependencies":{"prettier":{"version":"%s"}}}' "$(cat "` + versionFile + `")"
+    else
+      echo '{}'; exit 1
+    fi ;;
+  view) echo 3.4.0 ;;
+  install) v="${3#prettier@}"; [ "$v" = latest ] && v=3.4.0; echo "$v" > "` + versionFile + `" ;;
+  uninstall) rm -f "` + versionFile + `" ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "npm"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	insts := moduleInstallers("prettier", config.Module{Npm: &config.ToolSpec{Package: "prettier", Version: "3.3.3"}})
+	if len(insts) != 1 || insts[0].kind() != "npm" {
+		t.Fatalf("moduleInstallers = %v, want one npm installer", insts)
+	}
+	inst := insts[0].(*toolInstaller)
+
+	if ok, err := inst.installed(); err != nil || ok {
+		t.Fatalf("installed() before install = %v, %v; want false, nil", ok, err)
+	}
+	s, err := executor.NewSession(executor.SessionOptions{Name: "prettier"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := inst.install(s); err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	if ok, err := inst.installed(); err != nil || !ok {
+		t.Fatalf("installed() after install = %v, %v; want true, nil", ok, err)
+	}
+	if detail, err := inst.outdated(); err != nil || detail != "" {
+		t.Fatalf("outdated() of a pinned tool = %q, %v; want up to date", detail, err)
+	}
+
+	// Unpinning the tool makes the newer release available.
+	inst.spec.Version = ""
+	if detail, err := inst.outdated(); err != nil || detail != "3.3.3 -> 3.4.0" {
+		t.Fatalf("outdated() = %q, %v; want 3.3.3 -> 3.4.0", detail, err)
+	}
+	if err := inst.update(s); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if v, _ := inst.version(); v != "3.4.0" {
+		t.Fatalf("version after update = %q, want 3.4.0", v)
+	}
+
+	if err := inst.uninstall(s); err != nil {
+		t.Fatalf("uninstall: %v", err)
+	}
+	if ok, _ := inst.installed(); ok {
+		t.Fatal("installed() after uninstall = true")
+	}
+}
+
+func TestResolvePlan_ToolchainDependency(t *testing.T) {
+	cfg := &config.Config{Modules: map[string]config.Module{
+		"go":    {Check: "command -v go"},
+		"gopls": {Go: &config.ToolSpec{Package: "golang.org/x/tools/gopls"}},
+		"ruff":  {UvTool: &config.ToolSpec{Package: "ruff"}}, // no uv module
+	}}
+	plan := resolvePlan(cfg, []string{"gopls", "ruff"})
+	if strings.Join(plan, ",") != "go,gopls,ruff" {
+		t.Fatalf("plan = %v, want [go gopls ruff]", plan)
+	}
+}
+
+func TestXCmdInstaller_NotBootstrapped(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	t.Setenv("X_CMD_ROOT", t.TempDir())
+	cfg := &config.Config{Modules: map[string]config.Module{
+		"x-cmd": {Check: "command -v x", Script: &config.ScriptSpec{URL: "https://get.x-cmd.com"}},
+		"fzf":   {XCmd: &config.XCmdSpec{Package: "fzf"}},
+	}}
+	if plan := resolvePlan(cfg, []string{"fzf"}); strings.Join(plan, ",") != "x-cmd,fzf" {
+		t.Fatalf("plan = %v, want [x-cmd fzf]", plan)
+	}
+
+	s, err := executor.NewSession(executor.SessionOptions{Name: "fzf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	inst := &xcmdInstaller{spec: *cfg.Modules["fzf"].XCmd, client: xcmd.New()}
+	err = inst.install(s)
+	if !errors.Is(err, xcmd.ErrNotBootstrapped) || !strings.Contains(err.Error(), "script: install type") {
+		t.Fatalf("install without x-cmd = %v, want ErrNotBootstrapped with a hint", err)
+	}
+}
@@ -0,0 +1,292 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/w31r4/dotm/config"
+	"github.com/w31r4/dotm/pkg/archive"
+	"github.com/w31r4/dotm/pkg/gitrepo"
+	"github.com/w31r4/dotm/pkg/lock"
+	"github.com/w31r4/dotm/pkg/pkgmgr"
+	"github.com/w31r4/dotm/pkg/script"
+	"github.com/w31r4/dotm/pkg/xcmd"
+)
+
+var lockCmd = &cobra.Command{
+	Use:   "lock [module...]",
+	Short: "Pin resolved versions and artifacts in dotm.lock",
+	Long: `Resolve what each module would install and record it in dotm.lock, next
+to config.yaml. Commit the lock file so every machine installs the same
+thing with 'dotm install --locked'.
+
+For each module the lock records its install type and a hash of its
+definition, plus:
+  - git:     the commit the ref points to
+  - script:  the sha256 of the installer script
+  - archive: the version, URL and sha256 for every platform
+  - xcmd:    the pinned or currently installed version
+  - go, cargo, uv_tool, npm: the pinned or currently installed version
+
+  - packages: the installed version of each package, for this machine's
+    package manager
+
+apt and dnf install the locked package versions. brew and pacman cannot
+install a given version, and install commands are never pinned; 'dotm
+install --locked' warns about everything it cannot pin.
+
+Without arguments every module is locked. With module names, only those
+modules (and their dependencies) are re-resolved; other entries are kept.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			log.Fatalf("Error loading config from %s: %v", configPath, err)
+		}
+
+		path := lock.Path(configPath)
+		lockFile := lock.New()
+		names := args
+		if len(names) == 0 {
+			for name := range cfg.Modules {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+		} else {
+			for _, name := range names {
+				if _, ok := cfg.Modules[name]; !ok {
+					log.Fatalf("Module '%s' not found in configuration", name)
+				}
+			}
+			if existing, err := lock.Load(path); err == nil {
+				lockFile = existing
+			}
+			names = resolvePlan(cfg, names)
+		}
+
+		// Drop modules that no longer exist.
+		for name := range lockFile.Modules {
+			if _, ok := cfg.Modules[name]; !ok {
+				delete(lockFile.Modules, name)
+			}
+		}
+
+		for _, name := range names {
+			fmt.Printf("Locking %s...\n", name)
+			entry, err := lockModule(name, cfg.Modules[name])
+			if err != nil {
+				log.Fatalf("Failed to lock module %s: %v", name, err)
+			}
+			lockFile.Modules[name] = entry
+		}
+
+		if err := lockFile.Save(path); err != nil {
+			log.Fatalf("Error writing %s: %v", path, err)
+		}
+		fmt.Printf("✅ Locked %d modules in %s\n", len(names), path)
+	},
+}
+
+// lockModule resolves a module's install types to pinned artifacts.
+func lockModule(name string, module config.Module) (*lock.Module, error) {
+	entry := &lock.Module{Hash: module.Hash(), Versions: make(map[string]string)}
+
+	var types []string
+	for _, inst := range moduleInstallers(name, module) {
+		types = append(types, inst.kind())
+	}
+	if len(module.Packages) > 0 {
+		types = append(types, "packages")
+	}
+	if len(module.Install) > 0 {
+		types = append(types, "commands")
+	}
+	entry.Type = strings.Join(types, ",")
+
+	if x := module.XCmd; x != nil {
+		v := x.Version
+		if v == "" {
+			var err error
+			v, err = xcmd.New().Version(x.Package)
+			if err != nil && err != xcmd.ErrNotBootstrapped {
+				return nil, err
+			}
+			if v == "" {
+				fmt.Printf("Warning: %s is not installed, so its x-cmd version is not pinned\n", x.Package)
+			}
+		}
+		if v != "" {
+			entry.Versions["xcmd"] = v
+		}
+	}
+
+	for _, inst := range moduleInstallers(name, module) {
+		t, ok := inst.(*toolInstaller)
+		if !ok {
+			continue
+		}
+		v := t.spec.Version
+		if v == "" {
+			var err error
+			if v, err = t.version(); err != nil {
+				return nil, err
+			}
+			if v == "" {
+				fmt.Printf("Warning: %s is not installed, so its %s version is not pinned\n", t.spec.Package, t.kind())
+			}
+		}
+		if v != "" {
+			entry.Versions[t.kind()] = v
+		}
+	}
+
+	if len(module.Packages) > 0 {
+		m, pkgs, err := modulePackages(module)
+		if err != nil {
+			fmt.Printf("Warning: %v, so the packages of %s are not pinned\n", err, name)
+		}
+		for _, pkg := range pkgs {
+			v, err := m.Version(pkg)
+			if err != nil {
+				return nil, err
+			}
+			if v == "" {
+				fmt.Printf("Warning: %s is not installed, so its %s version is not pinned\n", pkg, m.Name())
+				continue
+			}
+			if entry.Packages == nil {
+				entry.Packages = map[string]map[string]string{m.Name(): {}}
+			}
+			entry.Packages[m.Name()][pkg] = v
+		}
+	}
+
+	if a := module.Archive; a != nil {
+		if a.Version != "" {
+			entry.Versions["archive"] = a.Version
+		}
+		entry.Artifacts = make(map[string]lock.Artifact)
+		for platform, sha := range a.SHA256 {
+			url, err := archive.Expand(a.URL, archive.VarsFor(platform, a.Version, a.Platforms))
+			if err != nil {
+				return nil, err
+			}
+			entry.Artifacts[platform] = lock.Artifact{URL: url, SHA256: sha}
+		}
+	}
+
+	if sc := module.Script; sc != nil {
+		_, digest, err := script.Fetch(sc.URL, sc.SHA256)
+		if err != nil {
+			return nil, err
+		}
+		entry.URL = sc.URL
+		entry.SHA256 = digest
+	}
+
+	if g := module.Git; g != nil {
+		commit, err := gitrepo.Resolve(g.Repo, g.Ref)
+		if err != nil {
+			return nil, err
+		}
+		entry.URL = g.Repo
+		entry.Commit = commit
+	}
+
+	return entry, nil
+}
+
+// applyLock checks that every module in the plan is covered by the lock
+// file and pins the config to what the lock recorded.
+func applyLock(cfg *config.Config, plan []string, lockFile *lock.File) error {
+	var problems, loose []string
+	for _, name := range plan {
+		module := cfg.Modules[name]
+		entry, err := lockFile.Check(name, module.Hash())
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		loose = append(loose, unpinned(name, module, entry)...)
+		cfg.Modules[name] = lockedModule(module, entry)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("dotm.lock does not match config.yaml (run `dotm lock` to update it):\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	if len(loose) > 0 {
+		fmt.Printf("Warning: dotm.lock cannot pin these, so they install whatever is current:\n  - %s\n", strings.Join(loose, "\n  - "))
+	}
+	return nil
+}
+
+// unpinned describes the parts of a module the lock entry cannot pin on
+// this machine.
+func unpinned(name string, module config.Module, entry *lock.Module) []string {
+	var loose []string
+	if m, pkgs, err := modulePackages(module); err == nil {
+		for _, pkg := range pkgs {
+			if v := entry.Packages[m.Name()][pkg]; v == "" {
+				loose = append(loose, fmt.Sprintf("%s: %s package %s (no version locked for %s)", name, m.Name(), pkg, m.Name()))
+			} else if _, ok := m.Pin(pkg, v); !ok {
+				loose = append(loose, fmt.Sprintf("%s: %s package %s (%s cannot install version %s)", name, m.Name(), pkg, m.Name(), v))
+			}
+		}
+	}
+	if cmds, ok := moduleInstallCommands(module); ok && len(cmds) > 0 {
+		loose = append(loose, name+": install commands")
+	}
+	return loose
+}
+
+// lockedModule returns a copy of module pinned to a lock entry.
+func lockedModule(module config.Module, entry *lock.Module) config.Module {
+	if module.Git != nil && entry.Commit != "" {
+		g := *module.Git
+		g.Ref = entry.Commit
+		module.Git = &g
+	}
+	if module.Script != nil && entry.SHA256 != "" {
+		sc := *module.Script
+		sc.SHA256 = entry.SHA256
+		module.Script = &sc
+	}
+	if len(entry.Packages) > 0 {
+		packages := maps.Clone(module.Packages)
+		for manager, versions := range entry.Packages {
+			m, err := pkgmgr.New(manager)
+			if err != nil {
+				continue
+			}
+			pkgs := slices.Clone(packages[manager])
+			for i, pkg := range pkgs {
+				if spec, ok := m.Pin(pkg, versions[pkg]); ok && versions[pkg] != "" {
+					pkgs[i] = spec
+				}
+			}
+			packages[manager] = pkgs
+		}
+		module.Packages = packages
+	}
+	if v := entry.Versions["xcmd"]; module.XCmd != nil && v != "" {
+		x := *module.XCmd
+		x.Version = v
+		module.XCmd = &x
+	}
+	tools := map[string]**config.ToolSpec{"go": &module.Go, "cargo": &module.Cargo, "uv_tool": &module.UvTool, "npm": &module.Npm}
+	for kind, spec := range tools {
+		if v := entry.Versions[kind]; *spec != nil && v != "" {
+			t := **spec
+			t.Version = v
+			*spec = &t
+		}
+	}
+	return module
+}
+
+func init() {
+	rootCmd.AddCommand(lockCmd)
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/w31r4/dotm/config"
+	"github.com/w31r4/dotm/pkg/lock"
+)
+
+func TestLockAndApplyLock(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_AUTHOR_NAME", "dotm")
+	t.Setenv("GIT_AUTHOR_EMAIL", "dotm@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "dotm")
+	t.Setenv("GIT_COMMITTER_EMAIL", "dotm@example.com")
+
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "plugin")
+	for _, args := range [][]string{
+		{"init", "--initial-branch=main", repo},
+		{"-C", repo, "commit", "--allow-empty", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	out, _ := exec.Command("git", "-C", repo, "rev-parse", "HEAD").Output()
+	commit := strings.TrimSpace(string(out))
+
+	body := "echo installing\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+	sum := sha256.Sum256([]byte(body))
+
+	useFakeManager(t, "git")
+	tools := config.Module{Packages: map[string][]string{"apt": {"git", "jq"}, "brew": {"git"}}}
+	cfg := &config.Config{Modules: map[string]config.Module{
+		"tools":  tools,
+		"plugin": {Git: &config.GitSpec{Repo: repo, Dest: filepath.Join(dir, "dest"), Ref: "main"}},
+		"tool":   {Check: "command -v tool", Script: &config.ScriptSpec{URL: srv.URL + "/install.sh"}},
+		"gopls": {
+			XCmd: &config.XCmdSpec{Package: "go", Version: "1.24.5"},
+			Go:   &config.ToolSpec{Package: "golang.org/x/tools/gopls", Version: "v0.16.0"},
+		},
+	}}
+
+	lockFile := lock.New()
+	for name, module := range cfg.Modules {
+		entry, err := lockModule(name, module)
+		if err != nil {
+			t.Fatalf("lockModule(%s): %v", name, err)
+		}
+		lockFile.Modules[name] = entry
+	}
+	if got := lockFile.Modules["plugin"].Commit; got != commit {
+		t.Fatalf("plugin commit = %q, want %q", got, commit)
+	}
+	if got := lockFile.Modules["tool"].SHA256; got != hex.EncodeToString(sum[:]) {
+		t.Fatalf("tool sha256 = %q", got)
+	}
+
+	path := filepath.Join(dir, lock.FileName)
+	if err := lockFile.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := lock.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := loaded.Modules["gopls"].Versions; got["xcmd"] != "1.24.5" || got["go"] != "v0.16.0" {
+		t.Fatalf("gopls versions = %v, want one per install type", got)
+	}
+
+	if got := loaded.Modules["tools"].Packages; len(got) != 1 || got["apt"]["git"] != "1.0" || got["apt"]["jq"] != "" {
+		t.Fatalf("tools packages = %v, want apt git at 1.0", got)
+	}
+
+	plan := []string{"plugin", "tool", "gopls", "tools"}
+	if err := applyLock(cfg, plan, loaded); err != nil {
+		t.Fatalf("applyLock: %v", err)
+	}
+	if ref := cfg.Modules["plugin"].Git.Ref; ref != commit {
+		t.Fatalf("locked ref = %q, want %q", ref, commit)
+	}
+	if sha := cfg.Modules["tool"].Script.SHA256; sha != hex.EncodeToString(sum[:]) {
+		t.Fatalf("locked script sha256 = %q", sha)
+	}
+
+	if m := cfg.Modules["gopls"]; m.XCmd.Version != "1.24.5" || m.Go.Version != "v0.16.0" {
+		t.Fatalf("locked gopls versions = %q, %q", m.XCmd.Version, m.Go.Version)
+	}
+
+	if got := cfg.Modules["tools"].Packages; !slices.Equal(got["apt"], []string{"git=1.0", "jq"}) || !slices.Equal(got["brew"], []string{"git"}) {
+		t.Fatalf("locked packages = %v", got)
+	}
+	if !slices.Equal(tools.Packages["apt"], []string{"git", "jq"}) {
+		t.Fatalf("locking changed the config's packages: %v", tools.Packages)
+	}
+	if loose := unpinned("tools", tools, loaded.Modules["tools"]); !slices.Equal(loose, []string{"tools: apt package jq (no version locked for apt)"}) {
+		t.Fatalf("unpinned = %v", loose)
+	}
+
+	// A module edited after locking is no longer covered.
+	changed := &config.Config{Modules: map[string]config.Module{
+		"plugin": {Git: &config.GitSpec{Repo: repo, Dest: filepath.Join(dir, "dest"), Ref: "next"}},
+	}}
+	err = applyLock(changed, []string{"plugin"}, loaded)
+	if err == nil || !strings.Contains(err.Error(), "changed in config.yaml") {
+		t.Fatalf("applyLock after edit = %v, want a changed-module error", err)
+	}
+}
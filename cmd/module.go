@@ -5,9 +5,12 @@ import (
 	"log"
 	"os"
 	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/w31r4/dotm/config"
+	"github.com/w31r4/dotm/pkg/pkgmgr"
+	"github.com/w31r4/dotm/pkg/toolchain"
 	"gopkg.in/yaml.v3"
 )
 
@@ -40,7 +43,10 @@ var listCmd = &cobra.Command{
 var removeCmd = &cobra.Command{
 	Use:   "remove [module]",
 	Short: "Remove a module from config.yaml",
-	Args:  cobra.ExactArgs(1),
+	Long: `Remove a module from config.yaml. With --uninstall, tools installed by
+the module's go, cargo, uv_tool and npm install types are uninstalled
+first. Anything else the module installed is left in place.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		moduleName := args[0]
 		cfg, err := config.LoadConfig(configPath)
@@ -48,10 +54,17 @@ var removeCmd = &cobra.Command{
 			log.Fatalf("Error loading config from %s: %v", configPath, err)
 		}
 
-		if _, ok := cfg.Modules[moduleName]; !ok {
+		module, ok := cfg.Modules[moduleName]
+		if !ok {
 			log.Fatalf("Module '%s' not found.", moduleName)
 		}
 
+		if uninstall, _ := cmd.Flags().GetBool("uninstall"); uninstall {
+			if err := uninstallModule(moduleName, module); err != nil {
+				log.Fatalf("Failed to uninstall module '%s': %v", moduleName, err)
+			}
+		}
+
 		delete(cfg.Modules, moduleName)
 
 		data, err := yaml.Marshal(cfg)
@@ -94,25 +107,44 @@ var addCmd = &cobra.Command{
 		installMacos, _ := cmd.Flags().GetStringSlice("install-macos")
 		installArch, _ := cmd.Flags().GetStringSlice("install-arch")
 		installDefault, _ := cmd.Flags().GetStringSlice("install-default")
+		privileged, _ := cmd.Flags().GetBool("privileged")
+		packages := make(map[string][]string)
+		for _, manager := range pkgmgr.Names {
+			if pkgs, _ := cmd.Flags().GetStringSlice(manager); len(pkgs) > 0 {
+				packages[manager] = pkgs
+			}
+		}
+		tools := make(map[string]*config.ToolSpec)
+		for _, kind := range toolchain.Names {
+			if spec, _ := cmd.Flags().GetString(toolFlag(kind)); spec != "" {
+				tools[kind] = parseToolSpec(spec)
+			}
+		}
 
 		newModule := config.Module{
 			Description:  desc,
 			Check:        check,
 			Dependencies: deps,
-			Install:      make(map[string][]string),
+			Install:      make(map[string][]config.Command),
+			Privileged:   privileged,
+			Packages:     packages,
+			Go:           tools["go"],
+			Cargo:        tools["cargo"],
+			UvTool:       tools["uv_tool"],
+			Npm:          tools["npm"],
 		}
 
 		if len(installDebian) > 0 {
-			newModule.Install["debian"] = installDebian
+			newModule.Install["debian"] = config.Commands(installDebian...)
 		}
 		if len(installMacos) > 0 {
-			newModule.Install["macos"] = installMacos
+			newModule.Install["macos"] = config.Commands(installMacos...)
 		}
 		if len(installArch) > 0 {
-			newModule.Install["arch"] = installArch
+			newModule.Install["arch"] = config.Commands(installArch...)
 		}
 		if len(installDefault) > 0 {
-			newModule.Install["default"] = installDefault
+			newModule.Install["default"] = config.Commands(installDefault...)
 		}
 
 		cfg.Modules[moduleName] = newModule
@@ -129,12 +161,46 @@ var addCmd = &cobra.Command{
 	},
 }
 
+// uninstallModule removes what the module's install types can uninstall.
+func uninstallModule(name string, module config.Module) error {
+	session, err := newModuleSession(name, module, false)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	for _, inst := range moduleInstallers(name, module) {
+		if u, ok := inst.(uninstaller); ok {
+			fmt.Printf("Uninstalling %s (%s)...\n", name, inst.kind())
+			if err := u.uninstall(session); err != nil {
+				return fmt.Errorf("%s: %w", inst.kind(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// toolFlag is the `module add` flag for a toolchain install type.
+func toolFlag(kind string) string {
+	return strings.ReplaceAll(kind, "_", "-")
+}
+
+// parseToolSpec parses "package" or "package@version". A leading "@" is
+// part of the package name, as in "@biomejs/biome@1.9.4".
+func parseToolSpec(s string) *config.ToolSpec {
+	if i := strings.LastIndex(s, "@"); i > 0 {
+		return &config.ToolSpec{Package: s[:i], Version: s[i+1:]}
+	}
+	return &config.ToolSpec{Package: s}
+}
+
 func init() {
 	rootCmd.AddCommand(moduleCmd)
 	moduleCmd.AddCommand(listCmd)
 	moduleCmd.AddCommand(removeCmd)
 	moduleCmd.AddCommand(addCmd)
 
+	removeCmd.Flags().Bool("uninstall", false, "Uninstall the module's go, cargo, uv_tool and npm tools before removing it")
+
 	// Flags for the 'add' command
 	addCmd.Flags().String("description", "", "Module description")
 	addCmd.Flags().String("check", "", "Command to check if the module is installed")
@@ -143,4 +209,11 @@ func init() {
 	addCmd.Flags().StringSlice("install-macos", []string{}, "Install command(s) for macOS")
 	addCmd.Flags().StringSlice("install-arch", []string{}, "Install command(s) for Arch Linux")
 	addCmd.Flags().StringSlice("install-default", []string{}, "Default install command(s)")
+	for _, manager := range pkgmgr.Names {
+		addCmd.Flags().StringSlice(manager, []string{}, fmt.Sprintf("Package(s) to install with %s", manager))
+	}
+	for _, kind := range toolchain.Names {
+		addCmd.Flags().String(toolFlag(kind), "", fmt.Sprintf("Tool to install with the %s install type, as package or package@version", kind))
+	}
+	addCmd.Flags().Bool("privileged", false, "Install commands need root (run directly as root, otherwise through sudo)")
 }
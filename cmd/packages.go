@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/w31r4/dotm/config"
+	"github.com/w31r4/dotm/pkg/executor"
+	"github.com/w31r4/dotm/pkg/pkgmgr"
+)
+
+// packageManager is the system package manager for the current run. It is
+// detected on first use and shared so per-run state (such as the apt index
+// refresh) is kept across modules.
+var packageManager pkgmgr.Manager
+
+// batchedModules records modules whose packages were installed by the
+// up-front batch in this run.
+var batchedModules = make(map[string]bool)
+
+func currentPackageManager() (pkgmgr.Manager, error) {
+	if packageManager != nil {
+		return packageManager, nil
+	}
+	m, err := pkgmgr.Detect()
+	if err != nil {
+		return nil, err
+	}
+	packageManager = m
+	return packageManager, nil
+}
+
+// modulePackages returns the package manager and the packages a module
+// declares for it. Both are empty when the module declares no packages for
+// the manager available on this machine.
+func modulePackages(module config.Module) (pkgmgr.Manager, []string, error) {
+	if len(module.Packages) == 0 {
+		return nil, nil, nil
+	}
+	m, err := currentPackageManager()
+	if err != nil {
+		return nil, nil, err
+	}
+	pkgs := module.Packages[m.Name()]
+	if len(pkgs) == 0 {
+		return nil, nil, nil
+	}
+	return m, pkgs, nil
+}
+
+// batchInstallPackages installs the missing packages of the plan in a
+// single package manager invocation. Only modules without a custom check
+// whose dependencies are all installed without commands take part, so the
+// batch never runs ahead of a setup step (such as adding an apt
+// repository) it might rely on. A failed batch is not fatal: the modules
+// are retried one by one afterwards.
+func batchInstallPackages(cfg *config.Config, plan []string, dryRun bool) {
+	m, err := currentPackageManager()
+	if err != nil {
+		return
+	}
+
+	var modules, batch []string
+	seen := make(map[string]bool)
+	for _, name := range plan {
+		module := cfg.Modules[name]
+		pkgs := module.Packages[m.Name()]
+		if len(pkgs) == 0 || module.Check != "" || dependsOnCommands(cfg, name) {
+			continue
+		}
+		if entry := journalEntry(name, module); entry.CheckPassed || entry.Installed {
+			continue
+		}
+		missing, err := pkgmgr.Missing(m, pkgs)
+		if err != nil {
+			fmt.Printf("Warning: could not query %s packages for %s: %v\n", m.Name(), name, err)
+			continue
+		}
+		if len(missing) > 0 {
+			modules = append(modules, name)
+		}
+		for _, pkg := range missing {
+			if !seen[pkg] {
+				seen[pkg] = true
+				batch = append(batch, pkg)
+			}
+		}
+	}
+	if len(batch) == 0 {
+		return
+	}
+
+	fmt.Printf("--- Installing %s packages for %s ---\n", m.Name(), strings.Join(modules, ", "))
+	session, err := executor.NewSession(executor.SessionOptions{Name: "packages", DryRun: dryRun, Privilege: privilege})
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		return
+	}
+	defer session.Close()
+	if err := m.Install(session, batch); err != nil {
+		fmt.Printf("Warning: batch install failed, retrying modules individually: %v\n", err)
+		return
+	}
+	for _, name := range modules {
+		batchedModules[name] = true
+	}
+}
+
+// dependsOnCommands reports whether any transitive dependency of a module
+// runs install commands on this OS.
+func dependsOnCommands(cfg *config.Config, name string) bool {
+	visited := make(map[string]bool)
+	var visit func(string) bool
+	visit = func(n string) bool {
+		for _, dep := range moduleDependencies(cfg.Modules, n, cfg.Modules[n]) {
+			if visited[dep] {
+				continue
+			}
+			visited[dep] = true
+			if cmds, ok := moduleInstallCommands(cfg.Modules[dep]); ok && len(cmds) > 0 {
+				return true
+			}
+			if visit(dep) {
+				return true
+			}
+		}
+		return false
+	}
+	return visit(name)
+}
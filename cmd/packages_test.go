@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/w31r4/dotm/config"
+	"github.com/w31r4/dotm/pkg/executor"
+)
+
+// fakeManager is an in-memory package manager.
+type fakeManager struct {
+	installed map[string]bool
+	outdated  map[string]bool
+	calls     [][]string
+	upgraded  []string
+}
+
+func (f *fakeManager) Name() string     { return "apt" }
+func (f *fakeManager) Privileged() bool { return false }
+
+func (f *fakeManager) Installed(pkg string) (bool, error) { return f.installed[pkg], nil }
+
+// Version reports 1.0 for every installed package.
+func (f *fakeManager) Version(pkg string) (string, error) {
+	if f.installed[pkg] {
+		return "1.0", nil
+	}
+	return "", nil
+}
+
+func (f *fakeManager) Pin(pkg, version string) (string, bool) { return pkg + "=" + version, true }
+
+func (f *fakeManager) Install(_ *executor.Session, pkgs []string) error {
+	f.calls = append(f.calls, pkgs)
+	for _, pkg := range pkgs {
+		f.installed[pkg] = true
+	}
+	return nil
+}
+
+func (f *fakeManager) Upgrade(_ *executor.Session, pkgs []string) error {
+	f.upgraded = append(f.upgraded, pkgs...)
+	return nil
+}
+
+func (f *fakeManager) Outdated(pkgs []string) ([]string, error) {
+	var result []string
+	for _, pkg := range pkgs {
+		if f.outdated[pkg] {
+			result = append(result, pkg)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeManager) Remove(_ *executor.Session, pkgs []string) error { return nil }
+
+func useFakeManager(t *testing.T, installed ...string) *fakeManager {
+	t.Helper()
+	f := &fakeManager{installed: make(map[string]bool), outdated: make(map[string]bool)}
+	for _, pkg := range installed {
+		f.installed[pkg] = true
+	}
+	packageManager = f
+	t.Cleanup(func() { packageManager = nil })
+	return f
+}
+
+func TestBatchInstallPackages(t *testing.T) {
+	resetInstallRun(t)
+	f := useFakeManager(t, "zsh")
+	cfg := &config.Config{Modules: map[string]config.Module{
+		"git":     {Packages: map[string][]string{"apt": {"git"}}},
+		"zsh":     {Packages: map[string][]string{"apt": {"zsh"}}},
+		"ripgrep": {Dependencies: []string{"git"}, Packages: map[string][]string{"apt": {"ripgrep"}}},
+		"repo":    {Install: map[string][]config.Command{"default": config.Commands("true")}},
+		"eza":     {Dependencies: []string{"repo"}, Packages: map[string][]string{"apt": {"eza"}}},
+	}}
+	names := []string{"zsh", "ripgrep", "eza"}
+
+	batchInstallPackages(cfg, resolvePlan(cfg, names), false)
+
+	if len(f.calls) != 1 || !slices.Equal(f.calls[0], []string{"git", "ripgrep"}) {
+		t.Fatalf("batch calls = %v, want one call with [git ripgrep]", f.calls)
+	}
+
+	for _, name := range names {
+		if err := installModule(name, cfg, false); err != nil {
+			t.Fatalf("installModule(%s): %v", name, err)
+		}
+	}
+	if len(f.calls) != 2 || !slices.Equal(f.calls[1], []string{"eza"}) {
+		t.Fatalf("calls = %v, want eza installed on its own after its dependency", f.calls)
+	}
+	want := map[string]installStatus{"zsh": statusPresent, "git": statusInstalled, "ripgrep": statusInstalled, "eza": statusInstalled}
+	for name, status := range want {
+		if got := installResults[name].Status; got != status {
+			t.Errorf("%s: got %q, want %q", name, got, status)
+		}
+	}
+}
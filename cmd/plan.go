@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/w31r4/dotm/config"
+	"github.com/w31r4/dotm/pkg/executor"
+	"github.com/w31r4/dotm/pkg/pkgmgr"
+	"github.com/w31r4/dotm/pkg/toolchain"
+	"github.com/w31r4/dotm/pkg/xcmd"
+)
+
+// resolvePlan returns the requested modules together with their
+// dependencies, dependencies first. Unknown modules are left out; the
+// install step reports them.
+func resolvePlan(cfg *config.Config, names []string) []string {
+	var plan []string
+	visited := make(map[string]bool)
+
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		module, ok := cfg.Modules[name]
+		if !ok {
+			return
+		}
+		for _, dep := range moduleDependencies(cfg.Modules, name, module) {
+			visit(dep)
+		}
+		plan = append(plan, name)
+	}
+
+	for _, name := range names {
+		visit(name)
+	}
+	return plan
+}
+
+// moduleDependencies returns a module's dependencies: the ones it lists,
+// followed by the modules that provide the toolchains its `go:`, `cargo:`,
+// `uv_tool:` and `npm:` install types need, when config.yaml defines them.
+func moduleDependencies(modules map[string]config.Module, name string, module config.Module) []string {
+	deps := module.Dependencies
+	tools := module.Tools()
+	for _, kind := range toolchain.Names {
+		if _, ok := tools[kind]; !ok {
+			continue
+		}
+		backend, _ := toolchain.New(kind)
+		provider := backend.Toolchain()
+		if _, ok := modules[provider]; !ok || provider == name || slices.Contains(deps, provider) {
+			continue
+		}
+		deps = append(slices.Clip(deps), provider)
+	}
+	if module.XCmd != nil {
+		provider := xcmd.ProviderModule
+		if _, ok := modules[provider]; ok && provider != name && !slices.Contains(deps, provider) {
+			deps = append(slices.Clip(deps), provider)
+		}
+	}
+	return deps
+}
+
+// moduleInstallCommands returns the install commands for the current OS,
+// falling back to "default". The boolean is false when neither exists.
+func moduleInstallCommands(module config.Module) ([]config.Command, bool) {
+	if cmds, ok := module.Install[executor.GetOS()]; ok {
+		return cmds, true
+	}
+	// If no specific command for the OS, check for a "default"
+	cmds, ok := module.Install["default"]
+	return cmds, ok
+}
+
+// moduleUpdateCommands returns the update commands for the current OS,
+// falling back to "default".
+func moduleUpdateCommands(module config.Module) []config.Command {
+	if cmds, ok := module.Update[executor.GetOS()]; ok {
+		return cmds
+	}
+	return module.Update["default"]
+}
+
+// isPrivileged reports whether a command of the module must run as root.
+func isPrivileged(module config.Module, cmd config.Command) bool {
+	return module.Privileged || cmd.Privileged
+}
+
+// privilegedStep is an install command that needs root.
+type privilegedStep struct {
+	Module  string
+	Command string
+}
+
+// privilegedSteps lists every install command in the plan that needs root.
+func privilegedSteps(cfg *config.Config, plan []string) []privilegedStep {
+	var steps []privilegedStep
+	for _, name := range plan {
+		module := cfg.Modules[name]
+		if m, pkgs, err := modulePackages(module); err == nil && len(pkgs) > 0 && m.Privileged() {
+			if missing, err := pkgmgr.Missing(m, pkgs); err != nil || len(missing) > 0 {
+				steps = append(steps, privilegedStep{Module: name, Command: m.Name() + " install " + strings.Join(pkgs, " ")})
+			}
+		}
+		if module.Script != nil && module.Script.Privileged {
+			steps = append(steps, privilegedStep{Module: name, Command: "script " + module.Script.URL})
+		}
+		cmds, _ := moduleInstallCommands(module)
+		for _, cmd := range cmds {
+			if isPrivileged(module, cmd) {
+				steps = append(steps, privilegedStep{Module: name, Command: cmd.Run})
+			}
+		}
+	}
+	return steps
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// promptInput is where interactive answers are read from.
+var promptInput io.Reader = os.Stdin
+
+var promptReader *bufio.Reader
+
+// prompt prints a question and returns the trimmed answer.
+func prompt(question string) string {
+	if promptReader == nil {
+		promptReader = bufio.NewReader(promptInput)
+	}
+	fmt.Print(question)
+	answer, _ := promptReader.ReadString('\n')
+	return strings.TrimSpace(answer)
+}
+
+// confirm asks a yes/no question; anything but "y" or "yes" means no.
+func confirm(question string) bool {
+	switch strings.ToLower(prompt(question + " [y/N] ")) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
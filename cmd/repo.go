@@ -11,6 +11,10 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/w31r4/dotm/config"
+	"github.com/w31r4/dotm/pkg/backup"
+	"github.com/w31r4/dotm/pkg/dotrepo"
+	"github.com/w31r4/dotm/pkg/state"
 )
 
 var repoCmd = &cobra.Command{
@@ -21,67 +25,176 @@ var repoCmd = &cobra.Command{
 var syncCmd = &cobra.Command{
 	Use:   "sync",
 	Short: "Clone dotfiles repo and checkout files",
+	Long: `Clone the dotfiles repository as a bare repo (if it does not exist yet)
+and check it out into the work tree, backing up files that are in the way.
+
+Settings come from flags, then environment variables, then the repo:
+section of config.yaml, then the defaults:
+
+  --url         DOTM_DOTFILES_URL         repo.url         (none)
+  --dir         DOTM_DOTFILES_DIR         repo.dir         ~/.dotfiles
+  --backup-dir  DOTM_DOTFILES_BACKUP_DIR  repo.backup_dir  ~/.dotfiles-backup
+  --branch      DOTM_DOTFILES_BRANCH      repo.branch      the remote's default
+  --work-tree   DOTM_DOTFILES_WORK_TREE   repo.work_tree   ~
+  --backend     DOTM_DOTFILES_BACKEND     repo.backend     cli
+
+The backend is "cli", which runs the git command, or "go-git", a pure-Go
+implementation for machines without git. Both find the files in the way of
+a checkout or pull before changing anything.
+
+Files in the way of the checkout or pull are handled by --strategy:
+"backup" (default) moves them into a backup snapshot, "ours" keeps the
+local version and commits it, and "theirs" does the same as "backup" but
+names each file and its snapshot. --interactive shows
+the diff for each file and asks whether to keep the local version, take
+the repo's, merge the two with $MERGETOOL or skip it for now.
+
+--all syncs every repository in the repos: list of config.yaml, lowest
+priority first, into the same work tree. A repository that tracks a file
+already checked out by an earlier one is reported and skipped instead of
+overwriting it.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		repoURL, _ := cmd.Flags().GetString("url")
-		dotfilesDir, _ := cmd.Flags().GetString("dir")
-		backupBaseDir, _ := cmd.Flags().GetString("backup-dir")
 		pullLatest, _ := cmd.Flags().GetBool("pull")
-
-		homeDir, err := os.UserHomeDir()
+		strategy, err := conflictStrategy(cmd)
 		if err != nil {
-			return fmt.Errorf("get home dir: %w", err)
+			return err
 		}
-
-		dotfilesDir, err = expandHomePath(dotfilesDir, homeDir)
-		if err != nil {
-			return fmt.Errorf("invalid --dir: %w", err)
+		if all, _ := cmd.Flags().GetBool("all"); all {
+			return syncAll(cmd, strategy, pullLatest)
 		}
-		backupBaseDir, err = expandHomePath(backupBaseDir, homeDir)
+		settings, err := loadRepoSettings(cmd)
 		if err != nil {
-			return fmt.Errorf("invalid --backup-dir: %w", err)
+			return err
 		}
+		return syncRepo(settings, strategy, pullLatest, nil)
+	},
+}
 
-		// 1) Clone the bare repository (if needed)
-		if _, err := os.Stat(dotfilesDir); errors.Is(err, os.ErrNotExist) {
-			fmt.Println("Cloning dotfiles bare repository...")
-			if _, err := runCommand(dryRun, "git", "clone", "--bare", repoURL, dotfilesDir); err != nil {
-				return fmt.Errorf("failed to clone repo: %w", err)
-			}
-		} else if err != nil {
-			return fmt.Errorf("stat dotfiles dir: %w", err)
-		} else {
-			fmt.Println("Dotfiles repository already exists. Skipping clone.")
-		}
+// syncRepo clones (if needed), checks out and pulls the repository the
+// settings describe. claim, if not nil, is called with the files of the
+// branch before the checkout, and with the files of the fetched upstream
+// before the pull, and can veto either.
+func syncRepo(settings *repoSettings, strategy string, pullLatest bool, claim func(files []string) error) error {
+	dotfilesDir := settings.dir
+	backend, err := dotrepo.New(settings.backend)
+	if err != nil {
+		return err
+	}
 
-		// 2) Keep the dotfiles repo readable by hiding home-directory untracked files.
-		if _, err := runCommand(dryRun, "git", "--git-dir="+dotfilesDir, "config", "status.showUntrackedFiles", "no"); err != nil {
-			return fmt.Errorf("failed to configure dotfiles repo: %w", err)
+	// 1) Clone the bare repository (if needed)
+	if _, err := os.Stat(dotfilesDir); errors.Is(err, os.ErrNotExist) {
+		if settings.url == "" {
+			return fmt.Errorf("no dotfiles repo at %s and no URL to clone it from (%s)", dotfilesDir, settings.urlHint())
+		}
+		if dryRun {
+			fmt.Printf("[DRY RUN] Would clone %s into %s and check it out into %s\n", settings.url, dotfilesDir, settings.workTree)
+			return nil
+		}
+		fmt.Printf("Cloning dotfiles bare repository (%s backend)...\n", backend.Name())
+		if err := backend.Clone(settings.url, dotfilesDir, settings.branch); err != nil {
+			return fmt.Errorf("failed to clone repo: %w", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("stat dotfiles dir: %w", err)
+	} else {
+		fmt.Println("Dotfiles repository already exists. Skipping clone.")
+	}
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would check out %s into %s, backing up conflicting files to %s\n", dotfilesDir, settings.workTree, settings.backupDir)
+		if pullLatest {
+			fmt.Println("[DRY RUN] Would pull latest changes (fast-forward only)")
 		}
+		return nil
+	}
+
+	if err := os.MkdirAll(settings.workTree, 0755); err != nil {
+		return fmt.Errorf("create work tree: %w", err)
+	}
+	repo, err := backend.Open(dotfilesDir, settings.workTree)
+	if err != nil {
+		return err
+	}
+
+	// 2) Keep the dotfiles repo readable by hiding home-directory untracked files.
+	if err := repo.SetConfig("status.showUntrackedFiles", "no"); err != nil {
+		return fmt.Errorf("failed to configure dotfiles repo: %w", err)
+	}
 
-		// 3) Checkout (with conflict backup)
-		fmt.Println("Checking out dotfiles...")
-		backupDir, err := checkoutWithBackup(dotfilesDir, homeDir, backupBaseDir, dryRun)
+	// 3) Track origin's branches so `repo status` can report ahead/behind
+	// counts. Bare clones do not set up remote-tracking branches.
+	if err := repo.TrackUpstream(); err != nil {
+		return fmt.Errorf("failed to configure upstream: %w", err)
+	}
+
+	if claim != nil {
+		files, err := repo.Files(settings.branch)
 		if err != nil {
 			return err
 		}
-		if backupDir != "" {
-			fmt.Printf("Backed up conflicting files to: %s\n", backupDir)
+		if err := claim(files); err != nil {
+			return fmt.Errorf("not checked out: %w", err)
 		}
+	}
 
-		// 4) Update repo (fast-forward only) and re-checkout if needed.
-		if pullLatest {
-			fmt.Println("Pulling latest changes...")
-			pullBackupDir, err := pullWithBackup(dotfilesDir, homeDir, backupBaseDir, dryRun)
+	// 4) Checkout (with conflict backup)
+	fmt.Println("Checking out dotfiles...")
+	sync := &syncer{repo: repo, gitDir: dotfilesDir, workTree: settings.workTree, backupBaseDir: settings.backupDir, strategy: strategy}
+	backupDir, err := sync.checkout(settings.branch)
+	if err != nil {
+		return err
+	}
+	if backupDir != "" {
+		fmt.Printf("Backed up conflicting files to: %s\n", backupDir)
+	}
+
+	// 5) Update repo (fast-forward only).
+	if pullLatest {
+		fmt.Println("Pulling latest changes...")
+		if claim != nil {
+			if err := repo.Fetch(); err != nil {
+				return err
+			}
+			files, err := repo.UpstreamFiles()
 			if err != nil {
 				return err
 			}
-			if pullBackupDir != "" {
-				fmt.Printf("Backed up conflicting files to: %s\n", pullBackupDir)
+			if err := claim(files); err != nil {
+				return fmt.Errorf("not pulled: %w", err)
 			}
 		}
+		pullBackupDir, err := sync.pull()
+		if err != nil {
+			return err
+		}
+		if pullBackupDir != "" {
+			fmt.Printf("Backed up conflicting files to: %s\n", pullBackupDir)
+		}
+	}
 
-		return nil
-	},
+	// 6) Decrypt encrypted dotfiles into place. A machine without the
+	// key yet still gets everything else.
+	if err := decryptSecrets(&dotfiles{gitDir: dotfilesDir, workTree: settings.workTree}, settings, false); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	if err := recordSync(dotfilesDir); err != nil {
+		fmt.Printf("Warning: could not record sync time: %v\n", err)
+	}
+	return nil
+}
+
+// recordSync stores the time of a completed sync for `repo status`.
+func recordSync(dotfilesDir string) error {
+	path, err := state.ReposPath()
+	if err != nil {
+		return err
+	}
+	repos, err := state.LoadRepos(path)
+	if err != nil {
+		return err
+	}
+	repos.Repo(dotfilesDir).LastSync = time.Now()
+	return repos.Save()
 }
 
 var repoGitCmd = &cobra.Command{
@@ -95,34 +208,224 @@ Examples:
   dotm repo git status
   dotm repo git add .zshrc
   dotm repo git commit -m "Update zsh config"
-
-By default, the repo is assumed to be at ~/.dotfiles. Override with DOTM_DOTFILES_DIR.`,
+  dotm repo git --repo work log --oneline
+
+The repository and work tree come from DOTM_DOTFILES_DIR and
+DOTM_DOTFILES_WORK_TREE, or the repo: section of config.yaml, and default
+to ~/.dotfiles and ~. A leading --repo NAME (or DOTM_DOTFILES_REPO) selects
+an entry of the repos: list instead. All other arguments are passed to git,
+so the --dir and --work-tree flags of the other repo commands are not
+available here.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		homeDir, err := os.UserHomeDir()
+		name, args, err := splitRepoFlag(args)
 		if err != nil {
-			return fmt.Errorf("get home dir: %w", err)
+			return err
+		}
+		if name == "" {
+			name = os.Getenv("DOTM_DOTFILES_REPO")
+		}
+		settings, err := loadNamedRepoSettings(nil, name)
+		if err != nil {
+			return err
 		}
+		repo, err := settings.open()
+		if err != nil {
+			return err
+		}
+		_, err = runCommandInDir(false, repo.workTree, "git", repo.gitArgs(args...)...)
+		return err
+	},
+}
+
+// dotfiles is the bare dotfiles repository and the work tree (the home
+// directory) it checks out into.
+type dotfiles struct {
+	gitDir   string
+	workTree string
+}
 
-		dotfilesDir := os.Getenv("DOTM_DOTFILES_DIR")
-		if dotfilesDir == "" {
-			dotfilesDir = "~/.dotfiles"
+// repoSettings are the dotfiles repository settings after applying, in
+// order of precedence, command-line flags, environment variables, the repo:
+// section of config.yaml and the defaults. Paths are expanded.
+type repoSettings struct {
+	// name is the repos: entry, or "" for the repo: section.
+	name      string
+	url       string
+	dir       string
+	backupDir string
+	branch    string
+	workTree  string
+	// backend is the dotrepo backend sync uses.
+	backend string
+	// deny are the extra patterns from repo.deny that `repo track` refuses.
+	deny []string
+	// encryption is repo.encryption, or nil when it is not configured.
+	encryption *config.EncryptionSpec
+	// configPath is the config file the repo: section was read from.
+	configPath string
+}
+
+// loadRepoSettings resolves the repository settings for cmd. Only flags
+// that cmd defines are consulted; cmd may be nil to skip flags entirely.
+// The repository is the repos: entry named by --repo or
+// DOTM_DOTFILES_REPO, or the repo: section when neither is set.
+// A missing config file is not an error, since `repo sync` usually runs
+// before a machine has one.
+func loadRepoSettings(cmd *cobra.Command) (*repoSettings, error) {
+	name := ""
+	if cmd != nil {
+		name, _ = cmd.Flags().GetString("repo")
+	}
+	if name == "" {
+		name = os.Getenv("DOTM_DOTFILES_REPO")
+	}
+	return loadNamedRepoSettings(cmd, name)
+}
+
+// loadNamedRepoSettings is loadRepoSettings for the repos: entry called
+// name, or for the repo: section when name is empty. A named repository's
+// URL, directory and branch come only from flags and its entry; its other
+// settings fall back to the environment and the repo: section as usual.
+func loadNamedRepoSettings(cmd *cobra.Command, name string) (*repoSettings, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("get home dir: %w", err)
+	}
+
+	path := configPath
+	if path == "" {
+		path = "config.yaml"
+	}
+	spec := &config.RepoSpec{}
+	cfg, err := config.LoadConfig(path)
+	if err == nil {
+		if cfg.Repo != nil {
+			spec = cfg.Repo
 		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("load %s: %w", path, err)
+	}
 
-		dotfilesDir, err = expandHomePath(dotfilesDir, homeDir)
-		if err != nil {
-			return fmt.Errorf("invalid DOTM_DOTFILES_DIR: %w", err)
+	field := "repo."
+	urlEnv, dirEnv, branchEnv, defaultDir := "DOTM_DOTFILES_URL", "DOTM_DOTFILES_DIR", "DOTM_DOTFILES_BRANCH", "~/.dotfiles"
+	if name != "" {
+		if cfg == nil {
+			return nil, fmt.Errorf("no repository named %q: %s does not exist", name, path)
+		}
+		entry, ok := cfg.FindRepo(name)
+		if !ok {
+			return nil, fmt.Errorf("no repository named %q in the repos: list of %s (configured: %s)", name, path, repoNames(cfg))
 		}
+		spec = namedRepoSpec(spec, entry)
+		field = "repos." + name + "."
+		urlEnv, dirEnv, branchEnv, defaultDir = "", "", "", "~/.dotfiles-"+name
+	}
 
-		if _, err := os.Stat(dotfilesDir); errors.Is(err, os.ErrNotExist) {
-			return fmt.Errorf("dotfiles repo not found at %s (run `dotm repo sync` first)", dotfilesDir)
-		} else if err != nil {
-			return fmt.Errorf("stat dotfiles dir: %w", err)
+	s := &repoSettings{name: name, deny: spec.Deny, encryption: spec.Encryption, configPath: path}
+	for _, setting := range []struct {
+		value            *string
+		flag, env, field string
+		configured, def  string
+		isPath           bool
+	}{
+		{&s.url, "url", urlEnv, "url", spec.URL, "", false},
+		{&s.dir, "dir", dirEnv, "dir", spec.Dir, defaultDir, true},
+		{&s.backupDir, "backup-dir", "DOTM_DOTFILES_BACKUP_DIR", "backup_dir", spec.BackupDir, "~/.dotfiles-backup", true},
+		{&s.branch, "branch", branchEnv, "branch", spec.Branch, "", false},
+		{&s.workTree, "work-tree", "DOTM_DOTFILES_WORK_TREE", "work_tree", spec.WorkTree, "~", true},
+		{&s.backend, "backend", "DOTM_DOTFILES_BACKEND", "backend", spec.Backend, dotrepo.CLI, false},
+	} {
+		value, source := setting.def, "default"
+		if cmd != nil && cmd.Flags().Changed(setting.flag) {
+			value, _ = cmd.Flags().GetString(setting.flag)
+			source = "--" + setting.flag
+		} else if env := os.Getenv(setting.env); setting.env != "" && env != "" {
+			value, source = env, setting.env
+		} else if setting.configured != "" {
+			value, source = setting.configured, field+setting.field+" in "+path
+		}
+		if setting.isPath {
+			if value, err = expandHomePath(value, homeDir); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", source, err)
+			}
 		}
+		*setting.value = value
+	}
+	return s, nil
+}
 
-		gitArgs := append([]string{"--git-dir=" + dotfilesDir, "--work-tree=" + homeDir}, args...)
-		_, err = runCommandInDir(false, homeDir, "git", gitArgs...)
-		return err
-	},
+// urlHint says where the URL of the repository can be set.
+func (s *repoSettings) urlHint() string {
+	if s.name != "" {
+		return fmt.Sprintf("pass --url or add a url to the %s entry of repos: in %s", s.name, s.configPath)
+	}
+	return fmt.Sprintf("pass --url, set DOTM_DOTFILES_URL or add repo.url to %s", s.configPath)
+}
+
+// namedRepoSpec fills the settings a repos: entry leaves empty from the
+// repo: section. URL, directory and branch are never shared.
+func namedRepoSpec(base *config.RepoSpec, entry *config.NamedRepo) *config.RepoSpec {
+	spec := entry.RepoSpec
+	if spec.BackupDir == "" {
+		spec.BackupDir = base.BackupDir
+	}
+	if spec.WorkTree == "" {
+		spec.WorkTree = base.WorkTree
+	}
+	if spec.Backend == "" {
+		spec.Backend = base.Backend
+	}
+	if spec.Encryption == nil {
+		spec.Encryption = base.Encryption
+	}
+	spec.Deny = append(slices.Clone(base.Deny), spec.Deny...)
+	return &spec
+}
+
+// openDotfiles locates the dotfiles repository from the settings for cmd
+// (see loadRepoSettings). It fails if the repository does not exist.
+func openDotfiles(cmd *cobra.Command) (*dotfiles, error) {
+	settings, err := loadRepoSettings(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return settings.open()
+}
+
+// open returns the repository the settings point to, failing if it does
+// not exist.
+func (s *repoSettings) open() (*dotfiles, error) {
+	if _, err := os.Stat(s.dir); errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("dotfiles repo not found at %s (run `dotm repo sync` first)", s.dir)
+	} else if err != nil {
+		return nil, fmt.Errorf("stat dotfiles dir: %w", err)
+	}
+	return &dotfiles{gitDir: s.dir, workTree: s.workTree}, nil
+}
+
+// gitArgs prefixes git arguments with the repository and work tree.
+func (d *dotfiles) gitArgs(args ...string) []string {
+	prefix := []string{"--git-dir=" + d.gitDir}
+	if d.workTree != "" {
+		prefix = append(prefix, "--work-tree="+d.workTree)
+	}
+	return append(prefix, args...)
+}
+
+// output runs a read-only git command quietly and returns its trimmed
+// stdout.
+func (d *dotfiles) output(args ...string) (string, error) {
+	command := exec.Command("git", d.gitArgs(args...)...)
+	command.Dir = d.workTree
+	out, err := command.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+	}
+	return strings.TrimRight(string(out), "\n"), nil
 }
 
 func expandHomePath(path, homeDir string) (string, error) {
@@ -170,78 +473,120 @@ func formatCommand(name string, args []string) string {
 	return name + " " + strings.Join(args, " ")
 }
 
-func checkoutWithBackup(dotfilesDir, homeDir, backupBaseDir string, dryRun bool) (string, error) {
-	return backupAndRetry(dotfilesDir, homeDir, backupBaseDir, dryRun, []string{"checkout"})
+// syncer runs the checkout and pull of repo sync, clearing the files in
+// their way.
+type syncer struct {
+	repo          dotrepo.Repository
+	gitDir        string
+	workTree      string
+	backupBaseDir string
+	// strategy is one of conflictStrategies, or strategyAsk.
+	strategy string
+}
+
+// checkout checks out branch, or the current branch when it is empty.
+func (s *syncer) checkout(branch string) (string, error) {
+	return s.run("checkout", func() error { return s.repo.Checkout(branch) })
 }
 
-func pullWithBackup(dotfilesDir, homeDir, backupBaseDir string, dryRun bool) (string, error) {
-	return backupAndRetry(dotfilesDir, homeDir, backupBaseDir, dryRun, []string{"pull", "--ff-only"})
+func (s *syncer) pull() (string, error) {
+	return s.run("pull", s.repo.Pull)
 }
 
-func backupAndRetry(dotfilesDir, homeDir, backupBaseDir string, dryRun bool, gitArgs []string) (string, error) {
+// run runs op, moving the files it reports in the way into a backup
+// snapshot and trying again.
+// Once op succeeds the moved files are resolved according to the
+// strategy. It returns the snapshot directory, or "" if nothing was
+// backed up.
+func (s *syncer) run(op string, run func() error) (string, error) {
 	const maxAttempts = 5
 	backupDir := ""
 	var lastErr error
+	var manifest *backup.Manifest
+	var cleared []dotrepo.Conflict
 
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		args := append([]string{"--git-dir=" + dotfilesDir, "--work-tree=" + homeDir}, gitArgs...)
-		out, err := runCommand(dryRun, "git", args...)
+		err := run()
 		if err == nil {
-			return backupDir, nil
+			if backupDir == "" {
+				return "", nil
+			}
+			return backupDir, s.resolve(backupDir, cleared)
 		}
 		lastErr = err
 
-		conflicts := parseOverwrittenFilePaths(out)
+		conflicts := dotrepo.Conflicts(err)
 		if len(conflicts) == 0 {
-			return backupDir, fmt.Errorf("git %s failed: %w", strings.Join(gitArgs, " "), err)
+			return backupDir, fmt.Errorf("git %s failed: %w", op, err)
 		}
-
 		if backupDir == "" {
-			backupDir = filepath.Join(backupBaseDir, time.Now().Format("20060102-150405.000000000"))
+			now := time.Now()
+			manifest = &backup.Manifest{
+				ID:        backup.NewID(now),
+				CreatedAt: now,
+				Operation: op,
+				GitDir:    s.gitDir,
+				WorkTree:  s.workTree,
+			}
+			backupDir = filepath.Join(s.backupBaseDir, manifest.ID)
 		}
 
-		if err := backupPaths(homeDir, backupDir, conflicts, dryRun); err != nil {
+		paths := make([]string, len(conflicts))
+		for i, c := range conflicts {
+			paths[i] = c.Path
+		}
+		moved, err := backupPaths(s.workTree, backupDir, paths, false)
+		if err != nil {
 			return backupDir, err
 		}
+		manifest.Files = append(manifest.Files, moved...)
+		if err := backup.WriteManifest(backupDir, manifest); err != nil {
+			return backupDir, fmt.Errorf("write backup manifest: %w", err)
+		}
+		cleared = append(cleared, conflicts...)
 	}
 
-	return backupDir, fmt.Errorf("git %s failed after %d attempts: %w", strings.Join(gitArgs, " "), maxAttempts, lastErr)
+	return backupDir, fmt.Errorf("git %s failed after %d attempts: %w", op, maxAttempts, lastErr)
 }
 
-func backupPaths(homeDir, backupDir string, paths []string, dryRun bool) error {
+// backupPaths moves paths (relative to homeDir) into backupDir and returns
+// the ones that existed.
+func backupPaths(homeDir, backupDir string, paths []string, dryRun bool) ([]string, error) {
 	if dryRun {
 		for _, p := range paths {
 			fmt.Printf("[DRY RUN] Would back up: %s -> %s\n", filepath.Join(homeDir, p), filepath.Join(backupDir, p))
 		}
-		return nil
+		return nil, nil
 	}
 
 	if err := os.MkdirAll(backupDir, 0755); err != nil {
-		return fmt.Errorf("create backup dir: %w", err)
+		return nil, fmt.Errorf("create backup dir: %w", err)
 	}
 
+	var moved []string
 	for _, rawPath := range paths {
 		relPath, err := safeRelPath(rawPath)
 		if err != nil {
-			return err
+			return moved, err
 		}
 
 		srcPath := filepath.Join(homeDir, relPath)
 		dstPath := filepath.Join(backupDir, relPath)
 
 		if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
-			return fmt.Errorf("create backup parent dir: %w", err)
+			return moved, fmt.Errorf("create backup parent dir: %w", err)
 		}
 
 		if err := os.Rename(srcPath, dstPath); err != nil {
 			if errors.Is(err, os.ErrNotExist) {
 				continue
 			}
-			return fmt.Errorf("backup %s: %w", relPath, err)
+			return moved, fmt.Errorf("backup %s: %w", relPath, err)
 		}
+		moved = append(moved, relPath)
 	}
 
-	return nil
+	return moved, nil
 }
 
 func safeRelPath(p string) (string, error) {
@@ -258,52 +603,20 @@ func safeRelPath(p string) (string, error) {
 	return clean, nil
 }
 
-func parseOverwrittenFilePaths(gitOutput string) []string {
-	var paths []string
-	inList := false
-
-	for _, line := range strings.Split(gitOutput, "\n") {
-		trimmed := strings.TrimSpace(line)
-
-		if strings.Contains(line, "would be overwritten by checkout:") || strings.Contains(line, "would be overwritten by merge:") {
-			inList = true
-			continue
-		}
-
-		if !inList {
-			continue
-		}
-
-		if trimmed == "" {
-			continue
-		}
-
-		// Common terminators after the file list
-		if strings.HasPrefix(trimmed, "Please ") || strings.HasPrefix(trimmed, "Aborting") || strings.HasPrefix(trimmed, "hint:") {
-			inList = false
-			continue
-		}
-
-		// If we hit another header-like line, end the current list.
-		if strings.HasPrefix(trimmed, "error:") || strings.HasPrefix(trimmed, "fatal:") {
-			inList = false
-			continue
-		}
-
-		paths = append(paths, trimmed)
-	}
-
-	paths = slices.Compact(paths)
-	return paths
-}
-
 func init() {
 	rootCmd.AddCommand(repoCmd)
 	repoCmd.AddCommand(syncCmd)
 	repoCmd.AddCommand(repoGitCmd)
-	syncCmd.Flags().String("url", "git@github.com:w31r4/dotfiles.git", "The URL of the dotfiles repository")
-	syncCmd.Flags().String("dir", "~/.dotfiles", "The directory where the bare dotfiles repo is stored")
-	syncCmd.Flags().String("backup-dir", "~/.dotfiles-backup", "Where to back up conflicting files during checkout/pull")
+	repoCmd.PersistentFlags().String("repo", "", "Use the named repository from the repos: list of config.yaml")
+	repoCmd.PersistentFlags().String("dir", "", "The directory where the bare dotfiles repo is stored (default ~/.dotfiles)")
+	repoCmd.PersistentFlags().String("work-tree", "", "The directory the dotfiles are checked out into (default ~)")
+	syncCmd.Flags().String("url", "", "The URL of the dotfiles repository")
+	syncCmd.Flags().String("branch", "", "The branch to check out (default: the remote's default branch)")
+	syncCmd.Flags().String("backup-dir", "", "Where to back up conflicting files during checkout/pull (default ~/.dotfiles-backup)")
+	syncCmd.Flags().String("backend", "", "The git implementation to use: cli or go-git (default cli)")
+	syncCmd.Flags().String("strategy", strategyBackup, "What to do with local files in the way: backup, ours or theirs")
+	syncCmd.Flags().BoolP("interactive", "i", false, "Ask what to do with each local file in the way")
+	syncCmd.Flags().Bool("all", false, "Sync every repository in the repos: list of config.yaml")
 	syncCmd.Flags().Bool("pull", true, "Pull latest changes (fast-forward only) after checkout")
 	syncCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Simulate actions without making changes")
 }
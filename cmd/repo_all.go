@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/w31r4/dotm/config"
+)
+
+// syncAll syncs every entry of the repos: list, lowest priority first.
+// A repository that tracks a file already checked out by an earlier one
+// is reported and skipped instead of overwriting it.
+func syncAll(cmd *cobra.Command, strategy string, pullLatest bool) error {
+	for _, flag := range []string{"repo", "url", "dir", "branch"} {
+		if cmd.Flags().Changed(flag) {
+			return fmt.Errorf("--all syncs every entry of repos:, so --%s cannot be used with it", flag)
+		}
+	}
+	path := configPath
+	if path == "" {
+		path = "config.yaml"
+	}
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		return fmt.Errorf("load %s: %w", path, err)
+	}
+	if len(cfg.Repos) == 0 {
+		return fmt.Errorf("--all needs a repos: list in %s", path)
+	}
+	repos := slices.Clone(cfg.Repos)
+	slices.SortStableFunc(repos, func(a, b config.NamedRepo) int { return a.Priority - b.Priority })
+
+	// claimed maps the absolute path of every file checked out so far to
+	// the repository it came from.
+	claimed := make(map[string]string)
+	var failed []string
+	for i, entry := range repos {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("==> %s\n", entry.Name)
+		settings, err := loadNamedRepoSettings(cmd, entry.Name)
+		if err == nil {
+			err = syncRepo(settings, strategy, pullLatest, func(files []string) error {
+				return claimFiles(files, settings, claimed)
+			})
+		}
+		if err != nil {
+			fmt.Printf("❌ %s: %v\n", entry.Name, err)
+			failed = append(failed, entry.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d repositories were not synced: %s", len(failed), len(repos), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// claimFiles records files, relative to the work tree of settings, in
+// claimed. It fails without recording anything if another repository
+// already claimed one of them.
+func claimFiles(files []string, settings *repoSettings, claimed map[string]string) error {
+	var overlaps []string
+	for _, f := range files {
+		if owner, ok := claimed[filepath.Join(settings.workTree, f)]; ok && owner != settings.name {
+			overlaps = append(overlaps, fmt.Sprintf("%s (from %s)", f, owner))
+		}
+	}
+	if len(overlaps) > 0 {
+		return fmt.Errorf("these files are also tracked by a repository synced earlier (remove them from one of the two):\n  - %s", strings.Join(overlaps, "\n  - "))
+	}
+	for _, f := range files {
+		claimed[filepath.Join(settings.workTree, f)] = settings.name
+	}
+	return nil
+}
+
+// splitRepoFlag removes a leading --repo NAME or --repo=NAME from the
+// arguments of `repo git`, which does not parse flags itself.
+func splitRepoFlag(args []string) (string, []string, error) {
+	if len(args) == 0 {
+		return "", args, nil
+	}
+	if name, ok := strings.CutPrefix(args[0], "--repo="); ok {
+		return name, args[1:], nil
+	}
+	if args[0] == "--repo" {
+		if len(args) < 2 {
+			return "", nil, errors.New("--repo needs a repository name")
+		}
+		return args[1], args[2:], nil
+	}
+	return "", args, nil
+}
+
+// repoNames lists the names in the repos: list for error messages.
+func repoNames(cfg *config.Config) string {
+	names := make([]string, len(cfg.Repos))
+	for i, r := range cfg.Repos {
+		names[i] = r.Name
+	}
+	return strings.Join(names, ", ")
+}
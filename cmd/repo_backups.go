@@ -0,0 +1,291 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/w31r4/dotm/pkg/backup"
+	"github.com/w31r4/dotm/pkg/fileutil"
+)
+
+var repoBackupsCmd = &cobra.Command{
+	Use:   "backups",
+	Short: "List, restore and prune backups made by repo sync",
+	Long: `When 'dotm repo sync' finds files in $HOME that a checkout or pull would
+overwrite, it moves them into a timestamped snapshot under the backup
+directory (--backup-dir, DOTM_DOTFILES_BACKUP_DIR or repo.backup_dir in
+config.yaml; default ~/.dotfiles-backup). These commands browse, restore and
+clean up those snapshots.`,
+}
+
+var repoBackupsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List backup snapshots with their files",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		settings, err := loadRepoSettings(cmd)
+		if err != nil {
+			return err
+		}
+		baseDir := settings.backupDir
+		snapshots, err := backup.List(baseDir)
+		if err != nil {
+			return err
+		}
+		if len(snapshots) == 0 {
+			fmt.Printf("No backups in %s\n", baseDir)
+			return nil
+		}
+		for i, s := range snapshots {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%s  %s  %s  (%d files)\n", s.ID, s.CreatedAt.Format("2006-01-02 15:04"), s.Operation, len(s.Files))
+			for _, f := range s.Files {
+				fmt.Printf("  %s\n", f)
+			}
+		}
+		return nil
+	},
+}
+
+var repoBackupsRestoreCmd = &cobra.Command{
+	Use:   "restore <id> [paths...]",
+	Short: "Restore files from a backup snapshot",
+	Long: `Copy files from a backup snapshot back into $HOME. Without paths, every
+file in the snapshot is restored. The id may be abbreviated to any unique
+prefix.
+
+Missing files are restored directly. When a file in $HOME differs from the
+backed-up copy, the diff is shown and you are asked whether to replace it;
+the current file is first moved into a new snapshot. --force replaces
+without asking. Without a terminal, differing files are skipped unless
+--force is given. The snapshot itself is left untouched.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		settings, err := loadRepoSettings(cmd)
+		if err != nil {
+			return err
+		}
+		baseDir := settings.backupDir
+		snapshot, err := backup.Find(baseDir, args[0])
+		if err != nil {
+			return err
+		}
+		// Snapshots made before manifests existed do not record their work tree.
+		if snapshot.WorkTree == "" {
+			snapshot.WorkTree = settings.workTree
+		}
+		force, _ := cmd.Flags().GetBool("force")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		return restoreBackup(snapshot, baseDir, args[1:], force, dryRun)
+	},
+}
+
+var repoBackupsPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete old backup snapshots",
+	Long: `Delete backup snapshots. --keep N always keeps the N newest snapshots;
+--older-than limits deletion to snapshots older than the given age (e.g.
+30d, 2w or 12h). With both, the newest N are kept and the rest are deleted
+once they are old enough.`,
+	Example: "  dotm repo backups prune --keep 5 --older-than 30d",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keep, _ := cmd.Flags().GetInt("keep")
+		olderThanFlag, _ := cmd.Flags().GetString("older-than")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if keep < 0 {
+			return fmt.Errorf("--keep must not be negative")
+		}
+		if !cmd.Flags().Changed("keep") && olderThanFlag == "" {
+			return fmt.Errorf("specify --keep, --older-than or both")
+		}
+		var olderThan time.Duration
+		if olderThanFlag != "" {
+			var err error
+			if olderThan, err = backup.ParseAge(olderThanFlag); err != nil {
+				return err
+			}
+		}
+
+		settings, err := loadRepoSettings(cmd)
+		if err != nil {
+			return err
+		}
+		snapshots, err := backup.List(settings.backupDir)
+		if err != nil {
+			return err
+		}
+		prune := backup.Prunable(snapshots, keep, olderThan, time.Now())
+		if len(prune) == 0 {
+			fmt.Println("Nothing to prune.")
+			return nil
+		}
+		for _, s := range prune {
+			if dryRun {
+				fmt.Printf("[DRY RUN] Would delete %s (%d files)\n", s.ID, len(s.Files))
+				continue
+			}
+			if err := os.RemoveAll(s.Dir); err != nil {
+				return fmt.Errorf("delete %s: %w", s.ID, err)
+			}
+			fmt.Printf("Deleted %s (%d files)\n", s.ID, len(s.Files))
+		}
+		return nil
+	},
+}
+
+// restoreBackup copies files from a snapshot back into the work tree it
+// was taken from.
+func restoreBackup(snapshot *backup.Snapshot, baseDir string, paths []string, force, dryRun bool) error {
+	homeDir := snapshot.WorkTree
+	if homeDir == "" {
+		var err error
+		if homeDir, err = os.UserHomeDir(); err != nil {
+			return fmt.Errorf("get home dir: %w", err)
+		}
+	}
+
+	files := snapshot.Files
+	if len(paths) > 0 {
+		files = nil
+		for _, p := range paths {
+			rel, err := workTreeRelPath(p, homeDir)
+			if err != nil {
+				return err
+			}
+			if !slices.Contains(snapshot.Files, rel) {
+				return fmt.Errorf("%s is not in backup %s", rel, snapshot.ID)
+			}
+			files = append(files, rel)
+		}
+	}
+
+	// Files replaced during the restore are backed up to a new snapshot.
+	var replaced *backup.Manifest
+	var restored, skipped int
+	for _, rel := range files {
+		src := filepath.Join(snapshot.Dir, rel)
+		dst := filepath.Join(homeDir, rel)
+
+		if _, err := os.Stat(dst); errors.Is(err, os.ErrNotExist) {
+			if dryRun {
+				fmt.Printf("[DRY RUN] Would restore %s\n", dst)
+				continue
+			}
+			if err := fileutil.CopyFile(src, dst); err != nil {
+				return fmt.Errorf("restore %s: %w", rel, err)
+			}
+			fmt.Printf("Restored %s\n", dst)
+			restored++
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		same, err := fileutil.SameContent(src, dst)
+		if err != nil {
+			return fmt.Errorf("compare %s: %w", rel, err)
+		}
+		if same {
+			fmt.Printf("Unchanged %s\n", dst)
+			continue
+		}
+
+		fmt.Printf("\n%s differs from the backup:\n", dst)
+		showFileDiff(dst, src)
+		if dryRun {
+			fmt.Printf("[DRY RUN] Would ask before replacing %s\n", dst)
+			continue
+		}
+		if !force && !confirm(fmt.Sprintf("Replace %s with the backed-up version?", dst)) {
+			fmt.Printf("Skipped %s\n", dst)
+			skipped++
+			continue
+		}
+
+		if replaced == nil {
+			now := time.Now()
+			replaced = &backup.Manifest{
+				ID:        backup.NewID(now),
+				CreatedAt: now,
+				Operation: "restore " + snapshot.ID,
+				GitDir:    snapshot.GitDir,
+				WorkTree:  homeDir,
+			}
+		}
+		replacedDir := filepath.Join(baseDir, replaced.ID)
+		moved, err := backupPaths(homeDir, replacedDir, []string{rel}, false)
+		if err != nil {
+			return err
+		}
+		replaced.Files = append(replaced.Files, moved...)
+		if err := backup.WriteManifest(replacedDir, replaced); err != nil {
+			return fmt.Errorf("write backup manifest: %w", err)
+		}
+		if err := fileutil.CopyFile(src, dst); err != nil {
+			return fmt.Errorf("restore %s: %w", rel, err)
+		}
+		fmt.Printf("Restored %s (previous version backed up to %s)\n", dst, replacedDir)
+		restored++
+	}
+
+	if !dryRun {
+		fmt.Printf("\nRestored %d files from %s", restored, snapshot.ID)
+		if skipped > 0 {
+			fmt.Printf(", skipped %d", skipped)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// workTreeRelPath turns "~/.zshrc", "/home/me/.zshrc" or ".zshrc" into a
+// path relative to workTree, refusing paths outside it.
+func workTreeRelPath(p, workTree string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("get home dir: %w", err)
+	}
+	if p, err = expandHomePath(p, homeDir); err != nil {
+		return "", err
+	}
+	if filepath.IsAbs(p) {
+		if p, err = filepath.Rel(workTree, p); err != nil {
+			return "", err
+		}
+	}
+	return safeRelPath(p)
+}
+
+// showFileDiff prints a unified diff from current to other.
+func showFileDiff(current, other string) {
+	diff := exec.Command("git", "diff", "--no-index", "--", current, other)
+	diff.Stdout = os.Stdout
+	diff.Stderr = os.Stderr
+	// git diff exits with 1 when the files differ.
+	if err := diff.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+			fmt.Printf("(could not show diff: %v)\n", err)
+		}
+	}
+}
+
+func init() {
+	repoCmd.AddCommand(repoBackupsCmd)
+	repoBackupsCmd.AddCommand(repoBackupsListCmd, repoBackupsRestoreCmd, repoBackupsPruneCmd)
+	repoBackupsCmd.PersistentFlags().String("backup-dir", "", "Where repo sync stores backups (default ~/.dotfiles-backup)")
+	repoBackupsRestoreCmd.Flags().Bool("force", false, "Replace differing files without asking")
+	repoBackupsRestoreCmd.Flags().Bool("dry-run", false, "Show what would be restored without changing anything")
+	repoBackupsPruneCmd.Flags().Int("keep", 0, "Number of newest snapshots to keep")
+	repoBackupsPruneCmd.Flags().String("older-than", "", "Only delete snapshots older than this age (e.g. 30d)")
+	repoBackupsPruneCmd.Flags().Bool("dry-run", false, "List the snapshots that would be deleted")
+}
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/w31r4/dotm/pkg/dotrepo"
+	"github.com/w31r4/dotm/pkg/fileutil"
+)
+
+// Conflict strategies for files in the way of a checkout or pull.
+const (
+	// strategyBackup moves local files into a backup snapshot and takes
+	// the repo version.
+	strategyBackup = "backup"
+	// strategyOurs keeps local files and commits them.
+	strategyOurs = "ours"
+	// strategyTheirs takes the repo version like strategyBackup, reporting
+	// where each local file was backed up.
+	strategyTheirs = "theirs"
+	// strategyAsk asks about each file (repo sync --interactive).
+	strategyAsk = "ask"
+)
+
+var conflictStrategies = []string{strategyBackup, strategyOurs, strategyTheirs}
+
+// conflictStrategy returns the strategy selected by the --strategy and
+// --interactive flags of cmd.
+func conflictStrategy(cmd *cobra.Command) (string, error) {
+	strategy, _ := cmd.Flags().GetString("strategy")
+	interactive, _ := cmd.Flags().GetBool("interactive")
+	if !slices.Contains(conflictStrategies, strategy) {
+		return "", fmt.Errorf("unknown strategy %q (supported: %s)", strategy, strings.Join(conflictStrategies, ", "))
+	}
+	if interactive {
+		if cmd.Flags().Changed("strategy") {
+			return "", fmt.Errorf("--interactive and --strategy cannot be used together")
+		}
+		return strategyAsk, nil
+	}
+	return strategy, nil
+}
+
+// resolve decides, once a checkout or pull has moved conflicts into
+// backupDir, which version of each file to keep. Local versions that are
+// kept or merged are committed together.
+func (s *syncer) resolve(backupDir string, conflicts []dotrepo.Conflict) error {
+	switch s.strategy {
+	case strategyBackup:
+		return nil
+	case strategyTheirs:
+		for _, c := range conflicts {
+			fmt.Printf("Took the repo version of %s (local copy in %s)\n", c.Path, backupDir)
+		}
+		return nil
+	}
+	var commit []string
+	for _, c := range conflicts {
+		local := filepath.Join(backupDir, c.Path)
+		current := filepath.Join(s.workTree, c.Path)
+		if !isRegularFile(local) || !isRegularFile(current) {
+			// A directory on one side, or a symlink, cannot be merged.
+			fmt.Printf("Took the repo version of %s (local copy in %s)\n", c.Path, backupDir)
+			continue
+		}
+		same, err := fileutil.SameContent(local, current)
+		if err != nil {
+			return fmt.Errorf("compare %s: %w", c.Path, err)
+		}
+		if same {
+			fmt.Printf("%s is identical to the repo version\n", c.Path)
+			continue
+		}
+
+		keep, err := s.resolveFile(c, local, current, backupDir)
+		if err != nil {
+			return err
+		}
+		if keep {
+			commit = append(commit, c.Path)
+		}
+	}
+
+	if len(commit) == 0 {
+		return nil
+	}
+	if err := s.repo.Commit("Keep local changes to "+strings.Join(commit, ", "), commit); err != nil {
+		return fmt.Errorf("commit local versions: %w", err)
+	}
+	fmt.Printf("Committed local versions of %s; run `dotm repo git push` to publish them\n", strings.Join(commit, ", "))
+	return nil
+}
+
+// resolveFile settles a single conflict whose local version is in local
+// and whose repo version has been checked out to current. It reports
+// whether current should be committed.
+func (s *syncer) resolveFile(c dotrepo.Conflict, local, current, backupDir string) (bool, error) {
+	if s.strategy == strategyOurs {
+		if err := fileutil.CopyFile(local, current); err != nil {
+			return false, fmt.Errorf("keep %s: %w", c.Path, err)
+		}
+		fmt.Printf("Kept local %s\n", c.Path)
+		return true, nil
+	}
+
+	fmt.Printf("\n%s is in the way (the local file is %s). Changes from your version to the repo's:\n", c.Path, c.Reason)
+	showFileDiff(local, current)
+	for {
+		switch strings.ToLower(prompt("[k]eep local, [t]ake repo, [m]erge, [s]kip (default: take repo)? ")) {
+		case "k", "keep":
+			if err := fileutil.CopyFile(local, current); err != nil {
+				return false, fmt.Errorf("keep %s: %w", c.Path, err)
+			}
+			fmt.Printf("Kept local %s\n", c.Path)
+			return true, nil
+		case "", "t", "take":
+			fmt.Printf("Took the repo version of %s (local copy in %s)\n", c.Path, backupDir)
+			return false, nil
+		case "m", "merge":
+			merged, err := mergeFile(local, current)
+			if err != nil {
+				fmt.Printf("Merge failed: %v\n", err)
+				continue
+			}
+			if !merged {
+				fmt.Printf("Merge left %s unchanged; took the repo version (local copy in %s)\n", c.Path, backupDir)
+				return false, nil
+			}
+			fmt.Printf("Merged %s\n", c.Path)
+			return true, nil
+		case "s", "skip":
+			if err := fileutil.CopyFile(local, current); err != nil {
+				return false, fmt.Errorf("restore %s: %w", c.Path, err)
+			}
+			fmt.Printf("Skipped %s: your version is back in place, uncommitted\n", c.Path)
+			return false, nil
+		default:
+			fmt.Println("Please answer k, t, m or s.")
+		}
+	}
+}
+
+// mergeFile runs $MERGETOOL (default vimdiff) with the local version, a
+// copy of the repo version and current as arguments; the tool writes the
+// result to current. It reports whether the result differs from the repo
+// version.
+func mergeFile(local, current string) (bool, error) {
+	tmp, err := os.MkdirTemp("", "dotm-merge-")
+	if err != nil {
+		return false, err
+	}
+	defer os.RemoveAll(tmp)
+	remote := filepath.Join(tmp, "REPO."+filepath.Base(current))
+	if err := fileutil.CopyFile(current, remote); err != nil {
+		return false, err
+	}
+
+	tool := os.Getenv("MERGETOOL")
+	if tool == "" {
+		tool = "vimdiff"
+	}
+	command := exec.Command("sh", "-c", tool+` "$1" "$2" "$3"`, "sh", local, remote, current)
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+	if err := command.Run(); err != nil {
+		// Put the repo version back so a failed tool leaves no half-merge.
+		if cerr := fileutil.CopyFile(remote, current); cerr != nil {
+			return false, errors.Join(fmt.Errorf("merge tool %s: %w", tool, err), cerr)
+		}
+		return false, fmt.Errorf("merge tool %s: %w", tool, err)
+	}
+	same, err := fileutil.SameContent(remote, current)
+	return !same, err
+}
+
+func isRegularFile(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,253 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/w31r4/dotm/config"
+	"github.com/w31r4/dotm/pkg/scan"
+)
+
+var repoInitCmd = &cobra.Command{
+	Use:   "init [paths...]",
+	Short: "Create a new bare dotfiles repo",
+	Long: `Create a new bare dotfiles repository for people who do not have one yet.
+
+init creates the bare repository at the configured dir (default
+~/.dotfiles), hides untracked files in the work tree, writes a starter
+.gitignore, adds the given files and makes the first commit. With --url it
+also adds the remote as origin; push with 'dotm repo git push -u origin
+<branch>' once the remote exists. Finally it writes the repo: section of
+config.yaml so the other repo commands find the new repository.
+
+Files can be given with --add or as arguments. They are checked like
+'dotm repo track' checks them: if any file matches the deny list or looks
+like it holds a secret, nothing is created.`,
+	Example: `  dotm repo init --add ~/.zshrc --add ~/.gitconfig
+  dotm repo init --url git@github.com:me/dotfiles.git ~/.zshrc ~/.gitconfig`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		settings, err := loadRepoSettings(cmd)
+		if err != nil {
+			return err
+		}
+		add, _ := cmd.Flags().GetStringSlice("add")
+		noConfig, _ := cmd.Flags().GetBool("no-config")
+		// A named repository is already in the repos: list.
+		return initDotfiles(settings, append(add, args...), !noConfig && settings.name == "", dryRun)
+	},
+}
+
+// starterGitignore is written to the work tree by `repo init`. It keeps
+// the repository itself, backups and common secrets and caches out of
+// `git add`.
+const starterGitignore = `# Created by 'dotm repo init'. The dotfiles repo checks out into this
+# directory, so keep its own files, caches and secrets out of it.
+%s
+.DS_Store
+*.swp
+*~
+/.cache/
+/.ssh/
+/.gnupg/
+/.aws/credentials
+`
+
+// initDotfiles creates the bare repository described by settings, commits
+// .gitignore and paths to it, and records the settings in config.yaml. If
+// a step fails, the repository and the .gitignore it wrote are removed
+// again, so init can simply be rerun.
+func initDotfiles(settings *repoSettings, paths []string, writeConfig, dryRun bool) (err error) {
+	if _, err := os.Stat(settings.dir); err == nil {
+		return fmt.Errorf("%s already exists (use `dotm repo sync` to check out an existing repo)", settings.dir)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("stat dotfiles dir: %w", err)
+	}
+
+	repo := &dotfiles{gitDir: settings.dir, workTree: settings.workTree}
+	rels, err := repo.relPaths(paths)
+	if err != nil {
+		return fmt.Errorf("cannot add %w", err)
+	}
+	files := append([]string{".gitignore"}, rels...)
+
+	// Check what will be committed the way `repo track` does, before
+	// anything is created.
+	var added []string
+	for _, rel := range rels {
+		err := filepath.WalkDir(filepath.Join(settings.workTree, rel), func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			file, err := filepath.Rel(settings.workTree, path)
+			if err != nil {
+				return err
+			}
+			added = append(added, filepath.ToSlash(file))
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("cannot add %s: %w", rel, err)
+		}
+	}
+	problems, err := checkFiles(settings.workTree, append(slices.Clone(scan.DefaultDeny), settings.deny...), added)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("refusing to add files that may hold secrets (nothing was created):\n  - %s", strings.Join(problems, "\n  - "))
+	}
+
+	branch := settings.branch
+	if branch == "" {
+		branch = "main"
+	}
+	git := func(args ...string) error {
+		_, err := runCommandInDir(dryRun, repo.workTree, "git", repo.gitArgs(args...)...)
+		return err
+	}
+
+	// 1) Create the bare repository.
+	if _, err := runCommand(dryRun, "git", "init", "--bare", "--initial-branch="+branch, settings.dir); err != nil {
+		return fmt.Errorf("failed to create repo: %w", err)
+	}
+	gitignore := filepath.Join(settings.workTree, ".gitignore")
+	wroteGitignore := false
+	defer func() {
+		if err == nil || dryRun {
+			return
+		}
+		if rmErr := os.RemoveAll(settings.dir); rmErr != nil {
+			fmt.Printf("Warning: could not remove %s: %v\n", settings.dir, rmErr)
+		}
+		if wroteGitignore {
+			os.Remove(gitignore)
+		}
+		err = fmt.Errorf("%w (removed the unfinished repo at %s)", err, settings.dir)
+	}()
+	if _, err := runCommand(dryRun, "git", "--git-dir="+settings.dir, "config", "status.showUntrackedFiles", "no"); err != nil {
+		return fmt.Errorf("failed to configure dotfiles repo: %w", err)
+	}
+
+	// 2) Write a starter .gitignore unless there already is one.
+	if _, err := os.Stat(gitignore); errors.Is(err, os.ErrNotExist) {
+		if dryRun {
+			fmt.Printf("[DRY RUN] Would write %s\n", gitignore)
+		} else {
+			content := fmt.Sprintf(starterGitignore, strings.Join(ignoredRepoDirs(settings), "\n"))
+			if err := os.WriteFile(gitignore, []byte(content), 0644); err != nil {
+				return fmt.Errorf("write .gitignore: %w", err)
+			}
+			wroteGitignore = true
+			fmt.Printf("Wrote %s\n", gitignore)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	// 3) Make the first commit.
+	if err := git(append([]string{"add", "--"}, files...)...); err != nil {
+		return fmt.Errorf("failed to add files: %w", err)
+	}
+	if err := git("commit", "-m", "Initial dotfiles commit"); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+
+	// 4) Point the branch at the remote, so the first push only needs -u.
+	if settings.url != "" {
+		if err := git("remote", "add", "origin", settings.url); err != nil {
+			return fmt.Errorf("failed to add remote: %w", err)
+		}
+		if err := git("config", "branch."+branch+".remote", "origin"); err != nil {
+			return err
+		}
+		if err := git("config", "branch."+branch+".merge", "refs/heads/"+branch); err != nil {
+			return err
+		}
+	}
+
+	// 5) Record where the repository lives.
+	if writeConfig {
+		spec := repoSpecFor(settings, branch)
+		if dryRun {
+			fmt.Printf("[DRY RUN] Would write the repo: section of %s\n", settings.configPath)
+		} else if err := config.SetRepo(settings.configPath, spec); err != nil {
+			return fmt.Errorf("failed to update %s: %w", settings.configPath, err)
+		} else {
+			fmt.Printf("Wrote the repo: section of %s\n", settings.configPath)
+		}
+	}
+
+	fmt.Printf("✅ Created dotfiles repo at %s with %d files\n", settings.dir, len(files))
+	if settings.url != "" {
+		fmt.Printf("Push it with: dotm repo git push -u origin %s\n", branch)
+	}
+	return nil
+}
+
+// ignoredRepoDirs returns .gitignore patterns for the repository and
+// backup directories when they are inside the work tree.
+func ignoredRepoDirs(settings *repoSettings) []string {
+	var patterns []string
+	for _, dir := range []string{settings.dir, settings.backupDir} {
+		rel, err := filepath.Rel(settings.workTree, dir)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+			continue
+		}
+		patterns = append(patterns, "/"+filepath.ToSlash(rel)+"/")
+	}
+	return patterns
+}
+
+// repoSpecFor returns the repo: section describing settings, keeping the
+// settings init does not own (deny, encryption, backend) from the current
+// section. Settings that are at their defaults are left out; paths in the
+// home directory are written with "~".
+func repoSpecFor(settings *repoSettings, branch string) config.RepoSpec {
+	homeDir, _ := os.UserHomeDir()
+	spec := config.RepoSpec{}
+	if cfg, err := config.LoadConfig(settings.configPath); err == nil && cfg.Repo != nil {
+		spec = *cfg.Repo
+	}
+	spec.URL = settings.url
+	spec.Dir = tildePath(settings.dir, homeDir)
+	spec.Branch = branch
+	spec.BackupDir, spec.WorkTree = "", ""
+	if backupDir := tildePath(settings.backupDir, homeDir); backupDir != "~/.dotfiles-backup" {
+		spec.BackupDir = backupDir
+	}
+	if workTree := tildePath(settings.workTree, homeDir); workTree != "~" {
+		spec.WorkTree = workTree
+	}
+	return spec
+}
+
+// tildePath is the inverse of expandHomePath: it abbreviates paths in
+// homeDir with "~".
+func tildePath(path, homeDir string) string {
+	if homeDir == "" {
+		return path
+	}
+	if path == homeDir {
+		return "~"
+	}
+	if rel, ok := strings.CutPrefix(path, homeDir+string(os.PathSeparator)); ok {
+		return "~" + string(os.PathSeparator) + rel
+	}
+	return path
+}
+
+func init() {
+	repoCmd.AddCommand(repoInitCmd)
+	repoInitCmd.Flags().String("url", "", "Remote to add as origin")
+	repoInitCmd.Flags().String("branch", "", "Name of the initial branch (default main)")
+	repoInitCmd.Flags().String("backup-dir", "", "Backup directory to record in config.yaml (default ~/.dotfiles-backup)")
+	repoInitCmd.Flags().StringSlice("add", nil, "Files to add in the first commit (repeatable)")
+	repoInitCmd.Flags().Bool("no-config", false, "Do not write the repo: section of config.yaml")
+	repoInitCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Simulate actions without making changes")
+}
@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/w31r4/dotm/pkg/scan"
+)
+
+var repoSaveCmd = &cobra.Command{
+	Use:     "save",
+	Aliases: []string{"push"},
+	Short:   "Commit changed dotfiles and push them",
+	Long: `Commit every change to tracked dotfiles and push it to the upstream.
+
+The changes to be saved (modified and deleted tracked files, plus anything
+already staged, e.g. by 'dotm repo track') are checked first: changed paths
+against the deny list and added lines against the secret scanner. If
+anything is found, nothing is staged, committed or pushed.
+
+Without -m, the commit message lists the changed files. Commits that are
+already waiting to be pushed, e.g. made with 'dotm repo git commit', are
+pushed as well, after the same checks: if any of them touches a denied path
+or adds a line that looks like a secret, nothing is pushed.`,
+	Example: `  dotm repo save
+  dotm repo save -m "Switch prompt to starship"`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		settings, err := loadRepoSettings(cmd)
+		if err != nil {
+			return err
+		}
+		repo, err := settings.open()
+		if err != nil {
+			return err
+		}
+		message, _ := cmd.Flags().GetString("message")
+		noPush, _ := cmd.Flags().GetBool("no-push")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		return saveDotfiles(repo, append(slices.Clone(scan.DefaultDeny), settings.deny...), message, !noPush, dryRun)
+	},
+}
+
+// savedChange is a file in the commit made by repo save.
+type savedChange struct {
+	status string
+	path   string
+}
+
+// saveDotfiles checks, commits and optionally pushes every change to
+// tracked files.
+func saveDotfiles(repo *dotfiles, deny []string, message string, push, dryRun bool) error {
+	changes, err := pendingChanges(repo)
+	if err != nil {
+		return err
+	}
+	if err := checkChanges(repo, deny, changes); err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("No changes to tracked files.")
+	} else {
+		fmt.Println("Changes to save:")
+		for _, c := range changes {
+			fmt.Printf("  %-9s %s\n", c.status, c.path)
+		}
+		if message == "" {
+			message = saveMessage(changes)
+		}
+		if _, err := runCommandInDir(dryRun, repo.workTree, "git", repo.gitArgs("add", "--update")...); err != nil {
+			return err
+		}
+		if _, err := runCommandInDir(dryRun, repo.workTree, "git", repo.gitArgs("commit", "--quiet", "--message", message)...); err != nil {
+			return err
+		}
+	}
+	if !push {
+		return nil
+	}
+
+	st, err := repoStatus(repo)
+	if err != nil {
+		return err
+	}
+	if st.Upstream == "" {
+		return fmt.Errorf("branch %s has no upstream to push to (run 'dotm repo git push -u origin %s' once)", st.Branch, st.Branch)
+	}
+	if len(changes) == 0 && st.Ahead == 0 {
+		fmt.Printf("Nothing to push; %s is up to date with %s.\n", st.Branch, st.Upstream)
+		return nil
+	}
+	// Commits made outside repo save have not been checked yet.
+	if err := checkUnpushed(repo, deny); err != nil {
+		return err
+	}
+	_, err = runCommandInDir(dryRun, repo.workTree, "git", repo.gitArgs("push", "--quiet")...)
+	return err
+}
+
+// pendingChanges lists the changes `git add --update` followed by a commit
+// would record: staged changes and changes to tracked files.
+func pendingChanges(repo *dotfiles) ([]savedChange, error) {
+	out, err := repo.output("diff", "HEAD", "--name-status", "--no-renames", "-z")
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	var changes []savedChange
+	for i := 0; i+1 < len(fields); i += 2 {
+		status := "modified"
+		switch fields[i] {
+		case "A":
+			status = "added"
+		case "D":
+			status = "deleted"
+		case "T":
+			status = "retyped"
+		}
+		changes = append(changes, savedChange{status: status, path: fields[i+1]})
+	}
+	return changes, nil
+}
+
+// checkChanges refuses changes to denied paths and added lines that look
+// like secrets, listing every problem.
+func checkChanges(repo *dotfiles, deny []string, changes []savedChange) error {
+	var paths []string
+	for _, c := range changes {
+		if c.status != "deleted" {
+			paths = append(paths, c.path)
+		}
+	}
+	diff, err := repo.output("diff", "HEAD", "--unified=0", "--no-color", "--no-ext-diff", "--no-renames")
+	if err != nil {
+		return err
+	}
+	problems, err := secretProblems(deny, paths, diff)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("refusing to save changes that may hold secrets (nothing was committed or pushed):\n  - %s\nRemove the secrets, or encrypt the file with 'dotm repo track --encrypt'", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// checkUnpushed applies the checks of checkChanges to every commit that
+// is not on the upstream yet, so a secret added by one commit and removed
+// by a later one is still refused.
+func checkUnpushed(repo *dotfiles, deny []string) error {
+	out, err := repo.output("log", "--name-only", "--diff-filter=d", "--no-renames", "--format=", "-z", "@{upstream}..HEAD")
+	if err != nil {
+		return err
+	}
+	var paths []string
+	for _, path := range strings.Split(out, "\x00") {
+		if path = strings.TrimSpace(path); path != "" && !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+	diff, err := repo.output("log", "--patch", "--unified=0", "--no-color", "--no-ext-diff", "--no-renames", "--format=", "@{upstream}..HEAD")
+	if err != nil {
+		return err
+	}
+	problems, err := secretProblems(deny, paths, diff)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("refusing to push commits that may hold secrets (nothing was pushed):\n  - %s\nRemove the secrets from the unpushed commits, e.g. with 'dotm repo git rebase -i @{upstream}'", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// secretProblems describes the paths that match the deny patterns and the
+// lines added by diff that look like secrets.
+func secretProblems(deny, paths []string, diff string) ([]string, error) {
+	var problems []string
+	for _, path := range paths {
+		if pattern, denied := scan.Denied(path, deny); denied {
+			problems = append(problems, fmt.Sprintf("%s: matches deny pattern %q", path, pattern))
+		}
+	}
+	findings, err := scan.Diff(strings.NewReader(diff))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range findings {
+		problems = append(problems, fmt.Sprintf("%s:%d: looks like a %s", f.Path, f.Line, f.Rule))
+	}
+	return problems, nil
+}
+
+// saveMessage lists the changed files, naming them in the subject when
+// there are only a few.
+func saveMessage(changes []savedChange) string {
+	paths := make([]string, len(changes))
+	for i, c := range changes {
+		paths[i] = c.path
+	}
+	subject := fmt.Sprintf("Update %d dotfiles", len(changes))
+	if len(changes) <= 3 {
+		subject = "Update " + strings.Join(paths, ", ")
+	}
+	var body strings.Builder
+	for _, c := range changes {
+		fmt.Fprintf(&body, "\n%s: %s", c.status, c.path)
+	}
+	return subject + "\n" + body.String()
+}
+
+func init() {
+	repoCmd.AddCommand(repoSaveCmd)
+	repoSaveCmd.Flags().StringP("message", "m", "", "Commit message (default: a list of the changed files)")
+	repoSaveCmd.Flags().Bool("no-push", false, "Commit without pushing")
+	repoSaveCmd.Flags().Bool("dry-run", false, "Check the changes and show the git commands without running them")
+}
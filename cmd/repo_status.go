@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/w31r4/dotm/pkg/state"
+)
+
+var repoStatusJSON bool
+
+var repoStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Summarize the state of the dotfiles repo",
+	Long: `Summarize the dotfiles bare repository: the branch and how far it is
+ahead of or behind its upstream, tracked files in $HOME that were modified
+or deleted, stashes, whether untracked files are hidden, and when
+'dotm repo sync' last ran.
+
+Ahead/behind counts compare against the last fetch; run 'dotm repo git
+fetch' first for up-to-date numbers. Use --json for scripts and shell
+prompts.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openDotfiles(cmd)
+		if err != nil {
+			return err
+		}
+		st, err := repoStatus(repo)
+		if err != nil {
+			return err
+		}
+		if repoStatusJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(st)
+		}
+		printRepoStatus(st)
+		return nil
+	},
+}
+
+// repoState is the summary printed by `repo status`.
+type repoState struct {
+	GitDir   string `json:"git_dir"`
+	WorkTree string `json:"work_tree"`
+	Branch   string `json:"branch"`
+	// Upstream is empty when the branch does not track a remote branch.
+	Upstream string   `json:"upstream,omitempty"`
+	Ahead    int      `json:"ahead"`
+	Behind   int      `json:"behind"`
+	Modified []string `json:"modified"`
+	Deleted  []string `json:"deleted"`
+	// Conflicted lists files with unresolved merge conflicts.
+	Conflicted []string `json:"conflicted"`
+	Stashes    int      `json:"stashes"`
+	// ShowUntrackedFiles is the value of status.showUntrackedFiles; "no"
+	// keeps the rest of $HOME out of git status.
+	ShowUntrackedFiles string `json:"show_untracked_files"`
+	// LastSync is nil if `repo sync` never completed.
+	LastSync *time.Time `json:"last_sync"`
+}
+
+// clean reports whether there is nothing to commit, push or pull.
+func (s *repoState) clean() bool {
+	return s.Ahead == 0 && s.Behind == 0 && len(s.Modified) == 0 && len(s.Deleted) == 0 && len(s.Conflicted) == 0
+}
+
+func repoStatus(repo *dotfiles) (*repoState, error) {
+	st := &repoState{
+		GitDir:     repo.gitDir,
+		WorkTree:   repo.workTree,
+		Modified:   []string{},
+		Deleted:    []string{},
+		Conflicted: []string{},
+	}
+
+	// Untracked files are never listed: the work tree is all of $HOME.
+	out, err := repo.output("status", "--porcelain=v2", "--branch", "--untracked-files=no", "-z")
+	if err != nil {
+		return nil, err
+	}
+	parsePorcelainStatus(out, st)
+
+	if out, err := repo.output("stash", "list"); err == nil && out != "" {
+		st.Stashes = len(strings.Split(out, "\n"))
+	}
+
+	// `git config --get` exits with 1 when the option is unset.
+	st.ShowUntrackedFiles, _ = repo.output("config", "--get", "status.showUntrackedFiles")
+
+	path, err := state.ReposPath()
+	if err != nil {
+		return nil, err
+	}
+	repos, err := state.LoadRepos(path)
+	if err != nil {
+		return nil, err
+	}
+	if rs, ok := repos.Repos[repo.gitDir]; ok && !rs.LastSync.IsZero() {
+		st.LastSync = &rs.LastSync
+	}
+	return st, nil
+}
+
+// parsePorcelainStatus reads `git status --porcelain=v2 --branch -z`
+// output into st. The porcelain format does not depend on git's language
+// or version.
+func parsePorcelainStatus(out string, st *repoState) {
+	entries := strings.Split(out, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		switch {
+		case strings.HasPrefix(entry, "# branch.head "):
+			st.Branch = strings.TrimPrefix(entry, "# branch.head ")
+		case strings.HasPrefix(entry, "# branch.upstream "):
+			st.Upstream = strings.TrimPrefix(entry, "# branch.upstream ")
+		case strings.HasPrefix(entry, "# branch.ab "):
+			fields := strings.Fields(strings.TrimPrefix(entry, "# branch.ab "))
+			if len(fields) == 2 {
+				st.Ahead, _ = strconv.Atoi(strings.TrimPrefix(fields[0], "+"))
+				st.Behind, _ = strconv.Atoi(strings.TrimPrefix(fields[1], "-"))
+			}
+		case strings.HasPrefix(entry, "1 "), strings.HasPrefix(entry, "2 "):
+			// "1 XY sub mH mI mW hH hI path"; renames ("2 ...") have one
+			// more field and are followed by the original path.
+			n := 9
+			if entry[0] == '2' {
+				n = 10
+				i++
+			}
+			fields := strings.SplitN(entry, " ", n)
+			if len(fields) < n {
+				continue
+			}
+			xy, path := fields[1], fields[n-1]
+			if strings.Contains(xy, "D") {
+				st.Deleted = append(st.Deleted, path)
+			} else {
+				st.Modified = append(st.Modified, path)
+			}
+		case strings.HasPrefix(entry, "u "):
+			fields := strings.SplitN(entry, " ", 11)
+			if len(fields) == 11 {
+				st.Conflicted = append(st.Conflicted, fields[10])
+			}
+		}
+	}
+}
+
+func printRepoStatus(st *repoState) {
+	fmt.Printf("Repository: %s (work tree %s)\n", st.GitDir, st.WorkTree)
+
+	branch := st.Branch
+	if branch == "(detached)" {
+		branch = "detached HEAD"
+	}
+	switch {
+	case st.Upstream == "":
+		fmt.Printf("Branch:     %s (no upstream; run `dotm repo sync` to track origin)\n", branch)
+	case st.Ahead == 0 && st.Behind == 0:
+		fmt.Printf("Branch:     %s, up to date with %s\n", branch, st.Upstream)
+	default:
+		fmt.Printf("Branch:     %s, ahead %d, behind %d of %s\n", branch, st.Ahead, st.Behind, st.Upstream)
+	}
+
+	printPathList("Modified", st.Modified)
+	printPathList("Deleted", st.Deleted)
+	printPathList("Conflicted", st.Conflicted)
+
+	fmt.Printf("Stashes:    %d\n", st.Stashes)
+	if st.ShowUntrackedFiles == "no" {
+		fmt.Printf("Untracked:  hidden (status.showUntrackedFiles=no)\n")
+	} else {
+		fmt.Printf("Untracked:  shown; run `dotm repo git config status.showUntrackedFiles no` to hide the rest of $HOME\n")
+	}
+	if st.LastSync != nil {
+		fmt.Printf("Last sync:  %s (%s ago)\n", st.LastSync.Format("2006-01-02 15:04"), time.Since(*st.LastSync).Round(time.Minute))
+	} else {
+		fmt.Printf("Last sync:  never\n")
+	}
+	if st.clean() {
+		fmt.Println("\n✅ Nothing to commit, push or pull.")
+	}
+}
+
+func printPathList(label string, paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	fmt.Printf("%-11s %d\n", label+":", len(paths))
+	for _, p := range paths {
+		fmt.Printf("  %s\n", p)
+	}
+}
+
+func init() {
+	repoCmd.AddCommand(repoStatusCmd)
+	repoStatusCmd.Flags().BoolVar(&repoStatusJSON, "json", false, "Print the status as JSON")
+}
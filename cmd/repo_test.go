@@ -1,44 +1,19 @@
 package cmd
 
 import (
+	"os"
+	"os/exec"
+	"path/filepath"
 	"slices"
+	"strings"
 	"testing"
-)
-
-func TestParseOverwrittenFilePaths_CheckoutUntracked(t *testing.T) {
-	out := `error: The following untracked working tree files would be overwritten by checkout:
-	.bashrc
-	.config/nvim/init.vim
-Please move or remove them before you switch branches.
-Aborting`
-
-	got := parseOverwrittenFilePaths(out)
-	want := []string{".bashrc", ".config/nvim/init.vim"}
-	if !slices.Equal(got, want) {
-		t.Fatalf("got %v, want %v", got, want)
-	}
-}
-
-func TestParseOverwrittenFilePaths_MergeLocalChanges(t *testing.T) {
-	out := `error: Your local changes to the following files would be overwritten by merge:
-	.zshrc
-Please commit your changes or stash them before you merge.
-Aborting`
 
-	got := parseOverwrittenFilePaths(out)
-	want := []string{".zshrc"}
-	if !slices.Equal(got, want) {
-		t.Fatalf("got %v, want %v", got, want)
-	}
-}
-
-func TestParseOverwrittenFilePaths_NoConflicts(t *testing.T) {
-	out := "Already up to date.\n"
-	got := parseOverwrittenFilePaths(out)
-	if len(got) != 0 {
-		t.Fatalf("got %v, want empty", got)
-	}
-}
+	"github.com/spf13/cobra"
+	"github.com/w31r4/dotm/config"
+	"github.com/w31r4/dotm/pkg/backup"
+	"github.com/w31r4/dotm/pkg/dotrepo"
+	"github.com/w31r4/dotm/pkg/scan"
+)
 
 func TestSafeRelPath(t *testing.T) {
 	tests := []struct {
@@ -68,3 +43,643 @@ func TestSafeRelPath(t *testing.T) {
 		})
 	}
 }
+
+// gitTestEnv isolates git from the user's configuration and gives commits
+// a fixed identity.
+func gitTestEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "dotm")
+	t.Setenv("GIT_AUTHOR_EMAIL", "dotm@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "dotm")
+	t.Setenv("GIT_COMMITTER_EMAIL", "dotm@example.com")
+}
+
+func gitRun(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	command := exec.Command("git", args...)
+	command.Dir = dir
+	out, err := command.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// dotfilesRemote creates a bare remote whose main branch holds files, and
+// a scratch clone used to push further commits to it.
+func dotfilesRemote(t *testing.T, files map[string]string) (bare, work string) {
+	t.Helper()
+	dir := t.TempDir()
+	bare = filepath.Join(dir, "remote.git")
+	work = filepath.Join(dir, "work")
+	gitRun(t, dir, "init", "--bare", "--initial-branch=main", bare)
+	gitRun(t, dir, "clone", bare, work)
+	gitRun(t, work, "checkout", "-b", "main")
+	for name, body := range files {
+		writeTestFile(t, filepath.Join(work, name), body)
+	}
+	gitRun(t, work, "add", ".")
+	gitRun(t, work, "commit", "-m", "initial")
+	gitRun(t, work, "push", "origin", "main")
+	return bare, work
+}
+
+func writeTestFile(t *testing.T, path, body string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// syncedHome clones remote as the dotfiles repo of a fresh $HOME the way
+// `repo sync` does, and returns the opened repository.
+func syncedHome(t *testing.T, bare string) *dotfiles {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+	t.Setenv("DOTM_DOTFILES_DIR", "")
+	gitDir := filepath.Join(home, ".dotfiles")
+	gitRun(t, home, "clone", "--bare", bare, gitDir)
+	gitRun(t, home, "--git-dir="+gitDir, "config", "status.showUntrackedFiles", "no")
+	repo, err := openDotfiles(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := openBackend(t, repo)
+	if err := r.TrackUpstream(); err != nil {
+		t.Fatalf("TrackUpstream: %v", err)
+	}
+	sync := &syncer{repo: r, gitDir: gitDir, workTree: home, backupBaseDir: filepath.Join(home, ".dotfiles-backup"), strategy: strategyBackup}
+	if _, err := sync.checkout(""); err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+	return repo
+}
+
+func openBackend(t *testing.T, repo *dotfiles) dotrepo.Repository {
+	t.Helper()
+	backend, err := dotrepo.New(dotrepo.CLI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := backend.Open(repo.gitDir, repo.workTree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRepoStatus(t *testing.T) {
+	gitTestEnv(t)
+	bare, work := dotfilesRemote(t, map[string]string{".zshrc": "zsh\n", ".gitconfig": "git\n", ".vimrc": "vim\n"})
+	repo := syncedHome(t, bare)
+
+	st, err := repoStatus(repo)
+	if err != nil {
+		t.Fatalf("repoStatus: %v", err)
+	}
+	if st.Branch != "main" || st.Upstream != "origin/main" || !st.clean() {
+		t.Fatalf("fresh checkout status = %+v, want clean main tracking origin/main", st)
+	}
+	if st.ShowUntrackedFiles != "no" || st.LastSync != nil {
+		t.Fatalf("fresh checkout status = %+v", st)
+	}
+
+	writeTestFile(t, filepath.Join(repo.workTree, ".zshrc"), "changed\n")
+	os.Remove(filepath.Join(repo.workTree, ".gitconfig"))
+	writeTestFile(t, filepath.Join(repo.workTree, "untracked"), "ignored\n")
+	writeTestFile(t, filepath.Join(repo.workTree, ".vimrc"), "stashed\n")
+	gitRun(t, repo.workTree, repo.gitArgs("stash", "push", "--", ".vimrc")...)
+	writeTestFile(t, filepath.Join(work, ".tmux.conf"), "tmux\n")
+	gitRun(t, work, "add", ".tmux.conf")
+	gitRun(t, work, "commit", "-m", "add tmux")
+	gitRun(t, work, "push", "origin", "main")
+	gitRun(t, repo.workTree, repo.gitArgs("fetch", "origin")...)
+	if err := recordSync(repo.gitDir); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err = repoStatus(repo)
+	if err != nil {
+		t.Fatalf("repoStatus: %v", err)
+	}
+	if !slices.Equal(st.Modified, []string{".zshrc"}) || !slices.Equal(st.Deleted, []string{".gitconfig"}) {
+		t.Errorf("modified = %v, deleted = %v", st.Modified, st.Deleted)
+	}
+	if st.Ahead != 0 || st.Behind != 1 {
+		t.Errorf("ahead/behind = %d/%d, want 0/1", st.Ahead, st.Behind)
+	}
+	if st.Stashes != 1 {
+		t.Errorf("stashes = %d, want 1", st.Stashes)
+	}
+	if st.LastSync == nil {
+		t.Error("LastSync not reported after recordSync")
+	}
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	gitTestEnv(t)
+	bare, work := dotfilesRemote(t, map[string]string{".zshrc": "zsh\n"})
+	repo := syncedHome(t, bare)
+	home := repo.workTree
+	backupDir := filepath.Join(home, ".dotfiles-backup")
+
+	// A local file is in the way of a file added upstream.
+	writeTestFile(t, filepath.Join(work, ".tmux.conf"), "repo\n")
+	gitRun(t, work, "add", ".tmux.conf")
+	gitRun(t, work, "commit", "-m", "add tmux")
+	gitRun(t, work, "push", "origin", "main")
+	writeTestFile(t, filepath.Join(home, ".tmux.conf"), "local\n")
+	sync := &syncer{repo: openBackend(t, repo), gitDir: repo.gitDir, workTree: home, backupBaseDir: backupDir, strategy: strategyBackup}
+	if _, err := sync.pull(); err != nil {
+		t.Fatalf("pull: %v", err)
+	}
+
+	snapshots, err := backup.List(backupDir)
+	if err != nil || len(snapshots) != 1 {
+		t.Fatalf("List = %v, %v; want one snapshot", snapshots, err)
+	}
+	s := snapshots[0]
+	if s.Operation != "pull" || s.WorkTree != home || !slices.Equal(s.Files, []string{".tmux.conf"}) {
+		t.Fatalf("manifest = %+v", s.Manifest)
+	}
+
+	// Declining leaves the checked-out file alone.
+	promptInput, promptReader = strings.NewReader("n\n"), nil
+	t.Cleanup(func() { promptInput, promptReader = os.Stdin, nil })
+	if err := restoreBackup(s, backupDir, []string{"~/.tmux.conf"}, false, false); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(home, ".tmux.conf")); string(data) != "repo\n" {
+		t.Fatalf("declined restore changed .tmux.conf to %q", data)
+	}
+
+	// Accepting restores it and backs up the replaced version.
+	promptInput, promptReader = strings.NewReader("y\n"), nil
+	if err := restoreBackup(s, backupDir, nil, false, false); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(home, ".tmux.conf")); string(data) != "local\n" {
+		t.Fatalf(".tmux.conf = %q after restore, want the backed-up version", data)
+	}
+	snapshots, err = backup.List(backupDir)
+	if err != nil || len(snapshots) != 2 {
+		t.Fatalf("List = %v, %v; want two snapshots", snapshots, err)
+	}
+	if r := snapshots[0]; r.Operation != "restore "+s.ID || !slices.Equal(r.Files, []string{".tmux.conf"}) {
+		t.Errorf("restore snapshot = %+v", r.Manifest)
+	}
+	if _, err := os.Stat(filepath.Join(s.Dir, ".tmux.conf")); err != nil {
+		t.Errorf("restore removed the file from its snapshot: %v", err)
+	}
+
+	if err := restoreBackup(s, backupDir, []string{"/etc/passwd"}, true, false); err == nil {
+		t.Error("restoring a path outside the work tree succeeded")
+	}
+}
+
+func TestLoadRepoSettings(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, env := range []string{"DOTM_DOTFILES_URL", "DOTM_DOTFILES_DIR", "DOTM_DOTFILES_BACKUP_DIR", "DOTM_DOTFILES_BRANCH", "DOTM_DOTFILES_WORK_TREE", "DOTM_DOTFILES_REPO"} {
+		t.Setenv(env, "")
+	}
+	oldConfigPath := configPath
+	t.Cleanup(func() { configPath = oldConfigPath })
+
+	// Without a config file, the defaults apply.
+	configPath = filepath.Join(home, "missing.yaml")
+	s, err := loadRepoSettings(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.url != "" || s.dir != filepath.Join(home, ".dotfiles") || s.backupDir != filepath.Join(home, ".dotfiles-backup") || s.branch != "" || s.workTree != home {
+		t.Fatalf("defaults = %+v", s)
+	}
+
+	configPath = filepath.Join(home, "config.yaml")
+	writeTestFile(t, configPath, `repo:
+  url: https://example.com/config.git
+  dir: ~/config-dir
+  branch: config-branch
+modules: {}
+`)
+	t.Setenv("DOTM_DOTFILES_DIR", "~/env-dir")
+	t.Setenv("DOTM_DOTFILES_BRANCH", "env-branch")
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String("branch", "", "")
+	cmd.Flags().String("url", "", "")
+	if err := cmd.Flags().Parse([]string{"--branch", "flag-branch"}); err != nil {
+		t.Fatal(err)
+	}
+	s, err = loadRepoSettings(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.url != "https://example.com/config.git" {
+		t.Errorf("url = %q, want the config value", s.url)
+	}
+	if s.dir != filepath.Join(home, "env-dir") {
+		t.Errorf("dir = %q, want the environment value", s.dir)
+	}
+	if s.branch != "flag-branch" {
+		t.Errorf("branch = %q, want the flag value", s.branch)
+	}
+	if s.workTree != home {
+		t.Errorf("workTree = %q, want the default", s.workTree)
+	}
+
+	// A repos: entry uses its own URL, directory and branch and shares the
+	// rest with repo:.
+	writeTestFile(t, configPath, `repo:
+  url: https://example.com/config.git
+  backup_dir: ~/backups
+  deny: ["*.secret"]
+repos:
+  - name: work
+    url: https://example.com/work.git
+    deny: ["*.corp"]
+modules: {}
+`)
+	t.Setenv("DOTM_DOTFILES_REPO", "work")
+	s, err = loadRepoSettings(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.name != "work" || s.url != "https://example.com/work.git" || s.branch != "" {
+		t.Errorf("named settings = %+v", s)
+	}
+	if s.dir != filepath.Join(home, ".dotfiles-work") || s.backupDir != filepath.Join(home, "backups") || !slices.Equal(s.deny, []string{"*.secret", "*.corp"}) {
+		t.Errorf("named settings = %+v, want the default dir and shared backup dir and deny list", s)
+	}
+	t.Setenv("DOTM_DOTFILES_REPO", "play")
+	if _, err := loadRepoSettings(nil); err == nil || !strings.Contains(err.Error(), "configured: work") {
+		t.Errorf("unknown repository: %v", err)
+	}
+	t.Setenv("DOTM_DOTFILES_REPO", "")
+
+	writeTestFile(t, configPath, "repo: [not, a, mapping]\n")
+	if _, err := loadRepoSettings(nil); err == nil {
+		t.Error("loadRepoSettings accepted an invalid config file")
+	}
+}
+
+func TestRepoInit(t *testing.T) {
+	gitTestEnv(t)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("DOTM_DOTFILES_DIR", "")
+	oldConfigPath := configPath
+	t.Cleanup(func() { configPath = oldConfigPath })
+	configPath = filepath.Join(home, "config.yaml")
+	writeTestFile(t, configPath, `repo:
+  backend: go-git
+  deny: ["*.secret"]
+  encryption:
+    tool: gpg
+modules:
+  jq: {}
+`)
+	writeTestFile(t, filepath.Join(home, ".zshrc"), "zsh\n")
+	writeTestFile(t, filepath.Join(home, ".config", "git", "config"), "git\n")
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	gitRun(t, home, "init", "--bare", remote)
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String("url", "", "")
+	if err := cmd.Flags().Parse([]string{"--url", remote}); err != nil {
+		t.Fatal(err)
+	}
+	settings, err := loadRepoSettings(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Added files are checked against repo.deny and the secret scanner.
+	writeTestFile(t, filepath.Join(home, ".config", "app", "api.secret"), "secret\n")
+	err = initDotfiles(settings, []string{".zshrc", "~/.config/app"}, true, false)
+	if err == nil || !strings.Contains(err.Error(), `.config/app/api.secret: matches deny pattern "*.secret"`) {
+		t.Fatalf("initDotfiles with a denied file = %v", err)
+	}
+	if _, err := os.Stat(settings.dir); !os.IsNotExist(err) {
+		t.Errorf("a refused init created the repo: %v", err)
+	}
+
+	// A failed first commit, e.g. without a git identity, leaves nothing
+	// behind, so init can be rerun.
+	t.Setenv("GIT_AUTHOR_NAME", "")
+	if err := initDotfiles(settings, []string{".zshrc"}, true, false); err == nil {
+		t.Fatal("initDotfiles without an author name succeeded")
+	}
+	if _, err := os.Stat(settings.dir); !os.IsNotExist(err) {
+		t.Errorf("a failed init left the repo behind: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".gitignore")); !os.IsNotExist(err) {
+		t.Errorf("a failed init left .gitignore behind: %v", err)
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "dotm")
+
+	if err := initDotfiles(settings, []string{filepath.Join(home, ".zshrc"), ".config/git/config"}, true, false); err != nil {
+		t.Fatalf("initDotfiles: %v", err)
+	}
+
+	repo, err := openDotfiles(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := strings.Fields(gitRun(t, home, repo.gitArgs("ls-files")...))
+	if !slices.Equal(files, []string{".config/git/config", ".gitignore", ".zshrc"}) {
+		t.Errorf("committed files = %v", files)
+	}
+	if got := gitRun(t, home, repo.gitArgs("config", "status.showUntrackedFiles")...); got != "no" {
+		t.Errorf("status.showUntrackedFiles = %q", got)
+	}
+	if data, _ := os.ReadFile(filepath.Join(home, ".gitignore")); !strings.Contains(string(data), "/.dotfiles/\n") {
+		t.Errorf(".gitignore does not ignore the repo:\n%s", data)
+	}
+
+	// The branch pushes to and tracks the new remote.
+	gitRun(t, home, repo.gitArgs("push", "origin", "main")...)
+	gitRun(t, home, repo.gitArgs("fetch", "origin")...)
+	if got := gitRun(t, home, repo.gitArgs("rev-parse", "--abbrev-ref", "main@{upstream}")...); got != "origin/main" {
+		t.Errorf("upstream = %q", got)
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := cfg.Repo; r == nil || r.URL != remote || r.Dir != "~/.dotfiles" || r.Branch != "main" || r.WorkTree != "" || r.BackupDir != "" {
+		t.Errorf("repo section = %+v", cfg.Repo)
+	}
+	if r := cfg.Repo; r == nil || r.Backend != "go-git" || !slices.Equal(r.Deny, []string{"*.secret"}) || r.Encryption == nil || r.Encryption.Tool != "gpg" {
+		t.Errorf("repo section lost settings init does not own: %+v", cfg.Repo)
+	}
+	if _, ok := cfg.Modules["jq"]; !ok {
+		t.Error("writing the repo section lost the modules")
+	}
+
+	if err := initDotfiles(settings, nil, false, false); err == nil {
+		t.Error("initDotfiles succeeded over an existing repo")
+	}
+}
+
+func TestRepoTrack(t *testing.T) {
+	gitTestEnv(t)
+	bare, _ := dotfilesRemote(t, map[string]string{".zshrc": "zsh\n"})
+	repo := syncedHome(t, bare)
+	home := repo.workTree
+	deny := append(slices.Clone(scan.DefaultDeny), "*.secret")
+
+	writeTestFile(t, filepath.Join(home, ".config", "nvim", "init.lua"), "vim.o.number = true\n")
+	writeTestFile(t, filepath.Join(home, ".config", "nvim", "lazy-lock.json"), "{}\n")
+	writeTestFile(t, filepath.Join(home, ".ssh", "id_ed25519"), "key\n")
+	writeTestFile(t, filepath.Join(home, ".ssh", "id_ed25519.pub"), "ssh-ed25519 AAAA\n")
+	writeTestFile(t, filepath.Join(home, "notes.secret"), "hunter2\n")
+	writeTestFile(t, filepath.Join(home, ".envrc"), "export GITHUB_TOKEN=ghp_"+strings.Repeat("x", 36)+"\n")
+	staged := func() []string {
+		return strings.Fields(gitRun(t, home, repo.gitArgs("diff", "--cached", "--name-only")...))
+	}
+
+	refused := []struct {
+		path, want string
+	}{
+		{"~/.ssh/id_ed25519", "deny pattern"},
+		{"~/.ssh", "deny pattern"},
+		{"notes.secret", "deny pattern"},
+		{"~/.envrc", "GitHub token"},
+		{"/etc/hosts", "path traversal"},
+		{"~/.dotfiles/config", "inside the dotfiles repository"},
+	}
+	for _, tt := range refused {
+		err := trackFiles(repo, deny, []string{"~/.config/nvim", tt.path}, false)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("track %s: err = %v, want it to mention %q", tt.path, err, tt.want)
+		}
+		if got := staged(); len(got) != 0 {
+			t.Fatalf("refused track of %s staged %v", tt.path, got)
+		}
+	}
+
+	if err := trackFiles(repo, deny, []string{"~/.config/nvim", filepath.Join(home, ".ssh", "id_ed25519.pub")}, false); err != nil {
+		t.Fatalf("track: %v", err)
+	}
+	if got, want := staged(), []string{".config/nvim/init.lua", ".config/nvim/lazy-lock.json", ".ssh/id_ed25519.pub"}; !slices.Equal(got, want) {
+		t.Errorf("staged = %v, want %v", got, want)
+	}
+
+	if err := untrackFiles(repo, []string{"~/.zshrc"}, false); err != nil {
+		t.Fatalf("untrack: %v", err)
+	}
+	if !slices.Contains(staged(), ".zshrc") {
+		t.Error("untrack did not stage the removal of .zshrc")
+	}
+	if _, err := os.Stat(filepath.Join(home, ".zshrc")); err != nil {
+		t.Errorf("untrack deleted the file: %v", err)
+	}
+}
+
+func TestSyncConflictStrategies(t *testing.T) {
+	gitTestEnv(t)
+	bare, work := dotfilesRemote(t, map[string]string{".profile": "profile\n"})
+	repo := syncedHome(t, bare)
+	home := repo.workTree
+	backupDir := filepath.Join(home, ".dotfiles-backup")
+
+	upstream := map[string]string{".gitconfig": "repo git\n", ".tmux.conf": "repo tmux\n", ".vimrc": "repo vim\n", ".zshrc": "repo zsh\n"}
+	for name, body := range upstream {
+		writeTestFile(t, filepath.Join(work, name), body)
+		writeTestFile(t, filepath.Join(home, name), "local "+strings.TrimPrefix(body, "repo "))
+	}
+	gitRun(t, work, "add", "-A")
+	gitRun(t, work, "commit", "-m", "add files")
+	gitRun(t, work, "push", "origin", "main")
+
+	// Conflicts come in path order: skip, merge, take, keep.
+	mergeTool := filepath.Join(t.TempDir(), "mergetool")
+	writeTestFile(t, mergeTool, "#!/bin/sh\ncat \"$1\" \"$2\" > \"$3\"\n")
+	if err := os.Chmod(mergeTool, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MERGETOOL", mergeTool)
+	promptInput, promptReader = strings.NewReader("s\nbogus\nm\nt\nk\n"), nil
+	t.Cleanup(func() { promptInput, promptReader = os.Stdin, nil })
+	sync := &syncer{repo: openBackend(t, repo), gitDir: repo.gitDir, workTree: home, backupBaseDir: backupDir, strategy: strategyAsk}
+	if _, err := sync.pull(); err != nil {
+		t.Fatalf("pull: %v", err)
+	}
+
+	want := map[string]string{
+		".gitconfig": "local git\n",
+		".tmux.conf": "local tmux\nrepo tmux\n",
+		".vimrc":     "repo vim\n",
+		".zshrc":     "local zsh\n",
+	}
+	for name, body := range want {
+		if data, _ := os.ReadFile(filepath.Join(home, name)); string(data) != body {
+			t.Errorf("%s = %q, want %q", name, data, body)
+		}
+	}
+	if got := gitRun(t, home, repo.gitArgs("show", "HEAD:.zshrc")...); got != "local zsh" {
+		t.Errorf("committed .zshrc = %q", got)
+	}
+	if got := gitRun(t, home, repo.gitArgs("log", "-1", "--format=%s")...); got != "Keep local changes to .tmux.conf, .zshrc" {
+		t.Errorf("commit message = %q", got)
+	}
+	if got := gitRun(t, home, repo.gitArgs("status", "--porcelain")...); got != "M .gitconfig" {
+		t.Errorf("status = %q, want only the skipped file modified", got)
+	}
+	snapshots, err := backup.List(backupDir)
+	if err != nil || len(snapshots) != 1 || len(snapshots[0].Files) != 4 {
+		t.Fatalf("List = %v, %v; want one snapshot of all four files", snapshots, err)
+	}
+
+	// theirs takes the repo version, backing up the local file.
+	gitRun(t, home, repo.gitArgs("checkout", "--", ".gitconfig")...)
+	gitRun(t, home, repo.gitArgs("push", "origin", "main")...)
+	gitRun(t, work, "pull", "origin", "main")
+	writeTestFile(t, filepath.Join(work, ".bashrc"), "repo bash\n")
+	gitRun(t, work, "add", "-A")
+	gitRun(t, work, "commit", "-m", "add bashrc")
+	gitRun(t, work, "push", "origin", "main")
+	writeTestFile(t, filepath.Join(home, ".bashrc"), "local bash\n")
+	sync.strategy = strategyTheirs
+	dir, err := sync.pull()
+	if err != nil || dir == "" {
+		t.Fatalf("pull = %q, %v; want a backup", dir, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, ".bashrc")); string(data) != "local bash\n" {
+		t.Errorf("backed-up .bashrc = %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(home, ".bashrc")); string(data) != "repo bash\n" {
+		t.Errorf(".bashrc = %q", data)
+	}
+}
+
+func TestRepoSave(t *testing.T) {
+	gitTestEnv(t)
+	bare, _ := dotfilesRemote(t, map[string]string{".zshrc": "zsh\n", ".vimrc": "vim\n"})
+	repo := syncedHome(t, bare)
+	home := repo.workTree
+
+	writeTestFile(t, filepath.Join(home, ".zshrc"), "zsh 2\n")
+	os.Remove(filepath.Join(home, ".vimrc"))
+	if err := saveDotfiles(repo, scan.DefaultDeny, "", true, false); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if got := gitRun(t, home, "--git-dir="+bare, "log", "-1", "--format=%B"); got != "Update .vimrc, .zshrc\n\ndeleted: .vimrc\nmodified: .zshrc" {
+		t.Errorf("pushed commit message = %q", got)
+	}
+
+	// Split the token so this file does not look like it holds one.
+	writeTestFile(t, filepath.Join(home, ".zshrc"), "zsh 2\nexport GITHUB_TOKEN=ghp_"+strings.Repeat("a1B2", 9)+"\n")
+	err := saveDotfiles(repo, scan.DefaultDeny, "leak", true, false)
+	if err == nil || !strings.Contains(err.Error(), ".zshrc:2: looks like a GitHub token") {
+		t.Fatalf("save with a token = %v", err)
+	}
+	if got := gitRun(t, home, repo.gitArgs("log", "-1", "--format=%s")...); got != "Update .vimrc, .zshrc" {
+		t.Errorf("save committed despite the token: %q", got)
+	}
+	if got := gitRun(t, home, repo.gitArgs("diff", "--cached", "--name-only")...); got != "" {
+		t.Errorf("save staged %q despite the token", got)
+	}
+
+	// Commits made elsewhere are pushed even without changes.
+	writeTestFile(t, filepath.Join(home, ".zshrc"), "zsh 3\n")
+	gitRun(t, home, repo.gitArgs("commit", "-qam", "by hand")...)
+	if err := saveDotfiles(repo, scan.DefaultDeny, "", true, false); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if got := gitRun(t, home, "--git-dir="+bare, "log", "-1", "--format=%s"); got != "by hand" {
+		t.Errorf("remote head = %q, want the hand-made commit", got)
+	}
+
+	// A token committed by hand is refused even if a later commit
+	// removes it again, since the push would still carry it.
+	writeTestFile(t, filepath.Join(home, ".zshrc"), "zsh 3\nexport GITHUB_TOKEN=ghp_"+strings.Repeat("a1B2", 9)+"\n")
+	gitRun(t, home, repo.gitArgs("commit", "-qam", "leak by hand")...)
+	writeTestFile(t, filepath.Join(home, ".zshrc"), "zsh 3\n")
+	gitRun(t, home, repo.gitArgs("commit", "-qam", "drop the token")...)
+	err = saveDotfiles(repo, scan.DefaultDeny, "", true, false)
+	if err == nil || !strings.Contains(err.Error(), ".zshrc:2: looks like a GitHub token") || !strings.Contains(err.Error(), "nothing was pushed") {
+		t.Fatalf("save with a committed token = %v", err)
+	}
+	if got := gitRun(t, home, "--git-dir="+bare, "log", "-1", "--format=%s"); got != "by hand" {
+		t.Errorf("remote head = %q, the token was pushed", got)
+	}
+}
+
+func TestRepoSyncAll(t *testing.T) {
+	gitTestEnv(t)
+	team, _ := dotfilesRemote(t, map[string]string{".tmux.conf": "team tmux\n", ".gitconfig": "team git\n"})
+	personal, _ := dotfilesRemote(t, map[string]string{".zshrc": "my zsh\n", ".gitconfig": "my git\n"})
+	extra, extraWork := dotfilesRemote(t, map[string]string{".vimrc": "vim\n"})
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+	t.Setenv("DOTM_DOTFILES_REPO", "")
+	oldConfigPath := configPath
+	t.Cleanup(func() { configPath = oldConfigPath })
+	configPath = filepath.Join(t.TempDir(), "config.yaml")
+	writeTestFile(t, configPath, `repos:
+  - name: personal
+    url: `+personal+`
+    priority: 20
+  - name: extra
+    url: `+extra+`
+    priority: 30
+  - name: team
+    url: `+team+`
+    priority: 10
+modules: {}
+`)
+
+	err := syncAll(syncCmd, strategyBackup, true)
+	if err == nil || !strings.Contains(err.Error(), "1 of 3 repositories were not synced: personal") {
+		t.Fatalf("syncAll = %v", err)
+	}
+	want := map[string]string{".tmux.conf": "team tmux\n", ".gitconfig": "team git\n", ".vimrc": "vim\n"}
+	for name, body := range want {
+		if data, _ := os.ReadFile(filepath.Join(home, name)); string(data) != body {
+			t.Errorf("%s = %q, want %q", name, data, body)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(home, ".zshrc")); !os.IsNotExist(err) {
+		t.Errorf("the overlapping repository was checked out: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".dotfiles-personal")); err != nil {
+		t.Errorf("the overlapping repository was not cloned: %v", err)
+	}
+
+	// A file of an earlier repository that only shows up upstream is
+	// reported before the pull rather than backed up and replaced.
+	writeTestFile(t, filepath.Join(extraWork, ".tmux.conf"), "extra tmux\n")
+	gitRun(t, extraWork, "add", ".")
+	gitRun(t, extraWork, "commit", "-m", "add tmux")
+	gitRun(t, extraWork, "push", "origin", "main")
+	err = syncAll(syncCmd, strategyBackup, true)
+	if err == nil || !strings.Contains(err.Error(), "2 of 3 repositories were not synced: personal, extra") {
+		t.Fatalf("second syncAll = %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(home, ".tmux.conf")); string(data) != "team tmux\n" {
+		t.Errorf(".tmux.conf = %q, want the team version", data)
+	}
+	backups, _ := filepath.Glob(filepath.Join(home, ".dotfiles-backup*", "*", ".tmux.conf"))
+	if len(backups) > 0 {
+		t.Errorf("the upstream overlap was backed up: %v", backups)
+	}
+
+	name, args, err := splitRepoFlag([]string{"--repo", "team", "log", "--oneline"})
+	if err != nil || name != "team" || !slices.Equal(args, []string{"log", "--oneline"}) {
+		t.Errorf("splitRepoFlag = %q, %v, %v", name, args, err)
+	}
+	if name, args, _ := splitRepoFlag([]string{"status", "--repo=x"}); name != "" || len(args) != 2 {
+		t.Errorf("splitRepoFlag took a --repo after the git command: %q %v", name, args)
+	}
+}
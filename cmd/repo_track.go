@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/w31r4/dotm/pkg/scan"
+)
+
+var repoTrackCmd = &cobra.Command{
+	Use:   "track <paths...>",
+	Short: "Stage files for the dotfiles repo, refusing secrets",
+	Long: `Stage files or directories in the work tree (your home directory) for the
+next commit to the dotfiles repo.
+
+Before anything is staged, every file that would be added is checked:
+  - paths outside the work tree, or inside the repository itself, are refused
+  - paths matching the deny list are refused: SSH and GPG keys, cloud and
+    registry credentials, *.pem and *.key files, and any patterns in
+    repo.deny in config.yaml
+  - file contents are scanned for private keys and API tokens
+
+If any file fails a check, nothing is staged. 'dotm repo git add' skips
+these checks for the rare false positive.
+
+With --encrypt, files are encrypted with the tool configured in
+repo.encryption and only the encrypted copies, kept below
+~/.config/dotm/secrets, are staged. 'dotm repo sync' decrypts them into
+place. See 'dotm secrets'.`,
+	Example: `  dotm repo track ~/.zshrc ~/.config/nvim
+  dotm repo git commit -m "Track zsh and nvim config"`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		settings, err := loadRepoSettings(cmd)
+		if err != nil {
+			return err
+		}
+		repo, err := settings.open()
+		if err != nil {
+			return err
+		}
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if encrypt, _ := cmd.Flags().GetBool("encrypt"); encrypt {
+			c, err := settings.cipher()
+			if err != nil {
+				return err
+			}
+			return trackEncrypted(repo, c, args, dryRun)
+		}
+		return trackFiles(repo, append(slices.Clone(scan.DefaultDeny), settings.deny...), args, dryRun)
+	},
+}
+
+var repoUntrackCmd = &cobra.Command{
+	Use:   "untrack <paths...>",
+	Short: "Stop tracking files without deleting them",
+	Long: `Remove files or directories from the dotfiles repo with the next commit.
+The files themselves stay in your home directory.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openDotfiles(cmd)
+		if err != nil {
+			return err
+		}
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		return untrackFiles(repo, args, dryRun)
+	},
+}
+
+// trackFiles stages paths after checking every file they contain against
+// the deny patterns and the secret scanner.
+func trackFiles(repo *dotfiles, deny []string, paths []string, dryRun bool) error {
+	rels, err := repo.relPaths(paths)
+	if err != nil {
+		return err
+	}
+	for _, rel := range rels {
+		if _, err := os.Lstat(filepath.Join(repo.workTree, rel)); err != nil {
+			return err
+		}
+	}
+
+	// The files `git add` would stage: tracked ones and untracked ones that
+	// are not ignored.
+	out, err := repo.output(append([]string{"ls-files", "-z", "--cached", "--others", "--exclude-standard", "--"}, rels...)...)
+	if err != nil {
+		return err
+	}
+	var files []string
+	for _, file := range strings.Split(out, "\x00") {
+		if file != "" && !slices.Contains(files, file) {
+			files = append(files, file)
+		}
+	}
+	problems, err := checkFiles(repo.workTree, deny, files)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("refusing to track files that may hold secrets (nothing was staged):\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("nothing to track: %s only contains ignored files", strings.Join(rels, ", "))
+	}
+
+	if _, err := runCommandInDir(dryRun, repo.workTree, "git", repo.gitArgs(append([]string{"add", "--"}, rels...)...)...); err != nil {
+		return err
+	}
+	if !dryRun {
+		fmt.Printf("Staged %d files. Commit them with: dotm repo git commit -m \"...\"\n", len(files))
+	}
+	return nil
+}
+
+// checkFiles checks files, slash-separated paths relative to workTree,
+// against the deny patterns and the secret scanner, and describes every
+// match.
+func checkFiles(workTree string, deny, files []string) ([]string, error) {
+	var problems []string
+	for _, file := range files {
+		if pattern, denied := scan.Denied(file, deny); denied {
+			problems = append(problems, fmt.Sprintf("%s: matches deny pattern %q", file, pattern))
+			continue
+		}
+		path := filepath.Join(workTree, filepath.FromSlash(file))
+		info, err := os.Lstat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			// Deleted files and symlinks have no content to scan.
+			continue
+		}
+		findings, err := scan.File(path)
+		if err != nil {
+			return nil, fmt.Errorf("scan %s: %w", file, err)
+		}
+		for _, f := range findings {
+			problems = append(problems, fmt.Sprintf("%s:%d: looks like a %s", file, f.Line, f.Rule))
+		}
+	}
+	return problems, nil
+}
+
+// untrackFiles removes paths from the index, keeping them in the work tree.
+func untrackFiles(repo *dotfiles, paths []string, dryRun bool) error {
+	rels, err := repo.relPaths(paths)
+	if err != nil {
+		return err
+	}
+	if _, err := runCommandInDir(dryRun, repo.workTree, "git", repo.gitArgs(append([]string{"rm", "-r", "--cached", "--quiet", "--"}, rels...)...)...); err != nil {
+		return err
+	}
+	if !dryRun {
+		fmt.Printf("Untracked %s; the files stay in %s. Commit with: dotm repo git commit -m \"...\"\n", strings.Join(rels, ", "), repo.workTree)
+	}
+	return nil
+}
+
+// relPaths converts paths given on the command line to paths relative to
+// the work tree. Paths outside the work tree or inside the repository are
+// refused.
+func (d *dotfiles) relPaths(paths []string) ([]string, error) {
+	gitDir, err := filepath.Rel(d.workTree, d.gitDir)
+	if err != nil {
+		gitDir = ""
+	}
+	var rels []string
+	for _, p := range paths {
+		rel, err := workTreeRelPath(p, d.workTree)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		if rel == gitDir || strings.HasPrefix(rel, gitDir+string(os.PathSeparator)) {
+			return nil, fmt.Errorf("%s: refusing path inside the dotfiles repository", p)
+		}
+		rels = append(rels, rel)
+	}
+	return rels, nil
+}
+
+func init() {
+	repoCmd.AddCommand(repoTrackCmd, repoUntrackCmd)
+	repoTrackCmd.Flags().Bool("encrypt", false, "Store the files encrypted (see 'dotm secrets')")
+	repoTrackCmd.Flags().Bool("dry-run", false, "Check the files and show the git command without staging")
+	repoUntrackCmd.Flags().Bool("dry-run", false, "Show the git command without running it")
+}
@@ -0,0 +1,477 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/w31r4/dotm/config"
+	"github.com/w31r4/dotm/pkg/backup"
+	"github.com/w31r4/dotm/pkg/crypt"
+)
+
+// secretsDir is where encrypted dotfiles are stored in the work tree. A
+// secret for ~/.netrc is kept as ~/.config/dotm/secrets/.netrc.age.
+var secretsDir = filepath.Join(".config", "dotm", "secrets")
+
+// defaultAgeIdentity is the age identity used when repo.encryption does
+// not name one.
+const defaultAgeIdentity = "~/.config/dotm/age.key"
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage encrypted dotfiles",
+	Long: `Encrypted dotfiles let you keep files such as ~/.netrc, tokens or VPN
+configs in the dotfiles repo. They are encrypted with age or gpg, as set in
+the repo.encryption section of config.yaml, and stored below
+~/.config/dotm/secrets. 'dotm repo sync' decrypts them into place with
+mode 0600.
+
+Add a file with 'dotm repo track --encrypt <file>' or 'dotm secrets edit
+<file>'.`,
+}
+
+var secretsInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Set up a key for encrypted dotfiles",
+	Long: `Configure encryption in the repo: section of config.yaml.
+
+With --tool age (the default), a new age identity is generated at
+~/.config/dotm/age.key unless --identity names an existing one. Copy the
+identity to your other machines; it never goes into the repo.
+
+With --tool gpg, files are encrypted to --recipient (default: your default
+key) and decrypted with your gpg keyring.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		settings, err := loadRepoSettings(cmd)
+		if err != nil {
+			return err
+		}
+		if settings.encryption != nil {
+			return fmt.Errorf("encryption is already configured with %s in %s", settings.encryption.Tool, settings.configPath)
+		}
+		tool, _ := cmd.Flags().GetString("tool")
+		identity, _ := cmd.Flags().GetString("identity")
+		recipients, _ := cmd.Flags().GetStringSlice("recipient")
+
+		spec := &config.EncryptionSpec{Tool: tool, Recipients: recipients}
+		switch tool {
+		case "age":
+			homeDir, err := os.UserHomeDir()
+			if err != nil {
+				return fmt.Errorf("get home dir: %w", err)
+			}
+			if identity == "" {
+				path, err := expandHomePath(defaultAgeIdentity, homeDir)
+				if err != nil {
+					return err
+				}
+				publicKey, err := crypt.GenerateIdentity(path)
+				if err != nil {
+					return fmt.Errorf("generate age identity: %w", err)
+				}
+				fmt.Printf("Generated age identity %s\nPublic key: %s\n", path, publicKey)
+				fmt.Println("Copy the identity to your other machines; keep it out of the repo.")
+			} else {
+				if _, err := os.Stat(identity); err != nil {
+					return fmt.Errorf("age identity: %w", err)
+				}
+				spec.Identity = tildePath(identity, homeDir)
+			}
+		case "gpg":
+		default:
+			return fmt.Errorf("unknown encryption tool %q (supported: %s)", tool, strings.Join(crypt.Tools, ", "))
+		}
+
+		repoSpec := config.RepoSpec{}
+		if cfg, err := config.LoadConfig(settings.configPath); err == nil && cfg.Repo != nil {
+			repoSpec = *cfg.Repo
+		}
+		repoSpec.Encryption = spec
+		if err := config.SetRepo(settings.configPath, repoSpec); err != nil {
+			return fmt.Errorf("failed to update %s: %w", settings.configPath, err)
+		}
+		fmt.Printf("Configured %s encryption in %s\n", tool, settings.configPath)
+		return nil
+	},
+}
+
+var secretsEditCmd = &cobra.Command{
+	Use:   "edit <file>",
+	Short: "Edit an encrypted dotfile",
+	Long: `Decrypt a secret into a private temporary file, open it in $VISUAL or
+$EDITOR, then encrypt the result back into the repo, stage it and update
+the decrypted file in place.
+
+<file> is the decrypted path, e.g. ~/.netrc. If it is not encrypted yet, the
+current file (or an empty one) is the starting point, so edit also adds new
+secrets.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		settings, err := loadRepoSettings(cmd)
+		if err != nil {
+			return err
+		}
+		repo, err := settings.open()
+		if err != nil {
+			return err
+		}
+		c, err := settings.cipher()
+		if err != nil {
+			return err
+		}
+		return editSecret(repo, c, args[0])
+	},
+}
+
+var secretsDecryptCmd = &cobra.Command{
+	Use:   "decrypt",
+	Short: "Decrypt all encrypted dotfiles into place",
+	Long: `Decrypt every encrypted dotfile in the repo into the work tree with mode
+0600, as 'dotm repo sync' does. Files that differ from the decrypted
+version are backed up first.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		settings, err := loadRepoSettings(cmd)
+		if err != nil {
+			return err
+		}
+		repo, err := settings.open()
+		if err != nil {
+			return err
+		}
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		return decryptSecrets(repo, settings, dryRun)
+	},
+}
+
+// cipher returns the Cipher configured in repo.encryption.
+func (s *repoSettings) cipher() (crypt.Cipher, error) {
+	if s.encryption == nil {
+		return nil, fmt.Errorf("encryption is not configured (run `dotm secrets init` or add repo.encryption to %s)", s.configPath)
+	}
+	opts := crypt.Options{Tool: s.encryption.Tool, Identity: s.encryption.Identity, Recipients: s.encryption.Recipients}
+	if opts.Tool == "age" {
+		if opts.Identity == "" {
+			opts.Identity = defaultAgeIdentity
+		}
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("get home dir: %w", err)
+		}
+		if opts.Identity, err = expandHomePath(opts.Identity, homeDir); err != nil {
+			return nil, fmt.Errorf("invalid repo.encryption.identity: %w", err)
+		}
+	}
+	return crypt.New(opts)
+}
+
+// secretPath returns where the secret for rel is stored, relative to the
+// work tree.
+func secretPath(rel string, c crypt.Cipher) string {
+	return filepath.Join(secretsDir, rel+c.Ext())
+}
+
+// secretTarget returns the decrypted path, relative to the work tree, of a
+// secret stored at rel, or false if rel is not a secret.
+func secretTarget(rel string) (string, bool) {
+	target, ok := strings.CutPrefix(rel, secretsDir+string(os.PathSeparator))
+	if !ok {
+		return "", false
+	}
+	for _, ext := range []string{".age", ".gpg"} {
+		if plain, ok := strings.CutSuffix(target, ext); ok && plain != "" {
+			return plain, true
+		}
+	}
+	return "", false
+}
+
+// trackEncrypted encrypts files into secretsDir and stages the encrypted
+// copies. The plaintext files are never staged.
+func trackEncrypted(repo *dotfiles, c crypt.Cipher, paths []string, dryRun bool) error {
+	rels, err := repo.relPaths(paths)
+	if err != nil {
+		return err
+	}
+	var secrets []string
+	for _, rel := range rels {
+		path := filepath.Join(repo.workTree, rel)
+		info, err := os.Lstat(path)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("%s: only regular files can be encrypted", rel)
+		}
+		if _, err := repo.output("ls-files", "--error-unmatch", "--", rel); err == nil {
+			return fmt.Errorf("%s is tracked in plain text; run `dotm repo untrack %s` first", rel, rel)
+		}
+		secret := secretPath(rel, c)
+		secrets = append(secrets, secret)
+		if dryRun {
+			fmt.Printf("[DRY RUN] Would encrypt %s to %s\n", rel, secret)
+			continue
+		}
+		plain, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := writeSecret(repo, c, secret, plain); err != nil {
+			return fmt.Errorf("encrypt %s: %w", rel, err)
+		}
+		fmt.Printf("Encrypted %s with %s\n", rel, c.Tool())
+	}
+	if _, err := runCommandInDir(dryRun, repo.workTree, "git", repo.gitArgs(append([]string{"add", "--"}, secrets...)...)...); err != nil {
+		return err
+	}
+	if !dryRun {
+		fmt.Printf("Staged %d encrypted files. Commit them with: dotm repo git commit -m \"...\"\n", len(secrets))
+	}
+	return nil
+}
+
+// writeSecret encrypts plain into the work tree at secret.
+func writeSecret(repo *dotfiles, c crypt.Cipher, secret string, plain []byte) error {
+	encrypted, err := c.Encrypt(plain)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(repo.workTree, secret)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, encrypted, 0644)
+}
+
+// decryptSecrets writes every tracked secret to its place in the work
+// tree with mode 0600. Files that differ from the decrypted content are
+// moved to a backup snapshot first.
+func decryptSecrets(repo *dotfiles, settings *repoSettings, dryRun bool) error {
+	if _, err := os.Stat(repo.gitDir); err != nil {
+		// Only possible in a dry run of the first sync.
+		return nil
+	}
+	out, err := repo.output("ls-files", "-z", "--", secretsDir)
+	if err != nil {
+		return err
+	}
+	var secrets []string
+	for _, rel := range strings.Split(out, "\x00") {
+		if _, ok := secretTarget(rel); ok {
+			secrets = append(secrets, rel)
+		}
+	}
+	if len(secrets) == 0 {
+		return nil
+	}
+	c, err := settings.cipher()
+	if err != nil {
+		return fmt.Errorf("skipping %d encrypted files: %w", len(secrets), err)
+	}
+
+	fmt.Println("Decrypting secrets...")
+	var manifest *backup.Manifest
+	var problems []string
+	for _, secret := range secrets {
+		target, _ := secretTarget(secret)
+		if filepath.Ext(secret) != c.Ext() {
+			problems = append(problems, fmt.Sprintf("%s: not encrypted with %s", secret, c.Tool()))
+			continue
+		}
+		if dryRun {
+			fmt.Printf("[DRY RUN] Would decrypt %s to %s\n", secret, target)
+			continue
+		}
+		encrypted, err := os.ReadFile(filepath.Join(repo.workTree, secret))
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		plain, err := c.Decrypt(encrypted)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", secret, err))
+			continue
+		}
+
+		path := filepath.Join(repo.workTree, target)
+		if current, err := os.ReadFile(path); err == nil {
+			if bytes.Equal(current, plain) {
+				if err := os.Chmod(path, 0600); err != nil {
+					problems = append(problems, err.Error())
+				}
+				continue
+			}
+			if manifest == nil {
+				now := time.Now()
+				manifest = &backup.Manifest{ID: backup.NewID(now), CreatedAt: now, Operation: "decrypt", GitDir: repo.gitDir, WorkTree: repo.workTree}
+			}
+			backupDir := filepath.Join(settings.backupDir, manifest.ID)
+			moved, err := backupPaths(repo.workTree, backupDir, []string{target}, false)
+			if err != nil {
+				problems = append(problems, err.Error())
+				continue
+			}
+			manifest.Files = append(manifest.Files, moved...)
+			if err := backup.WriteManifest(backupDir, manifest); err != nil {
+				problems = append(problems, fmt.Sprintf("write backup manifest: %v", err))
+			}
+			fmt.Printf("Backed up the previous %s to %s\n", target, backupDir)
+		}
+		if err := crypt.WriteFile(path, plain); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", target, err))
+			continue
+		}
+		fmt.Printf("Decrypted %s\n", target)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("could not decrypt some secrets:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// editSecret opens the decrypted content of a secret in an editor and
+// stores the result encrypted.
+func editSecret(repo *dotfiles, c crypt.Cipher, file string) error {
+	rel, err := workTreeRelPath(file, repo.workTree)
+	if err != nil {
+		return err
+	}
+	if target, ok := secretTarget(rel); ok {
+		rel = target
+	}
+	secret := secretPath(rel, c)
+	path := filepath.Join(repo.workTree, rel)
+
+	var original []byte
+	exists := false
+	if encrypted, err := os.ReadFile(filepath.Join(repo.workTree, secret)); err == nil {
+		if original, err = c.Decrypt(encrypted); err != nil {
+			return fmt.Errorf("decrypt %s: %w", secret, err)
+		}
+		exists = true
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	} else if original, err = os.ReadFile(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "dotm-secret-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	tmp := filepath.Join(tmpDir, filepath.Base(rel))
+	if err := crypt.WriteFile(tmp, original); err != nil {
+		return err
+	}
+	if err := runEditor(tmp); err != nil {
+		return err
+	}
+	edited, err := os.ReadFile(tmp)
+	if err != nil {
+		return err
+	}
+	if exists && bytes.Equal(edited, original) {
+		fmt.Println("No changes.")
+		return nil
+	}
+
+	if err := writeSecret(repo, c, secret, edited); err != nil {
+		return fmt.Errorf("encrypt %s: %w", rel, err)
+	}
+	if err := crypt.WriteFile(path, edited); err != nil {
+		return err
+	}
+	if _, err := runCommandInDir(false, repo.workTree, "git", repo.gitArgs("add", "--", secret)...); err != nil {
+		return err
+	}
+	fmt.Printf("Updated %s and staged %s. Commit with: dotm repo git commit -m \"...\"\n", path, secret)
+	return nil
+}
+
+// runEditor opens path in $VISUAL or $EDITOR (default vi). The variable
+// may hold arguments, e.g. "code --wait".
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	command := exec.Command("sh", "-c", editor+` "$1"`, "sh", path)
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+	if err := command.Run(); err != nil {
+		return fmt.Errorf("editor %s: %w", editor, err)
+	}
+	return nil
+}
+
+// applyDecrypt runs a module's "decrypt" apply step, writing the decrypted
+// source to the target with mode 0600.
+func applyDecrypt(step config.ApplyStep, dryRun bool) error {
+	settings, err := loadRepoSettings(nil)
+	if err != nil {
+		return err
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("get home dir: %w", err)
+	}
+	source, err := expandHomePath(step.Source, homeDir)
+	if err != nil {
+		return fmt.Errorf("invalid source: %w", err)
+	}
+	if !filepath.IsAbs(source) {
+		source = filepath.Join(filepath.Dir(settings.configPath), source)
+	}
+	target, err := expandHomePath(step.Target, homeDir)
+	if err != nil {
+		return fmt.Errorf("invalid target: %w", err)
+	}
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would decrypt %s to %s\n", source, target)
+		return nil
+	}
+
+	c, err := settings.cipher()
+	if err != nil {
+		return err
+	}
+	encrypted, err := os.ReadFile(source)
+	if err != nil {
+		return err
+	}
+	plain, err := c.Decrypt(encrypted)
+	if err != nil {
+		return err
+	}
+	if current, err := os.ReadFile(target); err == nil && bytes.Equal(current, plain) {
+		fmt.Printf("%s is up to date.\n", target)
+		return os.Chmod(target, 0600)
+	}
+	if err := crypt.WriteFile(target, plain); err != nil {
+		return err
+	}
+	fmt.Printf("Decrypted %s to %s\n", source, target)
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(secretsCmd)
+	secretsCmd.AddCommand(secretsInitCmd, secretsEditCmd, secretsDecryptCmd)
+	secretsInitCmd.Flags().String("tool", "age", "Encryption tool: age or gpg")
+	secretsInitCmd.Flags().String("identity", "", "Existing age identity file to use instead of generating one")
+	secretsInitCmd.Flags().StringSlice("recipient", nil, "Public key (age) or key ID (gpg) to encrypt to (repeatable)")
+	secretsDecryptCmd.Flags().Bool("dry-run", false, "List the files that would be decrypted")
+}
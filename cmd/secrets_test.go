@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/w31r4/dotm/config"
+)
+
+// fakeAge puts age and age-keygen stand-ins on PATH. The "ciphertext" is
+// the plaintext behind a header line.
+func fakeAge(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	scripts := map[string]string{
+		"age": `#!/bin/sh
+case "$1" in
+--encrypt) echo FAKE-AGE; cat ;;
+--decrypt) read -r header; cat ;;
+esac
+`,
+		"age-keygen": "#!/bin/sh\necho age1me\n",
+	}
+	for name, body := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestSecrets(t *testing.T) {
+	gitTestEnv(t)
+	fakeAge(t)
+	bare, _ := dotfilesRemote(t, map[string]string{".zshrc": "zsh\n"})
+	repo := syncedHome(t, bare)
+	home := repo.workTree
+	settings := &repoSettings{
+		dir:        repo.gitDir,
+		workTree:   home,
+		backupDir:  filepath.Join(home, ".dotfiles-backup"),
+		encryption: &config.EncryptionSpec{Tool: "age", Identity: "~/age.key", Recipients: []string{"age1me"}},
+	}
+	c, err := settings.cipher()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestFile(t, filepath.Join(home, ".netrc"), "machine example.com password one\n")
+	if err := trackEncrypted(repo, c, []string{"~/.netrc"}, false); err != nil {
+		t.Fatalf("track --encrypt: %v", err)
+	}
+	staged := gitRun(t, home, repo.gitArgs("diff", "--cached", "--name-only")...)
+	if staged != ".config/dotm/secrets/.netrc.age" {
+		t.Fatalf("staged = %q, want only the encrypted copy", staged)
+	}
+	if err := trackEncrypted(repo, c, []string{"~/.zshrc"}, false); err == nil {
+		t.Error("encrypting a file tracked in plain text succeeded")
+	}
+
+	// Editing re-encrypts and updates the decrypted file.
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", `sed -i.orig "s/one/two/"`)
+	if err := editSecret(repo, c, "~/.netrc"); err != nil {
+		t.Fatalf("edit: %v", err)
+	}
+	encrypted, _ := os.ReadFile(filepath.Join(home, secretsDir, ".netrc.age"))
+	if string(encrypted) != "FAKE-AGE\nmachine example.com password two\n" {
+		t.Errorf("encrypted secret = %q", encrypted)
+	}
+
+	// Sync decrypts into place with mode 0600, backing up a local version
+	// that differs.
+	gitRun(t, home, repo.gitArgs("commit", "-m", "add netrc")...)
+	writeTestFile(t, filepath.Join(home, ".netrc"), "stale\n")
+	if err := decryptSecrets(repo, settings, false); err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(home, ".netrc"))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf(".netrc mode = %v, %v", info, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(home, ".netrc")); string(data) != "machine example.com password two\n" {
+		t.Errorf(".netrc = %q", data)
+	}
+	matches, _ := filepath.Glob(filepath.Join(settings.backupDir, "*", ".netrc"))
+	if len(matches) != 1 {
+		t.Errorf("backed up .netrc copies = %v, want 1", matches)
+	}
+
+	// Without a key configured, sync reports the skipped secrets.
+	settings.encryption = nil
+	if err := decryptSecrets(repo, settings, false); err == nil || !strings.Contains(err.Error(), "skipping 1 encrypted files") {
+		t.Errorf("decrypt without encryption configured: err = %v", err)
+	}
+}
+
+func TestSecretTarget(t *testing.T) {
+	tests := []struct {
+		rel, want string
+		ok        bool
+	}{
+		{filepath.Join(secretsDir, ".netrc.age"), ".netrc", true},
+		{filepath.Join(secretsDir, ".config", "vpn", "work.conf.gpg"), filepath.Join(".config", "vpn", "work.conf"), true},
+		{filepath.Join(secretsDir, "README"), "", false},
+		{".netrc.age", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := secretTarget(tt.rel); got != tt.want || ok != tt.ok {
+			t.Errorf("secretTarget(%q) = %q, %v; want %q, %v", tt.rel, got, ok, tt.want, tt.ok)
+		}
+	}
+}
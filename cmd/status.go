@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/w31r4/dotm/config"
+	"github.com/w31r4/dotm/pkg/executor"
+	"github.com/w31r4/dotm/pkg/pkgmgr"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status [module...]",
+	Short: "Show which modules are installed",
+	Long: `Show whether modules from config.yaml are installed on this machine.
+Without arguments every module is listed. Installed versions are reported
+for modules with a version_cmd or a built-in install type, next to the
+version the module requires. An installed module whose version does not
+satisfy its constraint is reported as "unsatisfied".`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			log.Fatalf("Error loading config from %s: %v", configPath, err)
+		}
+
+		names := args
+		if len(names) == 0 {
+			for name := range cfg.Modules {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "MODULE\tSTATUS\tVERSION\tREQUIRED\tTYPE")
+		for _, name := range names {
+			module, ok := cfg.Modules[name]
+			if !ok {
+				log.Fatalf("Module '%s' not found in configuration", name)
+			}
+			st := moduleStatus(name, module)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name, st.State, dashIfEmpty(st.Version), dashIfEmpty(module.Version), strings.Join(st.Types, ","))
+		}
+		w.Flush()
+	},
+}
+
+// moduleState is the installation state of a module as reported by `status`.
+type moduleState struct {
+	State   string
+	Version string
+	Types   []string
+}
+
+// moduleStatus probes a module without changing anything.
+func moduleStatus(name string, module config.Module) moduleState {
+	st := moduleState{State: "unknown"}
+	installers := moduleInstallers(name, module)
+	for _, inst := range installers {
+		st.Types = append(st.Types, inst.kind())
+	}
+	_, pkgs, pkgErr := modulePackages(module)
+	if len(pkgs) > 0 {
+		st.Types = append(st.Types, "packages")
+	}
+	if _, ok := moduleInstallCommands(module); ok {
+		st.Types = append(st.Types, "commands")
+	}
+
+	if module.Check != "" {
+		session, err := newModuleSession(name, module, false)
+		if err != nil {
+			st.State = "error: " + err.Error()
+			return st
+		}
+		defer session.Close()
+		if err := session.RunWith(module.Check, executor.RunOptions{Quiet: true}); err == nil {
+			st.State = "installed"
+		} else {
+			st.State = "missing"
+		}
+	} else if len(installers) > 0 || len(pkgs) > 0 {
+		present, err := installersPresent(installers)
+		if err == nil && present && len(pkgs) > 0 {
+			var missing []string
+			missing, err = pkgmgr.Missing(packageManager, pkgs)
+			present = len(missing) == 0
+		}
+		switch {
+		case err != nil:
+			st.State = "error: " + err.Error()
+		case present:
+			st.State = "installed"
+		default:
+			st.State = "missing"
+		}
+	} else if pkgErr != nil {
+		st.State = "error: " + pkgErr.Error()
+	}
+
+	if module.VersionCmd != "" || module.Version != "" {
+		session, err := newModuleSession(name, module, false)
+		if err != nil {
+			st.State = "error: " + err.Error()
+			return st
+		}
+		defer session.Close()
+		if st.State == "installed" {
+			if _, ok, err := versionSatisfied(session, name, module); err != nil {
+				st.State = "error: " + err.Error()
+			} else if !ok {
+				st.State = "unsatisfied"
+			}
+		}
+		if module.VersionCmd != "" {
+			st.Version, _ = installedVersion(session, name, module)
+			return st
+		}
+	}
+
+	var versions []string
+	for _, inst := range installers {
+		if v, err := inst.version(); err == nil && v != "" {
+			versions = append(versions, v)
+		}
+	}
+	st.Version = strings.Join(versions, ", ")
+	return st
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+}
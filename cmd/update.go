@@ -0,0 +1,276 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/w31r4/dotm/config"
+	"github.com/w31r4/dotm/pkg/executor"
+	"github.com/w31r4/dotm/pkg/pkgmgr"
+)
+
+var updateAll bool
+var updateCheck bool
+
+var updateCmd = &cobra.Command{
+	Use:   "update [module...]",
+	Short: "Upgrade installed modules",
+	Long: `Bring installed modules up to date. Modules are visited together with
+their dependencies, dependencies first.
+
+For each module that is installed, dotm upgrades its packages, fetches
+git checkouts, reinstalls archives whose version changed in config.yaml,
+and finally runs the module's update commands. Modules whose check fails
+are not installed and are skipped.
+
+With --check, nothing is changed; dotm only reports which modules have
+updates available.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if updateAll && len(args) > 0 {
+			return fmt.Errorf("--all cannot be combined with module names")
+		}
+		if !updateAll && len(args) == 0 {
+			return fmt.Errorf("specify modules to update or use --all")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			log.Fatalf("Error loading config from %s: %v", configPath, err)
+		}
+
+		names := args
+		if updateAll {
+			for name := range cfg.Modules {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+		}
+		for _, name := range names {
+			if _, ok := cfg.Modules[name]; !ok {
+				log.Fatalf("Module '%s' not found in configuration", name)
+			}
+		}
+		plan := resolvePlan(cfg, names)
+
+		if updateCheck {
+			printUpdateCheck(cfg, plan)
+			return
+		}
+
+		if err := acquirePrivileges(updatePrivilegedSteps(cfg, plan), dryRun); err != nil {
+			log.Fatal(err)
+		}
+		defer privilege.Release()
+
+		for _, name := range plan {
+			if err := updateModule(name, cfg.Modules[name], dryRun); err != nil {
+				// log.Fatalf skips deferred calls.
+				privilege.Release()
+				log.Fatalf("Failed to update module %s: %v", name, err)
+			}
+		}
+		fmt.Println("\nAll requested modules are up to date!")
+	},
+}
+
+// moduleCheckPasses runs a module's check command outside of any dry run,
+// since checks only probe the system. Modules without a check pass.
+func moduleCheckPasses(name string, module config.Module) (bool, error) {
+	if module.Check == "" {
+		return true, nil
+	}
+	session, err := newModuleSession(name, module, false)
+	if err != nil {
+		return false, err
+	}
+	defer session.Close()
+	return session.RunWith(module.Check, executor.RunOptions{Quiet: true}) == nil, nil
+}
+
+// updateModule upgrades a single installed module.
+func updateModule(name string, module config.Module, dryRun bool) error {
+	fmt.Printf("--- Updating module: %s ---\n", name)
+
+	if ok, err := moduleCheckPasses(name, module); err != nil {
+		return err
+	} else if !ok {
+		fmt.Printf("Module is not installed; skipping (run `dotm install %s`).\n", name)
+		return nil
+	}
+
+	session, err := newModuleSession(name, module, dryRun)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	updated := false
+
+	manager, pkgs, err := modulePackages(module)
+	if err != nil {
+		return fmt.Errorf("module '%s' declares packages: %w", name, err)
+	}
+	if len(pkgs) > 0 {
+		installed, err := installedPackages(manager, pkgs)
+		if err != nil {
+			return err
+		}
+		if len(installed) > 0 {
+			fmt.Printf("Upgrading %s packages for %s...\n", manager.Name(), name)
+			if err := manager.Upgrade(session, installed); err != nil {
+				return fmt.Errorf("upgrading %s packages failed: %w", manager.Name(), err)
+			}
+			updated = true
+		}
+	}
+
+	for _, inst := range moduleInstallers(name, module) {
+		u, ok := inst.(updater)
+		if !ok {
+			continue
+		}
+		fmt.Printf("Updating %s with %s...\n", name, inst.kind())
+		if err := u.update(session); err != nil {
+			return fmt.Errorf("%s update failed: %w", inst.kind(), err)
+		}
+		updated = true
+	}
+
+	cmds := moduleUpdateCommands(module)
+	if len(cmds) > 0 {
+		fmt.Printf("Running update commands for %s...\n", name)
+	}
+	for _, cmd := range cmds {
+		if err := session.RunWith(cmd.Run, executor.RunOptions{
+			Privileged:  isPrivileged(module, cmd),
+			Interactive: cmd.Interactive,
+		}); err != nil {
+			return fmt.Errorf("update command '%s' failed: %w", cmd, err)
+		}
+		updated = true
+	}
+
+	if !updated {
+		fmt.Println("Nothing to update.")
+	}
+	return nil
+}
+
+// installedPackages returns the packages that are already installed; update
+// never installs missing ones.
+func installedPackages(manager pkgmgr.Manager, pkgs []string) ([]string, error) {
+	missing, err := pkgmgr.Missing(manager, pkgs)
+	if err != nil {
+		return nil, fmt.Errorf("query %s packages: %w", manager.Name(), err)
+	}
+	var installed []string
+	for _, pkg := range pkgs {
+		if !slices.Contains(missing, pkg) {
+			installed = append(installed, pkg)
+		}
+	}
+	return installed, nil
+}
+
+// updatePrivilegedSteps lists the update steps in the plan that need root.
+func updatePrivilegedSteps(cfg *config.Config, plan []string) []privilegedStep {
+	var steps []privilegedStep
+	for _, name := range plan {
+		module := cfg.Modules[name]
+		if m, pkgs, err := modulePackages(module); err == nil && len(pkgs) > 0 && m.Privileged() {
+			steps = append(steps, privilegedStep{Module: name, Command: m.Name() + " upgrade " + strings.Join(pkgs, " ")})
+		}
+		for _, cmd := range moduleUpdateCommands(module) {
+			if isPrivileged(module, cmd) {
+				steps = append(steps, privilegedStep{Module: name, Command: cmd.Run})
+			}
+		}
+	}
+	return steps
+}
+
+// updateState is what `update --check` reports for a module.
+type updateState struct {
+	Status string
+	Detail string
+}
+
+// checkModuleUpdate reports whether a module has an update available
+// without changing anything.
+func checkModuleUpdate(name string, module config.Module) updateState {
+	if ok, err := moduleCheckPasses(name, module); err != nil {
+		return updateState{Status: "error", Detail: err.Error()}
+	} else if !ok {
+		return updateState{Status: "not installed"}
+	}
+
+	var details []string
+	manager, pkgs, err := modulePackages(module)
+	if err != nil {
+		return updateState{Status: "error", Detail: err.Error()}
+	}
+	if len(pkgs) > 0 {
+		installed, err := installedPackages(manager, pkgs)
+		if err != nil {
+			return updateState{Status: "error", Detail: err.Error()}
+		}
+		outdated, err := manager.Outdated(installed)
+		if err != nil {
+			return updateState{Status: "error", Detail: err.Error()}
+		}
+		if len(outdated) > 0 {
+			details = append(details, manager.Name()+": "+strings.Join(outdated, ", "))
+		}
+	}
+
+	for _, inst := range moduleInstallers(name, module) {
+		u, ok := inst.(updater)
+		if !ok {
+			continue
+		}
+		detail, err := u.outdated()
+		if err != nil {
+			return updateState{Status: "error", Detail: fmt.Sprintf("%s: %v", inst.kind(), err)}
+		}
+		if detail != "" {
+			details = append(details, inst.kind()+": "+detail)
+		}
+	}
+
+	switch {
+	case len(details) > 0:
+		return updateState{Status: "update available", Detail: strings.Join(details, "; ")}
+	case module.Check == "" && moduleStatus(name, module).State == "missing":
+		return updateState{Status: "not installed"}
+	case len(moduleUpdateCommands(module)) > 0:
+		return updateState{Status: "unknown", Detail: "update commands always run"}
+	default:
+		return updateState{Status: "up to date"}
+	}
+}
+
+func printUpdateCheck(cfg *config.Config, plan []string) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MODULE\tSTATUS\tDETAIL")
+	for _, name := range plan {
+		st := checkModuleUpdate(name, cfg.Modules[name])
+		fmt.Fprintf(w, "%s\t%s\t%s\n", name, st.Status, dashIfEmpty(st.Detail))
+	}
+	w.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(updateCmd)
+	updateCmd.Flags().BoolVar(&updateAll, "all", false, "Update every module in config.yaml")
+	updateCmd.Flags().BoolVar(&updateCheck, "check", false, "Only report which modules have updates available")
+	updateCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the update commands without running them")
+	updateCmd.Flags().BoolVar(&noSudo, "no-sudo", false, "Never use sudo; fail early if the plan has privileged steps")
+}
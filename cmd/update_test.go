@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/w31r4/dotm/config"
+)
+
+func TestUpdateModule(t *testing.T) {
+	f := useFakeManager(t, "zsh")
+	marker := filepath.Join(t.TempDir(), "updated")
+	module := config.Module{
+		Packages: map[string][]string{"apt": {"zsh", "zsh-doc"}},
+		Update:   map[string][]config.Command{"default": config.Commands("touch " + marker)},
+	}
+
+	if err := updateModule("zsh", module, false); err != nil {
+		t.Fatalf("updateModule: %v", err)
+	}
+	if !slices.Equal(f.upgraded, []string{"zsh"}) {
+		t.Fatalf("upgraded = %v, want only the installed package", f.upgraded)
+	}
+	if len(f.calls) != 0 {
+		t.Fatalf("update installed missing packages: %v", f.calls)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatalf("update command did not run: %v", err)
+	}
+}
+
+func TestUpdateModule_SkipsWhenCheckFails(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "updated")
+	module := config.Module{
+		Check:  "false",
+		Update: map[string][]config.Command{"default": config.Commands("touch " + marker)},
+	}
+	if err := updateModule("tool", module, false); err != nil {
+		t.Fatalf("updateModule: %v", err)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Fatalf("update commands ran for a module that is not installed")
+	}
+}
+
+func TestCheckModuleUpdate(t *testing.T) {
+	f := useFakeManager(t, "git", "zsh")
+	f.outdated["git"] = true
+
+	tests := []struct {
+		name   string
+		module config.Module
+		want   string
+	}{
+		{"git", config.Module{Packages: map[string][]string{"apt": {"git"}}}, "update available"},
+		{"zsh", config.Module{Packages: map[string][]string{"apt": {"zsh"}}}, "up to date"},
+		{"fd", config.Module{Packages: map[string][]string{"apt": {"fd-find"}}}, "not installed"},
+		{"tool", config.Module{Check: "false", Install: map[string][]config.Command{"default": config.Commands("true")}}, "not installed"},
+		{"nvm", config.Module{Check: "true", Update: map[string][]config.Command{"default": config.Commands("true")}}, "unknown"},
+	}
+	for _, tt := range tests {
+		if got := checkModuleUpdate(tt.name, tt.module); got.Status != tt.want {
+			t.Errorf("%s: status = %q (%s), want %q", tt.name, got.Status, got.Detail, tt.want)
+		}
+	}
+}
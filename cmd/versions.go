@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/w31r4/dotm/config"
+	"github.com/w31r4/dotm/pkg/executor"
+	"github.com/w31r4/dotm/pkg/semver"
+)
+
+// installedVersion returns the version of a module found on this machine,
+// or "" if it cannot be determined. It uses version_cmd when set and
+// otherwise asks the module's install types.
+func installedVersion(s *executor.Session, name string, module config.Module) (string, error) {
+	if module.VersionCmd != "" {
+		out, err := s.Output(module.VersionCmd)
+		if err != nil {
+			return "", nil // the tool is missing or broken
+		}
+		return semver.Extract(out, module.VersionRegex)
+	}
+	for _, inst := range moduleInstallers(name, module) {
+		if v, err := inst.version(); err == nil && v != "" {
+			return v, nil
+		}
+	}
+	return "", nil
+}
+
+// versionSatisfied reports whether the installed version of a module meets
+// its `version:` constraint, together with the version that was found.
+// Modules without a constraint are always satisfied.
+func versionSatisfied(s *executor.Session, name string, module config.Module) (string, bool, error) {
+	if module.Version == "" {
+		return "", true, nil
+	}
+	constraint, err := semver.ParseConstraint(module.Version)
+	if err != nil {
+		return "", false, fmt.Errorf("module '%s': %w", name, err)
+	}
+	installed, err := installedVersion(s, name, module)
+	if err != nil || installed == "" {
+		return "", false, err
+	}
+	v, err := semver.Parse(installed)
+	if err != nil {
+		return installed, false, nil
+	}
+	return installed, constraint.Check(v), nil
+}
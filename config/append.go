@@ -0,0 +1,173 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AppendModules adds modules to the config file at path without rewriting
+// the rest of the file, so its comments and formatting are kept. Modules
+// are written in the order of names, below comment if it is not empty.
+// The file is created if it does not exist.
+func AppendModules(path string, names []string, modules map[string]Module, comment string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	out, err := appendModules(data, names, modules, comment)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return os.WriteFile(path, out, 0644)
+}
+
+// appendModules returns data with the modules inserted at the end of its
+// `modules:` mapping.
+func appendModules(data []byte, names []string, modules map[string]Module, comment string) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	indent := 2
+	lines := strings.SplitAfter(string(data), "\n")
+	at := len(lines)
+	header := ""
+
+	var root *yaml.Node
+	if len(doc.Content) > 0 {
+		root = doc.Content[0]
+		if root.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("top level is not a mapping")
+		}
+	}
+	modulesKey := -1
+	if root != nil {
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			if root.Content[i].Value == "modules" {
+				modulesKey = i
+				break
+			}
+		}
+	}
+	if modulesKey < 0 {
+		header = "modules:\n"
+	} else {
+		value := root.Content[modulesKey+1]
+		if value.Kind == yaml.MappingNode && value.Style&yaml.FlowStyle != 0 {
+			return nil, fmt.Errorf("modules is a flow mapping; rewrite it in block style to append to it")
+		}
+		if value.Kind == yaml.MappingNode && len(value.Content) > 0 {
+			indent = value.Content[0].Column - 1
+		}
+		// Insert before the next top-level key and the comments above it.
+		if next := modulesKey + 2; next < len(root.Content) {
+			at = root.Content[next].Line - 1
+			for at > 0 && isBlankOrComment(lines[at-1]) {
+				at--
+			}
+		}
+	}
+
+	var block strings.Builder
+	block.WriteString(header)
+	block.WriteString("\n")
+	pad := strings.Repeat(" ", indent)
+	if comment != "" {
+		block.WriteString(pad + "# " + comment + "\n")
+	}
+	for _, name := range names {
+		text, err := renderModule(name, modules[name])
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.SplitAfter(text, "\n") {
+			if line != "" {
+				block.WriteString(pad + line)
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	for i, line := range lines {
+		if i == at {
+			break
+		}
+		out.WriteString(line)
+	}
+	if out.Len() > 0 && !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+		out.WriteString("\n")
+	}
+	out.WriteString(block.String())
+	if at < len(lines) {
+		if strings.TrimSpace(lines[at]) != "" {
+			out.WriteString("\n")
+		}
+		for _, line := range lines[at:] {
+			out.WriteString(line)
+		}
+	}
+	return out.Bytes(), nil
+}
+
+func isBlankOrComment(line string) bool {
+	line = strings.TrimSpace(line)
+	return line == "" || strings.HasPrefix(line, "#")
+}
+
+// renderModule encodes a module as YAML, leaving out empty fields and
+// writing package lists and commands in the compact flow style used in
+// the sample config.
+func renderModule(name string, module Module) (string, error) {
+	var node yaml.Node
+	if err := node.Encode(module); err != nil {
+		return "", err
+	}
+	var content []*yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Value != "description" && isEmptyNode(value) {
+			continue
+		}
+		switch key.Value {
+		case "packages", "dependencies":
+			value.Style = yaml.FlowStyle
+		case "install", "update":
+			for j := 1; j < len(value.Content); j += 2 {
+				value.Content[j].Style = yaml.FlowStyle
+			}
+		}
+		content = append(content, key, value)
+	}
+	node.Content = content
+
+	wrapper := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+		{Kind: yaml.ScalarNode, Value: name},
+		&node,
+	}}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(wrapper); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func isEmptyNode(n *yaml.Node) bool {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		return n.Value == "" || n.Tag == "!!null"
+	case yaml.SequenceNode, yaml.MappingNode:
+		return len(n.Content) == 0
+	}
+	return false
+}
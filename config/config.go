@@ -1,6 +1,8 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"os"
 
 	"gopkg.in/yaml.v3"
@@ -8,16 +10,282 @@ import (
 
 // Config holds the entire configuration loaded from config.yaml
 type Config struct {
+	// Repo configures the bare dotfiles repository used by `dotm repo`.
+	Repo *RepoSpec `yaml:"repo,omitempty"`
+	// Repos are further dotfiles repositories, selected with --repo NAME
+	// and synced together by `repo sync --all`.
+	Repos   []NamedRepo       `yaml:"repos,omitempty"`
 	Modules map[string]Module `yaml:"modules"`
 }
 
+// RepoSpec describes the dotfiles repository. Paths may start with "~".
+// Empty fields fall back to the defaults of the repo commands.
+type RepoSpec struct {
+	// URL is cloned by `repo sync` when the repository does not exist yet.
+	URL string `yaml:"url,omitempty"`
+	// Dir is where the bare repository is stored (default ~/.dotfiles).
+	Dir string `yaml:"dir,omitempty"`
+	// BackupDir is where files in the way of a checkout are moved
+	// (default ~/.dotfiles-backup).
+	BackupDir string `yaml:"backup_dir,omitempty"`
+	// Branch is the branch to check out (default: the remote's default).
+	Branch string `yaml:"branch,omitempty"`
+	// WorkTree is the directory the repository checks out into (default ~).
+	WorkTree string `yaml:"work_tree,omitempty"`
+	// Backend is the git implementation `repo sync` uses: "cli" (default)
+	// or "go-git".
+	Backend string `yaml:"backend,omitempty"`
+	// Deny adds patterns to the paths `repo track` refuses, such as
+	// "~/.config/work/**" or "*.secret".
+	Deny []string `yaml:"deny,omitempty"`
+	// Encryption configures `repo track --encrypt` and `dotm secrets`.
+	Encryption *EncryptionSpec `yaml:"encryption,omitempty"`
+}
+
+// NamedRepo is an entry of the repos: list. Settings it leaves empty fall
+// back to the repo: section, except URL and Dir, which default to none and
+// ~/.dotfiles-<name>.
+type NamedRepo struct {
+	Name string `yaml:"name"`
+	// Priority orders `repo sync --all`: lower numbers are synced first.
+	Priority int `yaml:"priority,omitempty"`
+	RepoSpec `yaml:",inline"`
+}
+
+// FindRepo returns the repos: entry called name.
+func (c *Config) FindRepo(name string) (*NamedRepo, bool) {
+	for i := range c.Repos {
+		if c.Repos[i].Name == name {
+			return &c.Repos[i], true
+		}
+	}
+	return nil, false
+}
+
+// EncryptionSpec selects the tool and keys used for encrypted dotfiles.
+type EncryptionSpec struct {
+	// Tool is "age" or "gpg".
+	Tool string `yaml:"tool"`
+	// Identity is the age identity file (default ~/.config/dotm/age.key).
+	Identity string `yaml:"identity,omitempty"`
+	// Recipients are age public keys or gpg key IDs to encrypt to. They
+	// default to the identity's public key (age) or the default key (gpg).
+	Recipients []string `yaml:"recipients,omitempty"`
+}
+
 // Module represents a single installable unit (e.g., zsh, fzf).
 type Module struct {
-	Description  string              `yaml:"description"`
-	Dependencies []string            `yaml:"dependencies"`
-	Check        string              `yaml:"check"`
-	Install      map[string][]string `yaml:"install"`
-	Apply        []ApplyStep         `yaml:"apply"`
+	Description  string               `yaml:"description"`
+	Dependencies []string             `yaml:"dependencies"`
+	Check        string               `yaml:"check"`
+	Install      map[string][]Command `yaml:"install"`
+	Apply        []ApplyStep          `yaml:"apply"`
+	// Env is exported to every check and install command of the module.
+	Env map[string]string `yaml:"env,omitempty"`
+	// Workdir is the directory commands run in. It may use "~" and
+	// $DOTM_TMPDIR, the module's scratch directory.
+	Workdir string `yaml:"workdir,omitempty"`
+	// Shell selects the interpreter for commands: sh (default), bash or zsh.
+	Shell string `yaml:"shell,omitempty"`
+	// Privileged marks every install command of the module as needing root.
+	Privileged bool `yaml:"privileged,omitempty"`
+	// Packages maps a package manager (apt, brew, pacman, dnf) to the
+	// packages it should install. They are handled by built-in backends
+	// before any install commands run.
+	Packages map[string][]string `yaml:"packages,omitempty"`
+	// XCmd installs the module through x-cmd (`x env use`).
+	XCmd *XCmdSpec `yaml:"xcmd,omitempty"`
+	// Archive installs binaries from a release archive.
+	Archive *ArchiveSpec `yaml:"archive,omitempty"`
+	// Script runs a downloaded installer script.
+	Script *ScriptSpec `yaml:"script,omitempty"`
+	// Git clones a repository, such as a shell plugin.
+	Git *GitSpec `yaml:"git,omitempty"`
+	// Go, Cargo, UvTool and Npm install a tool with a language package
+	// manager. The module that provides the toolchain (go, rust, uv or
+	// node) becomes an implicit dependency when it exists.
+	Go     *ToolSpec `yaml:"go,omitempty"`
+	Cargo  *ToolSpec `yaml:"cargo,omitempty"`
+	UvTool *ToolSpec `yaml:"uv_tool,omitempty"`
+	Npm    *ToolSpec `yaml:"npm,omitempty"`
+	// Version is a constraint the installed version must satisfy, such as
+	// ">=1.25 <2". A module whose check passes but whose version does not
+	// satisfy it is installed again.
+	Version string `yaml:"version,omitempty"`
+	// VersionCmd prints the installed version. Without it, the version
+	// reported by the module's install type is used.
+	VersionCmd string `yaml:"version_cmd,omitempty"`
+	// VersionRegex extracts the version from VersionCmd's output; its first
+	// capture group is used if it has one. By default the first dotted
+	// number is taken.
+	VersionRegex string `yaml:"version_regex,omitempty"`
+	// Update maps an OS (or "default") to the commands `dotm update` runs
+	// after the built-in install types have been updated.
+	Update map[string][]Command `yaml:"update,omitempty"`
+}
+
+// GitSpec describes a git checkout managed by dotm.
+type GitSpec struct {
+	Repo string `yaml:"repo"`
+	// Dest is the checkout directory. It may use "~" and environment
+	// variables, including ${VAR:-default}.
+	Dest string `yaml:"dest"`
+	// Ref is a branch, tag or commit. It defaults to the remote's default
+	// branch.
+	Ref string `yaml:"ref,omitempty"`
+	// Depth makes a shallow clone when greater than zero.
+	Depth int `yaml:"depth,omitempty"`
+}
+
+// ToolSpec describes a tool installed with a language package manager. In
+// YAML it is either the package name or a mapping, e.g.
+// { package: golang.org/x/tools/gopls, version: v0.20.0 }.
+type ToolSpec struct {
+	Package string `yaml:"package"`
+	// Version pins the tool; empty means the latest release.
+	Version string `yaml:"version,omitempty"`
+}
+
+// UnmarshalYAML accepts both the string and the mapping form.
+func (t *ToolSpec) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&t.Package)
+	}
+	type plain ToolSpec
+	return node.Decode((*plain)(t))
+}
+
+// MarshalYAML writes unpinned tools back as plain strings.
+func (t ToolSpec) MarshalYAML() (any, error) {
+	if t.Version == "" {
+		return t.Package, nil
+	}
+	type plain ToolSpec
+	return plain(t), nil
+}
+
+// Tools returns the module's language package manager tools keyed by
+// install type.
+func (m Module) Tools() map[string]ToolSpec {
+	tools := make(map[string]ToolSpec)
+	for kind, spec := range map[string]*ToolSpec{"go": m.Go, "cargo": m.Cargo, "uv_tool": m.UvTool, "npm": m.Npm} {
+		if spec != nil {
+			tools[kind] = *spec
+		}
+	}
+	return tools
+}
+
+// ScriptSpec describes a remote installer script. The script is downloaded
+// to a local cache and run from there instead of being piped into a shell.
+type ScriptSpec struct {
+	URL string `yaml:"url"`
+	// SHA256 pins the script; a download that does not match is refused.
+	SHA256 string   `yaml:"sha256,omitempty"`
+	Args   []string `yaml:"args,omitempty"`
+	// Shell runs the script (default sh).
+	Shell       string `yaml:"shell,omitempty"`
+	Privileged  bool   `yaml:"privileged,omitempty"`
+	Interactive bool   `yaml:"interactive,omitempty"`
+}
+
+// ArchiveSpec describes a release archive (tar.gz, tar.xz, zip or a bare
+// binary) whose files are installed into a bin directory. URL and Files are
+// templates that can use {{.Version}}, {{.OS}}, {{.Arch}} and {{.Platform}}.
+type ArchiveSpec struct {
+	URL     string `yaml:"url"`
+	Version string `yaml:"version"`
+	// SHA256 maps a platform such as "linux-amd64" to the archive checksum.
+	SHA256 map[string]string `yaml:"sha256"`
+	// Files lists the paths (or glob patterns) inside the archive to
+	// install. It defaults to the module name.
+	Files []string `yaml:"files,omitempty"`
+	// BinDir is where files are installed (default ~/.local/bin).
+	BinDir string `yaml:"bin_dir,omitempty"`
+	// Platforms maps a platform to the name used in asset names, exposed
+	// to templates as {{.Platform}}.
+	Platforms map[string]string `yaml:"platforms,omitempty"`
+}
+
+// XCmdSpec describes an x-cmd package. In YAML it is either the package
+// name or a mapping, e.g. { package: node, version: v20.11.0 }.
+type XCmdSpec struct {
+	Package string `yaml:"package"`
+	// Version pins the package; empty means whatever x-cmd resolves.
+	Version string `yaml:"version,omitempty"`
+}
+
+// UnmarshalYAML accepts both the string and the mapping form.
+func (x *XCmdSpec) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&x.Package)
+	}
+	type plain XCmdSpec
+	return node.Decode((*plain)(x))
+}
+
+// MarshalYAML writes unpinned packages back as plain strings.
+func (x XCmdSpec) MarshalYAML() (any, error) {
+	if x.Version == "" {
+		return x.Package, nil
+	}
+	type plain XCmdSpec
+	return plain(x), nil
+}
+
+// Command is a single install step. In YAML it is either a plain string or
+// a mapping with options, e.g. { run: "apt-get install -y zsh", privileged: true }.
+type Command struct {
+	Run string `yaml:"run"`
+	// Privileged commands run as root: directly when dotm already runs as
+	// root, through sudo otherwise.
+	Privileged bool `yaml:"privileged,omitempty"`
+	// Interactive commands are connected to the terminal so they can prompt
+	// for input. Other commands read from /dev/null.
+	Interactive bool `yaml:"interactive,omitempty"`
+}
+
+// Commands builds plain commands from shell strings.
+func Commands(runs ...string) []Command {
+	cmds := make([]Command, 0, len(runs))
+	for _, run := range runs {
+		cmds = append(cmds, Command{Run: run})
+	}
+	return cmds
+}
+
+// String returns the shell command line.
+func (c Command) String() string {
+	return c.Run
+}
+
+// UnmarshalYAML accepts both the string and the mapping form.
+func (c *Command) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&c.Run)
+	}
+	type plain Command
+	return node.Decode((*plain)(c))
+}
+
+// MarshalYAML writes commands without options back as plain strings.
+func (c Command) MarshalYAML() (any, error) {
+	if c == (Command{Run: c.Run}) {
+		return c.Run, nil
+	}
+	type plain Command
+	return plain(c), nil
+}
+
+// Hash returns a stable digest of the module definition. It changes whenever
+// any field of the module changes in config.yaml.
+func (m Module) Hash() string {
+	data, err := yaml.Marshal(m)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
 }
 
 // ApplyStep defines a single action to configure a dotfile.
@@ -25,6 +293,9 @@ type ApplyStep struct {
 	Strategy string `yaml:"strategy"`
 	Target   string `yaml:"target"`
 	Line     string `yaml:"line"`
+	// Source is the encrypted file a "decrypt" step writes to Target,
+	// relative to config.yaml.
+	Source string `yaml:"source,omitempty"`
 }
 
 // LoadConfig reads and parses the config.yaml file from the given path.
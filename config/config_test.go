@@ -0,0 +1,150 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestCommand_UnmarshalBothForms(t *testing.T) {
+	src := `
+install:
+  debian:
+    - "echo plain"
+    - { run: "apt-get install -y zsh", privileged: true }
+`
+	var m Module
+	if err := yaml.Unmarshal([]byte(src), &m); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	got := m.Install["debian"]
+	want := []Command{{Run: "echo plain"}, {Run: "apt-get install -y zsh", Privileged: true}}
+	if len(got) != len(want) {
+		t.Fatalf("got %d commands, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("command %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCommand_MarshalPlainAsString(t *testing.T) {
+	data, err := yaml.Marshal(Commands("brew install zsh"))
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if strings.Contains(string(data), "run:") {
+		t.Fatalf("plain command marshaled as mapping:\n%s", data)
+	}
+}
+
+func TestAppendModules_KeepsComments(t *testing.T) {
+	src := `# My machines
+modules:
+  # Shell
+  zsh:
+    description: "Z shell"
+    packages: { apt: [zsh] }
+
+# Settings below modules stay below them.
+other: true
+`
+	modules := map[string]Module{
+		"wget": {Description: "wget (imported from apt)", Packages: map[string][]string{"apt": {"wget"}}},
+		"htop": {Description: "htop", Check: "command -v htop", Install: map[string][]Command{"macos": Commands("brew install --cask htop")}},
+	}
+	out, err := appendModules([]byte(src), []string{"wget", "htop"}, modules, "Imported from apt")
+	if err != nil {
+		t.Fatalf("appendModules: %v", err)
+	}
+	got := string(out)
+	want := `# My machines
+modules:
+  # Shell
+  zsh:
+    description: "Z shell"
+    packages: { apt: [zsh] }
+
+  # Imported from apt
+  wget:
+    description: wget (imported from apt)
+    packages: {apt: [wget]}
+  htop:
+    description: htop
+    check: command -v htop
+    install:
+      macos: [brew install --cask htop]
+
+# Settings below modules stay below them.
+other: true
+`
+	if got != want {
+		t.Fatalf("appendModules output:\n%s\nwant:\n%s", got, want)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(out, &cfg); err != nil {
+		t.Fatalf("result does not parse: %v", err)
+	}
+	if len(cfg.Modules) != 3 || cfg.Modules["htop"].Install["macos"][0].Run != "brew install --cask htop" {
+		t.Fatalf("parsed modules = %+v", cfg.Modules)
+	}
+}
+
+func TestAppendModules_EmptyFile(t *testing.T) {
+	out, err := appendModules(nil, []string{"jq"}, map[string]Module{"jq": {Description: "jq", Packages: map[string][]string{"brew": {"jq"}}}}, "")
+	if err != nil {
+		t.Fatalf("appendModules: %v", err)
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(out, &cfg); err != nil {
+		t.Fatalf("result does not parse: %v\n%s", err, out)
+	}
+	if _, ok := cfg.Modules["jq"]; !ok {
+		t.Fatalf("jq missing from:\n%s", out)
+	}
+}
+
+func TestSetRepo(t *testing.T) {
+	spec := RepoSpec{URL: "git@example.com:me/dotfiles.git", Dir: "~/.dotfiles", Branch: "main"}
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "empty file",
+			in:   "",
+			want: "repo:\n  url: git@example.com:me/dotfiles.git\n  dir: ~/.dotfiles\n  branch: main\n",
+		},
+		{
+			name: "insert above modules",
+			in:   "# my tools\nmodules:\n  jq: {}\n",
+			want: "# my tools\nrepo:\n  url: git@example.com:me/dotfiles.git\n  dir: ~/.dotfiles\n  branch: main\n\nmodules:\n  jq: {}\n",
+		},
+		{
+			name: "replace existing section",
+			in:   "# The repo\nrepo:\n  url: old\n  work_tree: ~/old # gone\n\n# Tools\nmodules:\n  jq: {}\n",
+			want: "# The repo\nrepo:\n  url: git@example.com:me/dotfiles.git\n  dir: ~/.dotfiles\n  branch: main\n\n# Tools\nmodules:\n  jq: {}\n",
+		},
+		{
+			name: "replace last section",
+			in:   "modules: {}\nrepo:\n  url: old\n",
+			want: "modules: {}\nrepo:\n  url: git@example.com:me/dotfiles.git\n  dir: ~/.dotfiles\n  branch: main\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := setRepo([]byte(tt.in), spec)
+			if err != nil {
+				t.Fatalf("setRepo: %v", err)
+			}
+			if string(out) != tt.want {
+				t.Fatalf("got:\n%s\nwant:\n%s", out, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SetRepo writes spec as the `repo:` section of the config file at path,
+// replacing an existing section. Like AppendModules, it leaves the rest of
+// the file untouched and creates the file if it does not exist.
+func SetRepo(path string, spec RepoSpec) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	out, err := setRepo(data, spec)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return os.WriteFile(path, out, 0644)
+}
+
+// setRepo returns data with its `repo:` section replaced by spec, or with
+// one added at the top.
+func setRepo(data []byte, spec RepoSpec) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	var root *yaml.Node
+	if len(doc.Content) > 0 {
+		root = doc.Content[0]
+		if root.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("top level is not a mapping")
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(map[string]RepoSpec{"repo": spec}); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	block := buf.String()
+
+	lines := strings.SplitAfter(string(data), "\n")
+	// Replace the lines from start up to end, or insert the block at start
+	// when start == end.
+	start, end := 0, 0
+	separator := ""
+	if root != nil && len(root.Content) > 0 {
+		start = root.Content[0].Line - 1
+		end = start
+		separator = "\n"
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			if root.Content[i].Value != "repo" {
+				continue
+			}
+			start = root.Content[i].Line - 1
+			end = len(lines)
+			separator = ""
+			if next := i + 2; next < len(root.Content) {
+				// Keep the blank lines and comments above the next key.
+				end = root.Content[next].Line - 1
+				for end > start+1 && isBlankOrComment(lines[end-1]) {
+					end--
+				}
+			}
+			break
+		}
+	}
+
+	var out bytes.Buffer
+	for _, line := range lines[:start] {
+		out.WriteString(line)
+	}
+	if out.Len() > 0 && !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+		out.WriteString("\n")
+	}
+	out.WriteString(block)
+	out.WriteString(separator)
+	for _, line := range lines[end:] {
+		out.WriteString(line)
+	}
+	return out.Bytes(), nil
+}
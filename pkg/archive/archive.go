@@ -0,0 +1,316 @@
+// Package archive downloads release archives, verifies their checksum and
+// installs the binaries they contain.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Platform returns the key used for per-platform settings, e.g. "linux-amd64".
+func Platform() string {
+	return runtime.GOOS + "-" + runtime.GOARCH
+}
+
+// Vars are the values available to URL and file templates.
+type Vars struct {
+	Version  string
+	OS       string
+	Arch     string
+	Platform string
+}
+
+// NewVars returns the template values for the current machine. platforms
+// may map a platform key to the name a project uses for it in release
+// asset names (e.g. "linux-amd64" -> "x86_64-unknown-linux-musl").
+func NewVars(version string, platforms map[string]string) Vars {
+	return VarsFor(Platform(), version, platforms)
+}
+
+// VarsFor returns the template values for another platform, given as a key
+// such as "darwin-arm64".
+func VarsFor(platform, version string, platforms map[string]string) Vars {
+	goos, goarch, _ := strings.Cut(platform, "-")
+	vars := Vars{Version: version, OS: goos, Arch: goarch, Platform: platform}
+	if name, ok := platforms[platform]; ok {
+		vars.Platform = name
+	}
+	return vars
+}
+
+// Expand renders a template such as a download URL.
+func Expand(text string, vars Vars) (string, error) {
+	tmpl, err := template.New("archive").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parse template %q: %w", text, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, vars); err != nil {
+		return "", fmt.Errorf("expand template %q: %w", text, err)
+	}
+	return b.String(), nil
+}
+
+// httpClient is used for downloads; tests may replace it.
+var httpClient = &http.Client{Timeout: 10 * time.Minute}
+
+// Download fetches url into dest and returns the hex sha256 of the body.
+func Download(url, dest string) (string, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download %s: %s", url, resp.Status)
+	}
+
+	f, err := os.Create(dest)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), resp.Body); err != nil {
+		return "", fmt.Errorf("download %s: %w", url, err)
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Verify compares a computed checksum with the expected one.
+func Verify(got, want string) error {
+	if !strings.EqualFold(got, strings.TrimSpace(want)) {
+		return fmt.Errorf("sha256 mismatch: got %s, want %s", got, want)
+	}
+	return nil
+}
+
+// Format guesses the archive format from a file name: "tar.gz", "tar.xz",
+// "tar.bz2", "tar", "zip", or "" for a bare binary.
+func Format(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tar.gz"
+	case strings.HasSuffix(lower, ".tar.xz"), strings.HasSuffix(lower, ".txz"):
+		return "tar.xz"
+	case strings.HasSuffix(lower, ".tar.bz2"), strings.HasSuffix(lower, ".tbz2"):
+		return "tar.bz2"
+	case strings.HasSuffix(lower, ".tar"):
+		return "tar"
+	case strings.HasSuffix(lower, ".zip"):
+		return "zip"
+	default:
+		return ""
+	}
+}
+
+// Install copies the files of an archive that match patterns into binDir
+// as executables and returns the installed paths. Patterns are matched with
+// path.Match against the full entry path and, for patterns without a slash,
+// against the entry's base name. Each file is written to a temporary file
+// in binDir and renamed into place, so a binary is never half-written.
+func Install(archivePath, format string, patterns []string, binDir string) ([]string, error) {
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		return nil, fmt.Errorf("create %s: %w", binDir, err)
+	}
+
+	var installed []string
+	matched := make(map[string]bool)
+	visit := func(name string, r io.Reader) error {
+		pattern, ok := match(name, patterns)
+		if !ok {
+			return nil
+		}
+		dest, err := installFile(r, binDir, path.Base(name))
+		if err != nil {
+			return err
+		}
+		matched[pattern] = true
+		installed = append(installed, dest)
+		return nil
+	}
+
+	var err error
+	switch format {
+	case "":
+		f, openErr := os.Open(archivePath)
+		if openErr != nil {
+			return nil, openErr
+		}
+		defer f.Close()
+		if len(patterns) != 1 {
+			return nil, fmt.Errorf("a bare binary download needs exactly one file name, got %d", len(patterns))
+		}
+		dest, installErr := installFile(f, binDir, path.Base(patterns[0]))
+		if installErr != nil {
+			return nil, installErr
+		}
+		return []string{dest}, nil
+	case "zip":
+		err = walkZip(archivePath, visit)
+	default:
+		err = walkTar(archivePath, format, visit)
+	}
+	if err != nil {
+		return installed, err
+	}
+
+	for _, pattern := range patterns {
+		if !matched[pattern] {
+			return installed, fmt.Errorf("no file matching %q in archive", pattern)
+		}
+	}
+	return installed, nil
+}
+
+func match(name string, patterns []string) (string, bool) {
+	name = strings.TrimPrefix(path.Clean(name), "./")
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return pattern, true
+		}
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, path.Base(name)); ok {
+				return pattern, true
+			}
+		}
+	}
+	return "", false
+}
+
+func installFile(r io.Reader, binDir, name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid file name %q", name)
+	}
+	dest := filepath.Join(binDir, name)
+	tmp, err := os.CreateTemp(binDir, "."+name+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("write %s: %w", name, err)
+	}
+	if err := tmp.Chmod(0755); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return dest, nil
+}
+
+func walkTar(archivePath, format string, visit func(string, io.Reader) error) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	switch format {
+	case "tar.gz":
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("open gzip stream: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	case "tar.bz2":
+		r = bzip2.NewReader(f)
+	case "tar.xz":
+		// The standard library has no xz decoder; use xz(1).
+		var stderr bytes.Buffer
+		cmd := exec.Command("xz", "-dc")
+		cmd.Stdin = f
+		cmd.Stderr = &stderr
+		out, err := cmd.StdoutPipe()
+		if err != nil {
+			return err
+		}
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("xz is required to extract .tar.xz archives: %w", err)
+		}
+		walkErr := walkTarStream(out, visit)
+		io.Copy(io.Discard, out)
+		if err := cmd.Wait(); err != nil && walkErr == nil {
+			return fmt.Errorf("xz: %w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return walkErr
+	case "tar":
+	default:
+		return fmt.Errorf("unsupported archive format %q", format)
+	}
+	return walkTarStream(r, visit)
+}
+
+func walkTarStream(r io.Reader, visit func(string, io.Reader) error) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read tar: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := visit(hdr.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func walkZip(archivePath string, visit func(string, io.Reader) error) error {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("open zip: %w", err)
+	}
+	defer zr.Close()
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		err = visit(zf.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
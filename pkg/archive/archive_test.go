@@ -0,0 +1,181 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, body := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func sum(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+func serve(t *testing.T, assets map[string][]byte) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := assets[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDownloadVerifyInstall_TarGz(t *testing.T) {
+	data := tarGz(t, map[string]string{
+		"rg-14.1.1/rg":        "#!/bin/sh\necho rg\n",
+		"rg-14.1.1/README.md": "docs",
+	})
+	srv := serve(t, map[string][]byte{"/rg-14.1.1.tar.gz": data})
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "rg.tar.gz")
+
+	got, err := Download(srv.URL+"/rg-14.1.1.tar.gz", dest)
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if err := Verify(got, sum(data)); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	binDir := filepath.Join(dir, "bin")
+	files, err := Install(dest, Format(dest), []string{"rg"}, binDir)
+	if err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if len(files) != 1 || files[0] != filepath.Join(binDir, "rg") {
+		t.Fatalf("installed %v, want [%s]", files, filepath.Join(binDir, "rg"))
+	}
+	info, err := os.Stat(files[0])
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if info.Mode().Perm()&0111 == 0 {
+		t.Fatalf("installed file is not executable: %v", info.Mode())
+	}
+	if _, err := os.Stat(filepath.Join(binDir, "README.md")); !os.IsNotExist(err) {
+		t.Fatalf("unrequested file was installed")
+	}
+}
+
+func TestInstall_Zip(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "tool.zip")
+	if err := os.WriteFile(src, zipArchive(t, map[string]string{"bin/tool": "binary"}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := Install(src, "zip", []string{"bin/tool"}, filepath.Join(dir, "bin"))
+	if err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if got, _ := os.ReadFile(files[0]); string(got) != "binary" {
+		t.Fatalf("got %q, want %q", got, "binary")
+	}
+}
+
+func TestInstall_TarXz(t *testing.T) {
+	if _, err := exec.LookPath("xz"); err != nil {
+		t.Skip("xz not installed")
+	}
+	dir := t.TempDir()
+	tarPath := filepath.Join(dir, "tool.tar")
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "tool", Mode: 0755, Size: 4, Typeflag: tar.TypeReg})
+	tw.Write([]byte("tool"))
+	tw.Close()
+	if err := os.WriteFile(tarPath, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("xz", tarPath).CombinedOutput(); err != nil {
+		t.Fatalf("xz: %v: %s", err, out)
+	}
+
+	if _, err := Install(tarPath+".xz", Format(tarPath+".xz"), []string{"tool"}, filepath.Join(dir, "bin")); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+}
+
+func TestInstall_MissingFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "tool.tar.gz")
+	if err := os.WriteFile(src, tarGz(t, map[string]string{"other": "x"}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Install(src, "tar.gz", []string{"tool"}, filepath.Join(dir, "bin")); err == nil {
+		t.Fatalf("expected an error for a missing file")
+	}
+}
+
+func TestVerify_Mismatch(t *testing.T) {
+	if err := Verify(sum([]byte("a")), sum([]byte("b"))); err == nil {
+		t.Fatalf("expected mismatch error")
+	}
+}
+
+func TestExpand(t *testing.T) {
+	vars := Vars{Version: "1.2.3", OS: "linux", Arch: "amd64", Platform: "x86_64-unknown-linux-musl"}
+	got, err := Expand("https://example.com/v{{.Version}}/tool-{{.Platform}}.tar.gz", vars)
+	if err != nil {
+		t.Fatalf("Expand: %v", err)
+	}
+	want := "https://example.com/v1.2.3/tool-x86_64-unknown-linux-musl.tar.gz"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
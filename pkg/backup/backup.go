@@ -0,0 +1,179 @@
+// Package backup manages the snapshots `dotm repo sync` makes of files that
+// were in the way of a checkout or pull. Each snapshot is a timestamped
+// directory that mirrors the home directory layout and holds a manifest.
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ManifestName is the manifest file inside a snapshot directory.
+const ManifestName = ".dotm-backup.json"
+
+// idLayout names snapshot directories.
+const idLayout = "20060102-150405.000000000"
+
+// Manifest describes a snapshot.
+type Manifest struct {
+	// ID is the name of the snapshot directory.
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	// Operation is the git command the files were in the way of, e.g.
+	// "checkout" or "pull --ff-only".
+	Operation string `json:"operation"`
+	GitDir    string `json:"git_dir,omitempty"`
+	WorkTree  string `json:"work_tree,omitempty"`
+	// Files are the backed-up paths, relative to the work tree.
+	Files []string `json:"files"`
+}
+
+// Snapshot is a snapshot directory and its manifest.
+type Snapshot struct {
+	Manifest
+	Dir string
+}
+
+// NewID returns the ID for a snapshot taken at t.
+func NewID(t time.Time) string {
+	return t.Format(idLayout)
+}
+
+// WriteManifest writes m into the snapshot directory dir.
+func WriteManifest(dir string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ManifestName), append(data, '\n'), 0644)
+}
+
+// List returns the snapshots in baseDir, newest first. Snapshots made
+// before manifests existed are listed with the files found in them and an
+// unknown operation.
+func List(baseDir string) ([]*Snapshot, error) {
+	entries, err := os.ReadDir(baseDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var snapshots []*Snapshot
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		s, err := load(filepath.Join(baseDir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+func load(dir string) (*Snapshot, error) {
+	s := &Snapshot{Dir: dir}
+	data, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if err == nil {
+		if err := json.Unmarshal(data, &s.Manifest); err != nil {
+			return nil, fmt.Errorf("read manifest of %s: %w", dir, err)
+		}
+		s.ID = filepath.Base(dir)
+		return s, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	s.ID = filepath.Base(dir)
+	s.Operation = "unknown"
+	if t, err := time.ParseInLocation(idLayout, s.ID, time.Local); err == nil {
+		s.CreatedAt = t
+	} else if info, err := os.Stat(dir); err == nil {
+		s.CreatedAt = info.ModTime()
+	}
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		s.Files = append(s.Files, rel)
+		return nil
+	})
+	return s, err
+}
+
+// Find returns the snapshot whose ID is id or starts with it.
+func Find(baseDir, id string) (*Snapshot, error) {
+	snapshots, err := List(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	var matches []*Snapshot
+	for _, s := range snapshots {
+		if s.ID == id {
+			return s, nil
+		}
+		if strings.HasPrefix(s.ID, id) {
+			matches = append(matches, s)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no backup %q in %s", id, baseDir)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf("backup id %q is ambiguous (%d matches)", id, len(matches))
+	}
+}
+
+// Prunable returns the snapshots a prune would remove: all but the newest
+// keep snapshots, limited to those older than olderThan when it is
+// positive. snapshots must be sorted newest first, as List returns them.
+func Prunable(snapshots []*Snapshot, keep int, olderThan time.Duration, now time.Time) []*Snapshot {
+	var prune []*Snapshot
+	for i, s := range snapshots {
+		if i < keep {
+			continue
+		}
+		if olderThan > 0 && now.Sub(s.CreatedAt) < olderThan {
+			continue
+		}
+		prune = append(prune, s)
+	}
+	return prune
+}
+
+// ParseAge parses a duration that may also use days ("30d") and weeks
+// ("2w").
+func ParseAge(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			v, err := strconv.Atoi(n)
+			if err != nil || v < 0 {
+				return 0, fmt.Errorf("invalid age %q", s)
+			}
+			return time.Duration(v) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age %q (use e.g. 30d, 2w or 12h)", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,109 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestListAndFind(t *testing.T) {
+	base := t.TempDir()
+	newer := time.Date(2025, 3, 2, 10, 0, 0, 0, time.Local)
+	dir := filepath.Join(base, NewID(newer))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	m := &Manifest{ID: NewID(newer), CreatedAt: newer, Operation: "pull --ff-only", Files: []string{".zshrc"}}
+	if err := WriteManifest(dir, m); err != nil {
+		t.Fatal(err)
+	}
+
+	// A snapshot made before manifests existed.
+	older := time.Date(2025, 3, 1, 9, 0, 0, 0, time.Local)
+	legacy := filepath.Join(base, NewID(older))
+	if err := os.MkdirAll(filepath.Join(legacy, ".config", "nvim"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(legacy, ".config", "nvim", "init.lua"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots, err := List(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 {
+		t.Fatalf("List returned %d snapshots, want 2", len(snapshots))
+	}
+	if s := snapshots[0]; s.Operation != "pull --ff-only" || !slices.Equal(s.Files, []string{".zshrc"}) || s.Dir != dir {
+		t.Errorf("newest snapshot = %+v", s)
+	}
+	if s := snapshots[1]; s.Operation != "unknown" || !s.CreatedAt.Equal(older) || !slices.Equal(s.Files, []string{filepath.Join(".config", "nvim", "init.lua")}) {
+		t.Errorf("legacy snapshot = %+v", s)
+	}
+
+	if s, err := Find(base, "20250301"); err != nil || s.Dir != legacy {
+		t.Errorf("Find(prefix) = %+v, %v", s, err)
+	}
+	if _, err := Find(base, "2025"); err == nil {
+		t.Error("Find with an ambiguous prefix succeeded")
+	}
+	if _, err := Find(base, "1999"); err == nil {
+		t.Error("Find with an unknown id succeeded")
+	}
+
+	if snapshots, err := List(filepath.Join(base, "missing")); err != nil || len(snapshots) != 0 {
+		t.Errorf("List(missing) = %v, %v", snapshots, err)
+	}
+}
+
+func TestPrunable(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	var snapshots []*Snapshot
+	for _, days := range []int{1, 10, 40, 90} {
+		s := &Snapshot{}
+		s.ID = NewID(now.AddDate(0, 0, -days))
+		s.CreatedAt = now.AddDate(0, 0, -days)
+		snapshots = append(snapshots, s)
+	}
+	ids := func(ss []*Snapshot) []string {
+		var out []string
+		for _, s := range ss {
+			out = append(out, s.ID)
+		}
+		return out
+	}
+
+	if got := Prunable(snapshots, 2, 0, now); !slices.Equal(ids(got), ids(snapshots[2:])) {
+		t.Errorf("keep 2 = %v", ids(got))
+	}
+	if got := Prunable(snapshots, 0, 30*24*time.Hour, now); !slices.Equal(ids(got), ids(snapshots[2:])) {
+		t.Errorf("older than 30d = %v", ids(got))
+	}
+	if got := Prunable(snapshots, 3, 30*24*time.Hour, now); !slices.Equal(ids(got), ids(snapshots[3:])) {
+		t.Errorf("keep 3, older than 30d = %v", ids(got))
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"30d", 30 * 24 * time.Hour},
+		{"2w", 14 * 24 * time.Hour},
+		{"12h", 12 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got, err := ParseAge(tt.in); err != nil || got != tt.want {
+			t.Errorf("ParseAge(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "d", "-1d", "soon"} {
+		if _, err := ParseAge(in); err == nil {
+			t.Errorf("ParseAge(%q) succeeded", in)
+		}
+	}
+}
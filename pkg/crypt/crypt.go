@@ -0,0 +1,183 @@
+// Package crypt encrypts and decrypts dotfiles with the age or gpg command
+// line tools, so secrets can be committed to the dotfiles repository.
+package crypt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Tools are the supported encryption tools.
+var Tools = []string{"age", "gpg"}
+
+// Cipher encrypts and decrypts file contents.
+type Cipher interface {
+	// Tool is "age" or "gpg".
+	Tool() string
+	// Ext is the extension of encrypted files, including the dot.
+	Ext() string
+	Encrypt(plain []byte) ([]byte, error)
+	Decrypt(cipher []byte) ([]byte, error)
+}
+
+// Options configure a Cipher.
+type Options struct {
+	// Tool is "age" or "gpg".
+	Tool string
+	// Identity is the age identity file used to decrypt. It is ignored by
+	// gpg, which uses its keyring.
+	Identity string
+	// Recipients are age public keys or gpg key IDs to encrypt to. age
+	// always adds the identity's public key, so files stay decryptable
+	// here; gpg defaults to the default key.
+	Recipients []string
+}
+
+// New returns the Cipher for opts.
+func New(opts Options) (Cipher, error) {
+	switch opts.Tool {
+	case "age":
+		if opts.Identity == "" {
+			return nil, fmt.Errorf("age needs an identity file")
+		}
+		return &Age{Identity: opts.Identity, Recipients: opts.Recipients}, nil
+	case "gpg":
+		return &GPG{Recipients: opts.Recipients}, nil
+	default:
+		return nil, fmt.Errorf("unknown encryption tool %q (supported: %s)", opts.Tool, strings.Join(Tools, ", "))
+	}
+}
+
+// Age encrypts with age (https://age-encryption.org).
+type Age struct {
+	Identity   string
+	Recipients []string
+}
+
+func (a *Age) Tool() string { return "age" }
+
+func (a *Age) Ext() string { return ".age" }
+
+// Encrypt encrypts to the identity's public key and any other recipients,
+// so the file can always be decrypted with the identity it was made with.
+func (a *Age) Encrypt(plain []byte) ([]byte, error) {
+	out, err := run(nil, "age-keygen", "-y", a.Identity)
+	if err != nil {
+		return nil, fmt.Errorf("read public key of %s: %w", a.Identity, err)
+	}
+	recipients := strings.Fields(string(out))
+	for _, r := range a.Recipients {
+		if !slices.Contains(recipients, r) {
+			recipients = append(recipients, r)
+		}
+	}
+	args := []string{"--encrypt", "--armor"}
+	for _, r := range recipients {
+		args = append(args, "--recipient", r)
+	}
+	return run(plain, "age", args...)
+}
+
+func (a *Age) Decrypt(cipher []byte) ([]byte, error) {
+	return run(cipher, "age", "--decrypt", "--identity", a.Identity)
+}
+
+// GenerateIdentity creates a new age identity at path, readable only by
+// the owner, and returns its public key.
+func GenerateIdentity(path string) (string, error) {
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("%s already exists", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	if _, err := run(nil, "age-keygen", "-o", path); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		return "", err
+	}
+	out, err := run(nil, "age-keygen", "-y", path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// GPG encrypts with GnuPG.
+type GPG struct {
+	Recipients []string
+}
+
+func (g *GPG) Tool() string { return "gpg" }
+
+func (g *GPG) Ext() string { return ".gpg" }
+
+func (g *GPG) Encrypt(plain []byte) ([]byte, error) {
+	args := []string{"--batch", "--yes", "--armor", "--encrypt"}
+	if len(g.Recipients) == 0 {
+		args = append(args, "--default-recipient-self")
+	}
+	for _, r := range g.Recipients {
+		args = append(args, "--recipient", r)
+	}
+	return run(plain, "gpg", args...)
+}
+
+func (g *GPG) Decrypt(cipher []byte) ([]byte, error) {
+	return run(cipher, "gpg", "--batch", "--quiet", "--decrypt")
+}
+
+// run pipes stdin through a command and returns its stdout.
+func run(stdin []byte, name string, args ...string) ([]byte, error) {
+	command := exec.Command(name, args...)
+	if stdin != nil {
+		command.Stdin = bytes.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+	out, err := command.Output()
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("%s is not installed", name)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %s", name, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return out, nil
+}
+
+// WriteFile writes plaintext to path atomically with mode 0600, creating
+// parent directories.
+func WriteFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	// CreateTemp already uses 0600, but be explicit about it.
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
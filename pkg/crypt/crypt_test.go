@@ -0,0 +1,141 @@
+package crypt
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeAge puts age and age-keygen stand-ins on PATH. "Encryption" prefixes
+// the plaintext with a header naming the recipients.
+func fakeAge(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	scripts := map[string]string{
+		"age": `#!/bin/sh
+case "$1" in
+--encrypt) shift; echo "FAKE-AGE $*"; cat ;;
+--decrypt) [ -f "$3" ] || { echo "no identity $3" >&2; exit 1; }; read -r header; cat ;;
+esac
+`,
+		"age-keygen": `#!/bin/sh
+case "$1" in
+-y) echo age1fake ;;
+-o) echo AGE-SECRET-KEY-FAKE > "$2" ;;
+esac
+`,
+	}
+	for name, body := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestAge(t *testing.T) {
+	fakeAge(t)
+	identity := filepath.Join(t.TempDir(), "keys", "age.key")
+	publicKey, err := GenerateIdentity(identity)
+	if err != nil || publicKey != "age1fake" {
+		t.Fatalf("GenerateIdentity = %q, %v", publicKey, err)
+	}
+	if info, err := os.Stat(identity); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("identity mode = %v, %v", info, err)
+	}
+	if _, err := GenerateIdentity(identity); err == nil {
+		t.Error("GenerateIdentity overwrote an existing identity")
+	}
+
+	c, err := New(Options{Tool: "age", Identity: identity})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := c.Encrypt([]byte("machine example.com password hunter2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without recipients, age encrypts to the identity's public key.
+	if !strings.HasPrefix(string(encrypted), "FAKE-AGE --armor --recipient age1fake\n") {
+		t.Errorf("encrypted = %q", encrypted)
+	}
+	plain, err := c.Decrypt(encrypted)
+	if err != nil || string(plain) != "machine example.com password hunter2\n" {
+		t.Errorf("Decrypt = %q, %v", plain, err)
+	}
+
+	// Explicit recipients are added to the identity's own key, not used
+	// instead of it.
+	shared, err := New(Options{Tool: "age", Identity: identity, Recipients: []string{"age1laptop", "age1fake"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err = shared.Encrypt([]byte("token\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(encrypted), "FAKE-AGE --armor --recipient age1fake --recipient age1laptop\n") {
+		t.Errorf("encrypted with recipients = %q", encrypted)
+	}
+
+	missing, _ := New(Options{Tool: "age", Identity: filepath.Join(t.TempDir(), "missing")})
+	if _, err := missing.Decrypt(encrypted); err == nil || !strings.Contains(err.Error(), "no identity") {
+		t.Errorf("Decrypt without identity: err = %v", err)
+	}
+}
+
+func TestGPG(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg is not installed")
+	}
+	home, err := os.MkdirTemp("", "gpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		exec.Command("gpgconf", "--homedir", home, "--kill", "all").Run()
+		os.RemoveAll(home)
+	})
+	t.Setenv("GNUPGHOME", home)
+	if out, err := exec.Command("gpg", "--batch", "--passphrase", "", "--quick-generate-key", "dotm@example.com", "default", "default", "never").CombinedOutput(); err != nil {
+		t.Skipf("cannot generate a gpg key: %v\n%s", err, out)
+	}
+
+	c, err := New(Options{Tool: "gpg", Recipients: []string{"dotm@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := c.Encrypt([]byte("token\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(encrypted), "BEGIN PGP MESSAGE") {
+		t.Errorf("encrypted = %q", encrypted)
+	}
+	plain, err := c.Decrypt(encrypted)
+	if err != nil || string(plain) != "token\n" {
+		t.Errorf("Decrypt = %q, %v", plain, err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(Options{Tool: "rot13"}); err == nil {
+		t.Error("New accepted an unknown tool")
+	}
+	if _, err := New(Options{Tool: "age"}); err == nil {
+		t.Error("New accepted age without an identity")
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "private", ".netrc")
+	if err := WriteFile(path, []byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("mode = %v, %v", info, err)
+	}
+}
@@ -0,0 +1,269 @@
+package dotrepo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// cliBackend runs the git command.
+type cliBackend struct{}
+
+func (cliBackend) Name() string { return CLI }
+
+func (cliBackend) Clone(url, gitDir, branch string) error {
+	args := []string{"clone", "--bare"}
+	if branch != "" {
+		args = append(args, "--branch", branch)
+	}
+	_, err := runGit("", append(args, "--", url, gitDir)...)
+	return err
+}
+
+func (cliBackend) Open(gitDir, workTree string) (Repository, error) {
+	if _, err := os.Stat(gitDir); err != nil {
+		return nil, err
+	}
+	return &cliRepo{gitDir: gitDir, workTree: workTree}, nil
+}
+
+type cliRepo struct {
+	gitDir, workTree string
+}
+
+func (r *cliRepo) git(args ...string) (string, error) {
+	return runGit(r.workTree, append([]string{"--git-dir=" + r.gitDir, "--work-tree=" + r.workTree}, args...)...)
+}
+
+func (r *cliRepo) SetConfig(key, value string) error {
+	_, err := r.git("config", key, value)
+	return err
+}
+
+func (r *cliRepo) TrackUpstream() error {
+	if _, err := r.git("config", "--get", "remote.origin.fetch"); err == nil {
+		return nil
+	}
+	if _, err := r.git("config", "remote.origin.fetch", "+refs/heads/*:refs/remotes/origin/*"); err != nil {
+		return err
+	}
+	if _, err := r.git("fetch", "origin"); err != nil {
+		return err
+	}
+	branch, err := r.git("symbolic-ref", "--short", "HEAD")
+	if err != nil {
+		return err
+	}
+	_, err = r.git("branch", "--set-upstream-to=origin/"+branch, branch)
+	return err
+}
+
+func (r *cliRepo) Checkout(branch string) error {
+	target, err := r.resolve(branch)
+	if err != nil {
+		return err
+	}
+	args := []string{"checkout"}
+	if target != "HEAD" {
+		// git creates the local branch from origin/<branch> when needed.
+		args = append(args, branch)
+	}
+
+	conflicts, err := r.conflicts(target)
+	if err != nil {
+		return err
+	}
+	if err := conflictError("checkout", conflicts); err != nil {
+		return err
+	}
+	if _, err := r.git(args...); err != nil {
+		return err
+	}
+	return r.trackOrigin(branch)
+}
+
+// resolve returns the ref branch refers to: HEAD for "" or the current
+// branch, otherwise the local branch or origin's.
+func (r *cliRepo) resolve(branch string) (string, error) {
+	if current, _ := r.git("symbolic-ref", "--quiet", "--short", "HEAD"); branch == "" || branch == current {
+		return "HEAD", nil
+	}
+	for _, ref := range []string{"refs/heads/" + branch, "refs/remotes/origin/" + branch} {
+		if _, err := r.git("rev-parse", "--verify", "--quiet", ref+"^{commit}"); err == nil {
+			return ref, nil
+		}
+	}
+	return "", fmt.Errorf("branch %q not found locally or on origin", branch)
+}
+
+func (r *cliRepo) Files(branch string) ([]string, error) {
+	target, err := r.resolve(branch)
+	if err != nil {
+		return nil, err
+	}
+	return r.treeFiles(target)
+}
+
+// treeFiles lists the files in the tree of rev.
+func (r *cliRepo) treeFiles(rev string) ([]string, error) {
+	files, err := r.paths("ls-tree", "-r", "-z", "--name-only", rev)
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range files {
+		files[i] = filepath.FromSlash(f)
+	}
+	return files, nil
+}
+
+// trackOrigin sets origin/<branch> as the upstream of branch if it has
+// none. A bare clone copies every remote branch without upstreams.
+func (r *cliRepo) trackOrigin(branch string) error {
+	if branch == "" {
+		return nil
+	}
+	if _, err := r.git("rev-parse", "--verify", "--quiet", branch+"@{upstream}"); err == nil {
+		return nil
+	}
+	if _, err := r.git("rev-parse", "--verify", "--quiet", "refs/remotes/origin/"+branch); err != nil {
+		return nil
+	}
+	_, err := r.git("branch", "--set-upstream-to=origin/"+branch, branch)
+	return err
+}
+
+func (r *cliRepo) Fetch() error {
+	_, err := r.git("fetch")
+	return err
+}
+
+func (r *cliRepo) UpstreamFiles() ([]string, error) {
+	return r.treeFiles("@{upstream}")
+}
+
+func (r *cliRepo) Pull() error {
+	if err := r.Fetch(); err != nil {
+		return err
+	}
+	upstream, err := r.git("rev-parse", "--verify", "@{upstream}^{commit}")
+	if err != nil {
+		return fmt.Errorf("the current branch has no upstream: %w", err)
+	}
+	head, err := r.git("rev-parse", "--verify", "HEAD")
+	if err != nil {
+		return err
+	}
+	if head == upstream {
+		return nil
+	}
+	if _, err := r.git("merge-base", "--is-ancestor", upstream, head); err == nil {
+		// Only ahead of the upstream.
+		return nil
+	}
+	if _, err := r.git("merge-base", "--is-ancestor", head, upstream); err != nil {
+		return ErrNotFastForward
+	}
+
+	conflicts, err := r.conflicts(upstream)
+	if err != nil {
+		return err
+	}
+	if err := conflictError("pull", conflicts); err != nil {
+		return err
+	}
+	_, err = r.git("merge", "--ff-only", upstream)
+	return err
+}
+
+func (r *cliRepo) Commit(message string, paths []string) error {
+	_, err := r.git(append([]string{"commit", "--quiet", "--message", message, "--"}, paths...)...)
+	return err
+}
+
+// conflicts returns the files in the work tree that moving the index to
+// target would overwrite.
+func (r *cliRepo) conflicts(target string) ([]Conflict, error) {
+	changed, err := r.paths("diff-index", "--cached", "--name-only", "-z", target, "--")
+	if err != nil {
+		return nil, err
+	}
+	tracked, err := r.paths("ls-files", "-z")
+	if err != nil {
+		return nil, err
+	}
+	modified, err := r.paths("diff-files", "--name-only", "-z")
+	if err != nil {
+		return nil, err
+	}
+	isTracked := make(map[string]bool, len(tracked))
+	for _, p := range tracked {
+		isTracked[p] = true
+	}
+	isModified := make(map[string]bool, len(modified))
+	for _, p := range modified {
+		isModified[p] = true
+	}
+
+	var conflicts []Conflict
+	seen := make(map[string]bool)
+	for _, p := range changed {
+		rel := filepath.FromSlash(p)
+		if isTracked[p] {
+			if _, err := os.Lstat(filepath.Join(r.workTree, rel)); isModified[p] && err == nil {
+				conflicts = append(conflicts, Conflict{Path: rel, Reason: Modified})
+			}
+			continue
+		}
+		if blocker, ok := inTheWay(r.workTree, rel); ok && !seen[blocker] {
+			seen[blocker] = true
+			conflicts = append(conflicts, Conflict{Path: blocker, Reason: Untracked})
+		}
+	}
+	return conflicts, nil
+}
+
+// paths runs a git command with -z output and splits it.
+func (r *cliRepo) paths(args ...string) ([]string, error) {
+	out, err := r.git(args...)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, p := range strings.Split(out, "\x00") {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths, nil
+}
+
+// runGit runs git in dir and returns its stdout without the trailing
+// newline. Errors include git's stderr.
+func runGit(dir string, args ...string) (string, error) {
+	command := exec.Command("git", args...)
+	command.Dir = dir
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+	out, err := command.Output()
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", fmt.Errorf("git is not installed (use the go-git backend instead)")
+		}
+		name := args[0]
+		for _, arg := range args {
+			if !strings.HasPrefix(arg, "--git-dir=") && !strings.HasPrefix(arg, "--work-tree=") {
+				name = arg
+				break
+			}
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", name, msg)
+		}
+		return "", fmt.Errorf("git %s: %w", name, err)
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
@@ -0,0 +1,170 @@
+// Package dotrepo performs the git operations `dotm repo sync` needs on a
+// bare dotfiles repository that checks out into a separate work tree.
+//
+// Two backends implement them: "cli" shells out to the git command and
+// "go-git" is a pure-Go implementation that needs no git installation.
+// Both detect files in the way of a checkout or pull before changing
+// anything and report them as a *ConflictError, instead of relying on
+// git's (localized) error messages.
+package dotrepo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Backend names.
+const (
+	CLI   = "cli"
+	GoGit = "go-git"
+)
+
+// Backends lists the available backends.
+var Backends = []string{CLI, GoGit}
+
+// Backend creates and opens dotfiles repositories.
+type Backend interface {
+	Name() string
+	// Clone makes a bare clone of url at gitDir. An empty branch uses the
+	// remote's default branch.
+	Clone(url, gitDir, branch string) error
+	// Open opens the bare repository at gitDir with workTree as its work
+	// tree.
+	Open(gitDir, workTree string) (Repository, error)
+}
+
+// Repository is an open dotfiles repository.
+type Repository interface {
+	// SetConfig sets a git config option, e.g. status.showUntrackedFiles.
+	SetConfig(key, value string) error
+	// TrackUpstream makes sure origin's branches are fetched into
+	// refs/remotes/origin and that the current branch tracks its
+	// counterpart there. A repository that already fetches origin's
+	// branches is left alone.
+	TrackUpstream() error
+	// Checkout checks out branch into the work tree, or the current
+	// branch when branch is empty. Local branches are created from
+	// origin when needed.
+	Checkout(branch string) error
+	// Fetch fetches the current branch's upstream.
+	Fetch() error
+	// UpstreamFiles lists the files tracked on the current branch's
+	// upstream as of the last fetch, relative to the work tree.
+	UpstreamFiles() ([]string, error)
+	// Pull fetches the current branch's upstream and fast-forwards to it.
+	// A branch that is only ahead of its upstream is left alone.
+	Pull() error
+	// Files lists the files tracked on branch, or on the current branch
+	// when branch is empty, relative to the work tree. Like Checkout, it
+	// falls back to origin's branch.
+	Files(branch string) ([]string, error)
+	// Commit records the work tree versions of paths, which must be
+	// tracked, in a new commit on the current branch.
+	Commit(message string, paths []string) error
+}
+
+// New returns the backend called name; "" selects the CLI backend.
+func New(name string) (Backend, error) {
+	switch name {
+	case "", CLI:
+		return cliBackend{}, nil
+	case GoGit:
+		return goGitBackend{}, nil
+	default:
+		return nil, fmt.Errorf("unknown git backend %q (supported: %s)", name, strings.Join(Backends, ", "))
+	}
+}
+
+// ConflictReason says why a file is in the way.
+type ConflictReason int
+
+const (
+	// Untracked files exist in the work tree where a tracked file would
+	// be written.
+	Untracked ConflictReason = iota
+	// Modified files are tracked, changed locally and would be updated or
+	// removed.
+	Modified
+)
+
+func (r ConflictReason) String() string {
+	if r == Modified {
+		return "modified"
+	}
+	return "untracked"
+}
+
+// Conflict is a file in the way of a checkout or pull.
+type Conflict struct {
+	// Path is relative to the work tree and uses the OS separator.
+	Path   string
+	Reason ConflictReason
+}
+
+// ConflictError is returned by Checkout and Pull when files in the work
+// tree would be overwritten. Nothing has been changed when it is returned.
+type ConflictError struct {
+	// Op is "checkout" or "pull".
+	Op        string
+	Conflicts []Conflict
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s would overwrite %d local files: %s", e.Op, len(e.Conflicts), strings.Join(e.Paths(), ", "))
+}
+
+// Paths returns the conflicting paths.
+func (e *ConflictError) Paths() []string {
+	paths := make([]string, len(e.Conflicts))
+	for i, c := range e.Conflicts {
+		paths[i] = c.Path
+	}
+	return paths
+}
+
+// Conflicts returns the conflicts in err, or nil if err is not a
+// *ConflictError.
+func Conflicts(err error) []Conflict {
+	var conflictErr *ConflictError
+	if errors.As(err, &conflictErr) {
+		return conflictErr.Conflicts
+	}
+	return nil
+}
+
+// ErrNotFastForward is returned by Pull when the local branch has diverged
+// from its upstream.
+var ErrNotFastForward = errors.New("local branch has diverged from its upstream; fast-forward is not possible")
+
+func conflictError(op string, conflicts []Conflict) error {
+	if len(conflicts) == 0 {
+		return nil
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Path < conflicts[j].Path })
+	return &ConflictError{Op: op, Conflicts: conflicts}
+}
+
+// inTheWay returns the path that blocks writing rel into workTree: rel
+// itself if it exists, or a parent directory that is a file. It returns
+// false when rel can be written freely.
+func inTheWay(workTree, rel string) (string, bool) {
+	if _, err := os.Lstat(filepath.Join(workTree, rel)); err == nil {
+		return rel, true
+	}
+	for dir := filepath.Dir(rel); dir != "."; dir = filepath.Dir(dir) {
+		info, err := os.Lstat(filepath.Join(workTree, dir))
+		if err != nil {
+			continue
+		}
+		if !info.IsDir() {
+			return dir, true
+		}
+		// The closest existing ancestor is a directory.
+		break
+	}
+	return "", false
+}
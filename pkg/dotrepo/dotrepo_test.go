@@ -0,0 +1,341 @@
+package dotrepo
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func gitEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "dotm")
+	t.Setenv("GIT_AUTHOR_EMAIL", "dotm@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "dotm")
+	t.Setenv("GIT_COMMITTER_EMAIL", "dotm@example.com")
+}
+
+func run(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func writeFile(t *testing.T, path, body string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// remote is a bare repository fed from a scratch work tree.
+type remote struct {
+	t    *testing.T
+	bare string
+	work string
+}
+
+func newRemote(t *testing.T, files map[string]string) *remote {
+	t.Helper()
+	gitEnv(t)
+	dir := t.TempDir()
+	r := &remote{t: t, bare: filepath.Join(dir, "remote.git"), work: filepath.Join(dir, "work")}
+	run(t, dir, "init", "--bare", "--initial-branch=main", r.bare)
+	run(t, dir, "clone", r.bare, r.work)
+	run(t, r.work, "checkout", "-b", "main")
+	r.commit(files)
+	return r
+}
+
+// commit writes files on the current branch, commits and pushes them.
+func (r *remote) commit(files map[string]string) {
+	r.t.Helper()
+	for name, body := range files {
+		writeFile(r.t, filepath.Join(r.work, name), body)
+	}
+	run(r.t, r.work, "add", "-A")
+	run(r.t, r.work, "commit", "-m", "update")
+	run(r.t, r.work, "push", "origin", "HEAD")
+}
+
+// dotfiles clones the remote with backend and opens it on an empty home.
+func dotfiles(t *testing.T, backend Backend, r *remote, branch string) (repo Repository, gitDir, home string) {
+	t.Helper()
+	home = t.TempDir()
+	gitDir = filepath.Join(t.TempDir(), "dotfiles")
+	if err := backend.Clone(r.bare, gitDir, branch); err != nil {
+		t.Fatalf("Clone: %v", err)
+	}
+	repo, err := backend.Open(gitDir, home)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := repo.SetConfig("status.showUntrackedFiles", "no"); err != nil {
+		t.Fatalf("SetConfig: %v", err)
+	}
+	if err := repo.TrackUpstream(); err != nil {
+		t.Fatalf("TrackUpstream: %v", err)
+	}
+	return repo, gitDir, home
+}
+
+// status returns git status for the dotfiles; empty means clean.
+func status(t *testing.T, gitDir, home string) string {
+	t.Helper()
+	return run(t, home, "--git-dir="+gitDir, "--work-tree="+home, "status", "--porcelain")
+}
+
+// forEachBackend runs test once per backend.
+func forEachBackend(t *testing.T, test func(t *testing.T, backend Backend)) {
+	for _, name := range Backends {
+		t.Run(name, func(t *testing.T) {
+			backend, err := New(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			test(t, backend)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, name := range []string{"", CLI, GoGit} {
+		if _, err := New(name); err != nil {
+			t.Errorf("New(%q): %v", name, err)
+		}
+	}
+	if _, err := New("svn"); err == nil {
+		t.Error("New(svn) succeeded")
+	}
+}
+
+func TestCheckout(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend Backend) {
+		r := newRemote(t, map[string]string{".zshrc": "zsh\n", ".config/nvim/init.vim": "set nu\n"})
+		repo, gitDir, home := dotfiles(t, backend, r, "")
+
+		if err := repo.Checkout(""); err != nil {
+			t.Fatalf("Checkout: %v", err)
+		}
+		if got := readFile(t, filepath.Join(home, ".config/nvim/init.vim")); got != "set nu\n" {
+			t.Errorf("init.vim = %q", got)
+		}
+		if s := status(t, gitDir, home); s != "" {
+			t.Errorf("status after checkout:\n%s", s)
+		}
+		if got := run(t, home, "--git-dir="+gitDir, "rev-parse", "--abbrev-ref", "main@{upstream}"); got != "origin/main" {
+			t.Errorf("upstream = %q", got)
+		}
+	})
+}
+
+func TestCheckoutConflicts(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend Backend) {
+		r := newRemote(t, map[string]string{".zshrc": "zsh\n", ".config/nvim/init.vim": "set nu\n", ".vimrc": "vim\n"})
+		repo, _, home := dotfiles(t, backend, r, "")
+		writeFile(t, filepath.Join(home, ".zshrc"), "mine\n")
+		writeFile(t, filepath.Join(home, ".config"), "a file where a directory goes\n")
+
+		err := repo.Checkout("")
+		var conflictErr *ConflictError
+		if !errors.As(err, &conflictErr) {
+			t.Fatalf("Checkout = %v, want *ConflictError", err)
+		}
+		want := []Conflict{{Path: ".config", Reason: Untracked}, {Path: ".zshrc", Reason: Untracked}}
+		if conflictErr.Op != "checkout" || !slices.Equal(conflictErr.Conflicts, want) {
+			t.Errorf("conflicts = %s %v, want %v", conflictErr.Op, conflictErr.Conflicts, want)
+		}
+		if _, err := os.Stat(filepath.Join(home, ".vimrc")); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("checkout wrote files despite conflicts: %v", err)
+		}
+
+		if err := os.Remove(filepath.Join(home, ".config")); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Remove(filepath.Join(home, ".zshrc")); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.Checkout(""); err != nil {
+			t.Fatalf("Checkout after moving files away: %v", err)
+		}
+	})
+}
+
+func TestCheckoutBranch(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend Backend) {
+		r := newRemote(t, map[string]string{".zshrc": "zsh\n"})
+		run(t, r.work, "checkout", "-b", "laptop")
+		r.commit(map[string]string{".zshrc": "laptop\n", ".tmux.conf": "tmux\n"})
+		repo, gitDir, home := dotfiles(t, backend, r, "")
+
+		if err := repo.Checkout(""); err != nil {
+			t.Fatalf("Checkout: %v", err)
+		}
+		if files, err := repo.Files("laptop"); err != nil || !slices.Equal(files, []string{".tmux.conf", ".zshrc"}) {
+			t.Errorf("Files(laptop) = %v, %v", files, err)
+		}
+		if files, err := repo.Files(""); err != nil || !slices.Equal(files, []string{".zshrc"}) {
+			t.Errorf("Files() = %v, %v", files, err)
+		}
+		if err := repo.Checkout("laptop"); err != nil {
+			t.Fatalf("Checkout(laptop): %v", err)
+		}
+		if got := readFile(t, filepath.Join(home, ".zshrc")); got != "laptop\n" {
+			t.Errorf(".zshrc = %q", got)
+		}
+		if got := run(t, home, "--git-dir="+gitDir, "symbolic-ref", "--short", "HEAD"); got != "laptop" {
+			t.Errorf("HEAD = %q", got)
+		}
+		if got := run(t, home, "--git-dir="+gitDir, "rev-parse", "--abbrev-ref", "laptop@{upstream}"); got != "origin/laptop" {
+			t.Errorf("upstream = %q", got)
+		}
+		if s := status(t, gitDir, home); s != "" {
+			t.Errorf("status after checkout:\n%s", s)
+		}
+
+		if err := repo.Checkout("nope"); err == nil {
+			t.Error("Checkout(nope) succeeded")
+		}
+	})
+}
+
+func TestPull(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend Backend) {
+		r := newRemote(t, map[string]string{".zshrc": "zsh\n", ".vimrc": "vim\n"})
+		repo, gitDir, home := dotfiles(t, backend, r, "")
+		if err := repo.Checkout(""); err != nil {
+			t.Fatalf("Checkout: %v", err)
+		}
+		if err := repo.Pull(); err != nil {
+			t.Fatalf("Pull when up to date: %v", err)
+		}
+
+		r.commit(map[string]string{".zshrc": "zsh 2\n", ".gitconfig": "git\n"})
+		run(t, r.work, "rm", "-q", ".vimrc")
+		run(t, r.work, "commit", "-q", "-m", "drop vimrc")
+		run(t, r.work, "push", "-q", "origin", "HEAD")
+		writeFile(t, filepath.Join(home, ".zshrc"), "local edit\n")
+		writeFile(t, filepath.Join(home, ".gitconfig"), "local\n")
+
+		err := repo.Pull()
+		want := []Conflict{{Path: ".gitconfig", Reason: Untracked}, {Path: ".zshrc", Reason: Modified}}
+		if got := Conflicts(err); !slices.Equal(got, want) {
+			t.Fatalf("Pull = %v, want conflicts %v", err, want)
+		}
+		if got := readFile(t, filepath.Join(home, ".zshrc")); got != "local edit\n" {
+			t.Errorf("pull changed .zshrc despite conflicts: %q", got)
+		}
+
+		writeFile(t, filepath.Join(home, ".zshrc"), "zsh\n")
+		if err := os.Remove(filepath.Join(home, ".gitconfig")); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.Pull(); err != nil {
+			t.Fatalf("Pull: %v", err)
+		}
+		if got := readFile(t, filepath.Join(home, ".zshrc")); got != "zsh 2\n" {
+			t.Errorf(".zshrc = %q", got)
+		}
+		if _, err := os.Stat(filepath.Join(home, ".vimrc")); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf(".vimrc not removed: %v", err)
+		}
+		if s := status(t, gitDir, home); s != "" {
+			t.Errorf("status after pull:\n%s", s)
+		}
+	})
+}
+
+func TestUpstreamFiles(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend Backend) {
+		r := newRemote(t, map[string]string{".zshrc": "zsh\n"})
+		repo, _, home := dotfiles(t, backend, r, "")
+		if err := repo.Checkout(""); err != nil {
+			t.Fatalf("Checkout: %v", err)
+		}
+		r.commit(map[string]string{".gitconfig": "git\n"})
+
+		if err := repo.Fetch(); err != nil {
+			t.Fatalf("Fetch: %v", err)
+		}
+		files, err := repo.UpstreamFiles()
+		if want := []string{".gitconfig", ".zshrc"}; err != nil || !slices.Equal(files, want) {
+			t.Errorf("UpstreamFiles = %v, %v, want %v", files, err, want)
+		}
+		if _, err := os.Stat(filepath.Join(home, ".gitconfig")); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Fetch changed the work tree: %v", err)
+		}
+		if files, _ := repo.Files(""); !slices.Equal(files, []string{".zshrc"}) {
+			t.Errorf("Files after Fetch = %v", files)
+		}
+	})
+}
+
+func TestPullDiverged(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend Backend) {
+		r := newRemote(t, map[string]string{".zshrc": "zsh\n"})
+		repo, gitDir, home := dotfiles(t, backend, r, "")
+		if err := repo.Checkout(""); err != nil {
+			t.Fatalf("Checkout: %v", err)
+		}
+		writeFile(t, filepath.Join(home, ".zshrc"), "local\n")
+		run(t, home, "--git-dir="+gitDir, "--work-tree="+home, "commit", "-q", "-am", "local")
+		r.commit(map[string]string{".zshrc": "remote\n"})
+
+		if err := repo.Pull(); !errors.Is(err, ErrNotFastForward) {
+			t.Fatalf("Pull = %v, want ErrNotFastForward", err)
+		}
+	})
+}
+
+func TestCommit(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend Backend) {
+		r := newRemote(t, map[string]string{".zshrc": "zsh\n", ".vimrc": "vim\n"})
+		repo, gitDir, home := dotfiles(t, backend, r, "")
+		if err := repo.Checkout(""); err != nil {
+			t.Fatalf("Checkout: %v", err)
+		}
+		writeFile(t, filepath.Join(home, ".zshrc"), "local\n")
+		writeFile(t, filepath.Join(home, ".vimrc"), "not committed\n")
+
+		if err := repo.Commit("Keep local .zshrc", []string{".zshrc"}); err != nil {
+			t.Fatalf("Commit: %v", err)
+		}
+		if got := run(t, home, "--git-dir="+gitDir, "log", "-1", "--format=%s"); got != "Keep local .zshrc" {
+			t.Errorf("last commit = %q", got)
+		}
+		if got := run(t, home, "--git-dir="+gitDir, "show", "HEAD:.zshrc"); got != "local" {
+			t.Errorf("committed .zshrc = %q", got)
+		}
+		if s := status(t, gitDir, home); s != "M .vimrc" {
+			t.Errorf("status after commit:\n%s", s)
+		}
+		if err := repo.Pull(); err != nil {
+			t.Errorf("Pull when ahead of upstream: %v", err)
+		}
+		if err := repo.Commit("untracked", []string{".bashrc"}); err == nil {
+			t.Error("committing an untracked file succeeded")
+		}
+	})
+}
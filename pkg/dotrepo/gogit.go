@@ -0,0 +1,522 @@
+package dotrepo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+)
+
+// goGitBackend is a pure-Go backend built on go-git. It updates the work
+// tree itself rather than through go-git's worktree, whose status checks
+// walk the whole (home) directory.
+type goGitBackend struct{}
+
+func (goGitBackend) Name() string { return GoGit }
+
+func (goGitBackend) Clone(url, gitDir, branch string) error {
+	opts := &git.CloneOptions{URL: url}
+	if branch != "" {
+		opts.ReferenceName = plumbing.NewBranchReferenceName(branch)
+	}
+	_, err := git.PlainClone(gitDir, true, opts)
+	return err
+}
+
+func (goGitBackend) Open(gitDir, workTree string) (Repository, error) {
+	if _, err := os.Stat(gitDir); err != nil {
+		return nil, err
+	}
+	storage := filesystem.NewStorage(osfs.New(gitDir), cache.NewObjectLRUDefault())
+	repo, err := git.Open(storage, osfs.New(workTree))
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", gitDir, err)
+	}
+	return &goGitRepo{repo: repo, workTree: workTree}, nil
+}
+
+type goGitRepo struct {
+	repo     *git.Repository
+	workTree string
+}
+
+func (r *goGitRepo) SetConfig(key, value string) error {
+	section, option, ok := strings.Cut(key, ".")
+	if !ok || strings.Contains(option, ".") {
+		return fmt.Errorf("unsupported config key %q", key)
+	}
+	cfg, err := r.repo.Config()
+	if err != nil {
+		return err
+	}
+	cfg.Raw.Section(section).SetOption(option, value)
+	return r.repo.SetConfig(cfg)
+}
+
+func (r *goGitRepo) TrackUpstream() error {
+	cfg, err := r.repo.Config()
+	if err != nil {
+		return err
+	}
+	origin, ok := cfg.Remotes["origin"]
+	if !ok {
+		return fmt.Errorf("no remote named origin")
+	}
+	if len(origin.Fetch) > 0 {
+		return nil
+	}
+	origin.Fetch = []config.RefSpec{config.RefSpec(fmt.Sprintf(config.DefaultFetchRefSpec, "origin"))}
+	branch, err := r.currentBranch()
+	if err != nil {
+		return err
+	}
+	cfg.Branches[branch] = &config.Branch{Name: branch, Remote: "origin", Merge: plumbing.NewBranchReferenceName(branch)}
+	if err := r.repo.SetConfig(cfg); err != nil {
+		return err
+	}
+	return r.fetch("origin")
+}
+
+func (r *goGitRepo) Checkout(branch string) error {
+	current, err := r.currentBranch()
+	if err != nil {
+		return err
+	}
+	if branch == "" {
+		branch = current
+	}
+	ref, err := r.resolve(branch)
+	if err != nil {
+		return err
+	}
+	refName := plumbing.NewBranchReferenceName(branch)
+	if ref.Name() != refName {
+		// Create the local branch from origin, tracking it.
+		ref = plumbing.NewHashReference(refName, ref.Hash())
+		if err := r.repo.Storer.SetReference(ref); err != nil {
+			return err
+		}
+	}
+
+	if err := r.update("checkout", ref.Hash()); err != nil {
+		return err
+	}
+	if branch != current {
+		if err := r.repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, refName)); err != nil {
+			return err
+		}
+	}
+	return r.trackOrigin(branch)
+}
+
+// resolve returns the local branch, or origin's branch of that name.
+func (r *goGitRepo) resolve(branch string) (*plumbing.Reference, error) {
+	ref, err := r.repo.Reference(plumbing.NewBranchReferenceName(branch), true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		if ref, err = r.repo.Reference(plumbing.NewRemoteReferenceName("origin", branch), true); err != nil {
+			return nil, fmt.Errorf("branch %q not found locally or on origin", branch)
+		}
+	}
+	return ref, err
+}
+
+func (r *goGitRepo) Files(branch string) ([]string, error) {
+	if branch == "" {
+		current, err := r.currentBranch()
+		if err != nil {
+			return nil, err
+		}
+		branch = current
+	}
+	ref, err := r.resolve(branch)
+	if err != nil {
+		return nil, err
+	}
+	return r.treeFiles(ref.Hash())
+}
+
+// treeFiles lists the files in the tree of commit.
+func (r *goGitRepo) treeFiles(hash plumbing.Hash) ([]string, error) {
+	commit, err := r.repo.CommitObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	err = tree.Files().ForEach(func(f *object.File) error {
+		files = append(files, filepath.FromSlash(f.Name))
+		return nil
+	})
+	return files, err
+}
+
+// trackOrigin sets origin/<branch> as the upstream of branch if it has
+// none. A bare clone copies every remote branch without upstreams.
+func (r *goGitRepo) trackOrigin(branch string) error {
+	if _, err := r.repo.Branch(branch); err == nil {
+		return nil
+	}
+	refName := plumbing.NewBranchReferenceName(branch)
+	if _, err := r.repo.Reference(plumbing.NewRemoteReferenceName("origin", branch), true); err != nil {
+		return nil
+	}
+	err := r.repo.CreateBranch(&config.Branch{Name: branch, Remote: "origin", Merge: refName})
+	if errors.Is(err, git.ErrBranchExists) {
+		return nil
+	}
+	return err
+}
+
+func (r *goGitRepo) Fetch() error {
+	b, err := r.upstreamConfig()
+	if err != nil {
+		return err
+	}
+	return r.fetch(b.Remote)
+}
+
+func (r *goGitRepo) UpstreamFiles() ([]string, error) {
+	upstream, err := r.upstream()
+	if err != nil {
+		return nil, err
+	}
+	return r.treeFiles(upstream.Hash())
+}
+
+// upstreamConfig returns the branch config of the current branch, failing
+// if it has no upstream.
+func (r *goGitRepo) upstreamConfig() (*config.Branch, error) {
+	branch, err := r.currentBranch()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := r.repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	b, ok := cfg.Branches[branch]
+	if !ok || b.Remote == "" || b.Merge == "" {
+		return nil, fmt.Errorf("the current branch has no upstream")
+	}
+	return b, nil
+}
+
+// upstream returns the remote-tracking ref of the current branch's
+// upstream.
+func (r *goGitRepo) upstream() (*plumbing.Reference, error) {
+	b, err := r.upstreamConfig()
+	if err != nil {
+		return nil, err
+	}
+	ref, err := r.repo.Reference(plumbing.NewRemoteReferenceName(b.Remote, b.Merge.Short()), true)
+	if err != nil {
+		return nil, fmt.Errorf("upstream of %s: %w", b.Name, err)
+	}
+	return ref, nil
+}
+
+func (r *goGitRepo) Pull() error {
+	branch, err := r.currentBranch()
+	if err != nil {
+		return err
+	}
+	if err := r.Fetch(); err != nil {
+		return err
+	}
+	upstream, err := r.upstream()
+	if err != nil {
+		return err
+	}
+	head, err := r.repo.Reference(plumbing.NewBranchReferenceName(branch), true)
+	if err != nil {
+		return err
+	}
+	if head.Hash() == upstream.Hash() {
+		return nil
+	}
+	headCommit, err := r.repo.CommitObject(head.Hash())
+	if err != nil {
+		return err
+	}
+	upstreamCommit, err := r.repo.CommitObject(upstream.Hash())
+	if err != nil {
+		return err
+	}
+	if ok, err := upstreamCommit.IsAncestor(headCommit); err != nil {
+		return err
+	} else if ok {
+		// Only ahead of the upstream.
+		return nil
+	}
+	if ok, err := headCommit.IsAncestor(upstreamCommit); err != nil {
+		return err
+	} else if !ok {
+		return ErrNotFastForward
+	}
+
+	if err := r.update("pull", upstream.Hash()); err != nil {
+		return err
+	}
+	return r.repo.Storer.SetReference(plumbing.NewHashReference(head.Name(), upstream.Hash()))
+}
+
+func (r *goGitRepo) Commit(message string, paths []string) error {
+	idx, err := r.repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+	for _, rel := range paths {
+		e, err := idx.Entry(filepath.ToSlash(rel))
+		if err != nil {
+			return fmt.Errorf("%s is not tracked", rel)
+		}
+		content, mode, info, err := r.readWorkTree(rel)
+		if err != nil {
+			return err
+		}
+		obj := r.repo.Storer.NewEncodedObject()
+		obj.SetType(plumbing.BlobObject)
+		w, err := obj.Writer()
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(content); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		if e.Hash, err = r.repo.Storer.SetEncodedObject(obj); err != nil {
+			return err
+		}
+		e.Mode, e.ModifiedAt, e.Size = mode, info.ModTime(), uint32(info.Size())
+	}
+	if err := r.repo.Storer.SetIndex(idx); err != nil {
+		return err
+	}
+
+	w, err := r.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	_, err = w.Commit(message, &git.CommitOptions{Author: envSignature("AUTHOR"), Committer: envSignature("COMMITTER")})
+	return err
+}
+
+// envSignature returns the identity set by GIT_<role>_NAME and
+// GIT_<role>_EMAIL, as git would use it, or nil to use the git config.
+func envSignature(role string) *object.Signature {
+	name, email := os.Getenv("GIT_"+role+"_NAME"), os.Getenv("GIT_"+role+"_EMAIL")
+	if name == "" || email == "" {
+		return nil
+	}
+	return &object.Signature{Name: name, Email: email, When: time.Now()}
+}
+
+func (r *goGitRepo) currentBranch() (string, error) {
+	head, err := r.repo.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return "", err
+	}
+	if head.Type() != plumbing.SymbolicReference || !head.Target().IsBranch() {
+		return "", fmt.Errorf("HEAD is not on a branch")
+	}
+	return head.Target().Short(), nil
+}
+
+func (r *goGitRepo) fetch(remote string) error {
+	err := r.repo.Fetch(&git.FetchOptions{RemoteName: remote})
+	if errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil
+	}
+	return err
+}
+
+// treeFile is a file in a commit's tree.
+type treeFile struct {
+	hash plumbing.Hash
+	mode filemode.FileMode
+}
+
+// update moves the index and work tree to commit. Files in the way are
+// reported as a *ConflictError before anything is written.
+func (r *goGitRepo) update(op string, commit plumbing.Hash) error {
+	c, err := r.repo.CommitObject(commit)
+	if err != nil {
+		return err
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return err
+	}
+	target := make(map[string]treeFile)
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if f.Mode != filemode.Submodule {
+			target[f.Name] = treeFile{f.Hash, f.Mode}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	idx, err := r.repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+	current := make(map[string]treeFile, len(idx.Entries))
+	for _, e := range idx.Entries {
+		current[e.Name] = treeFile{e.Hash, e.Mode}
+	}
+
+	// Work out what changes and what is in the way.
+	var write, remove []string
+	var conflicts []Conflict
+	seen := make(map[string]bool)
+	for name, want := range target {
+		if have, ok := current[name]; ok && have == want {
+			continue
+		}
+		write = append(write, name)
+		rel := filepath.FromSlash(name)
+		if have, ok := current[name]; ok {
+			if modified, err := r.modified(rel, have); err != nil {
+				return err
+			} else if modified {
+				conflicts = append(conflicts, Conflict{Path: rel, Reason: Modified})
+			}
+		} else if blocker, ok := inTheWay(r.workTree, rel); ok && !seen[blocker] {
+			seen[blocker] = true
+			conflicts = append(conflicts, Conflict{Path: blocker, Reason: Untracked})
+		}
+	}
+	for name, have := range current {
+		if _, ok := target[name]; ok {
+			continue
+		}
+		remove = append(remove, name)
+		rel := filepath.FromSlash(name)
+		if modified, err := r.modified(rel, have); err != nil {
+			return err
+		} else if modified {
+			conflicts = append(conflicts, Conflict{Path: rel, Reason: Modified})
+		}
+	}
+	if err := conflictError(op, conflicts); err != nil {
+		return err
+	}
+
+	for _, name := range remove {
+		if err := os.Remove(filepath.Join(r.workTree, filepath.FromSlash(name))); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	for _, name := range write {
+		if err := r.writeFile(name, target[name]); err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+	}
+
+	// Rebuild the index from the tree. Stat data lets git skip rehashing
+	// unchanged files.
+	entries := make([]*index.Entry, 0, len(target))
+	for name, f := range target {
+		e := &index.Entry{Name: name, Hash: f.hash, Mode: f.mode}
+		if info, err := os.Lstat(filepath.Join(r.workTree, filepath.FromSlash(name))); err == nil {
+			e.ModifiedAt = info.ModTime()
+			e.Size = uint32(info.Size())
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return r.repo.Storer.SetIndex(&index.Index{Version: 2, Entries: entries})
+}
+
+// modified reports whether the work tree copy of a tracked file differs
+// from the index. Deleted files are not in the way of anything.
+func (r *goGitRepo) modified(rel string, have treeFile) (bool, error) {
+	content, _, _, err := r.readWorkTree(rel)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if errors.Is(err, errNotAFile) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	return plumbing.ComputeHash(plumbing.BlobObject, content) != have.hash, nil
+}
+
+var errNotAFile = errors.New("not a regular file or symlink")
+
+// readWorkTree returns the content git would store for a work tree file:
+// the file's bytes, or a symlink's target.
+func (r *goGitRepo) readWorkTree(rel string) ([]byte, filemode.FileMode, os.FileInfo, error) {
+	path := filepath.Join(r.workTree, rel)
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		link, err := os.Readlink(path)
+		return []byte(link), filemode.Symlink, info, err
+	case info.Mode().IsRegular():
+		content, err := os.ReadFile(path)
+		mode := filemode.Regular
+		if info.Mode().Perm()&0111 != 0 {
+			mode = filemode.Executable
+		}
+		return content, mode, info, err
+	default:
+		return nil, 0, info, fmt.Errorf("%s: %w", rel, errNotAFile)
+	}
+}
+
+// writeFile writes a blob from the tree into the work tree.
+func (r *goGitRepo) writeFile(name string, f treeFile) error {
+	blob, err := r.repo.BlobObject(f.hash)
+	if err != nil {
+		return err
+	}
+	reader, err := blob.Reader()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(r.workTree, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	switch f.mode {
+	case filemode.Symlink:
+		return os.Symlink(string(content), path)
+	case filemode.Executable:
+		return os.WriteFile(path, content, 0755)
+	default:
+		return os.WriteFile(path, content, 0644)
+	}
+}
@@ -3,9 +3,11 @@ package executor
 import (
 	"bufio"
 	"fmt"
+	"os"
 	"os/exec"
 	"runtime"
 	"strings"
+	"sync"
 )
 
 // GetOS returns the current operating system (e.g., "linux", "darwin").
@@ -25,20 +27,20 @@ func GetOS() string {
 
 // Execute runs a command and streams its output to stdout.
 func Execute(command string, dryRun bool) error {
-	if dryRun {
-		fmt.Printf("[DRY RUN] Would execute: %s\n", command)
-		// For checks in dry-run mode, we need to simulate failure
-		// to properly test the install path. A simple way is to check
-		// if the command is a 'check' command. This is a heuristic.
-		if strings.HasPrefix(command, "command -v") {
-			return fmt.Errorf("simulating check failure for dry run")
-		}
-		return nil
-	}
-	fmt.Printf("Executing: %s\n", command)
+	s := &Session{DryRun: dryRun}
+	return s.Run(command)
+}
 
-	// Use sh -c to properly handle commands with pipes or multiple parts.
-	cmd := exec.Command("sh", "-c", command)
+// run starts cmd and streams its output to stdout. The child's stdin is
+// the null device, so a command that unexpectedly asks for input fails
+// fast instead of hanging the run.
+func run(cmd *exec.Cmd, command string) error {
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %w", os.DevNull, err)
+	}
+	defer devNull.Close()
+	cmd.Stdin = devNull
 
 	// Get pipes for stdout and stderr
 	stdout, err := cmd.StdoutPipe()
@@ -59,17 +61,23 @@ func Execute(command string, dryRun bool) error {
 	stdoutScanner := bufio.NewScanner(stdout)
 	stderrScanner := bufio.NewScanner(stderr)
 
-	// Concurrently read from both pipes
+	// Concurrently read from both pipes. All output must be read before
+	// calling Wait, which closes the pipes.
+	var wg sync.WaitGroup
+	wg.Add(2)
 	go func() {
+		defer wg.Done()
 		for stdoutScanner.Scan() {
 			fmt.Println(stdoutScanner.Text())
 		}
 	}()
 	go func() {
+		defer wg.Done()
 		for stderrScanner.Scan() {
 			fmt.Println(stderrScanner.Text())
 		}
 	}()
+	wg.Wait()
 
 	// Wait for the command to finish
 	if err := cmd.Wait(); err != nil {
@@ -78,3 +86,28 @@ func Execute(command string, dryRun bool) error {
 
 	return nil
 }
+
+// runInteractive connects the child directly to dotm's terminal so that
+// installers can prompt and draw progress bars.
+func runInteractive(cmd *exec.Cmd, command string) error {
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("command '%s' failed: %w", command, err)
+	}
+	return nil
+}
+
+// runQuiet runs cmd with stdin from the null device and discards its output.
+func runQuiet(cmd *exec.Cmd, command string) error {
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("command '%s' failed: %w", command, err)
+	}
+	return nil
+}
+
+// isCheckCommand reports whether a command looks like a presence check.
+func isCheckCommand(command string) bool {
+	return strings.HasPrefix(command, "command -v")
+}
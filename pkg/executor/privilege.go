@@ -0,0 +1,104 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// sudoKeepAlive is how often the sudo timestamp is refreshed during a run.
+// It is well below sudo's default five minute timeout.
+const sudoKeepAlive = 60 * time.Second
+
+// ErrSudoUnavailable is returned when a privileged command must go through
+// sudo but sudo is not installed.
+var ErrSudoUnavailable = errors.New("sudo is not available; run dotm as root or install sudo")
+
+// IsRoot reports whether dotm is running with root privileges.
+func IsRoot() bool {
+	return os.Geteuid() == 0
+}
+
+// Privilege runs privileged commands. When dotm already runs as root the
+// commands are executed directly; otherwise they go through sudo, which is
+// prompted for once by Acquire and kept alive until Release.
+type Privilege struct {
+	DryRun bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NeedsSudo reports whether privileged commands have to go through sudo.
+func (p *Privilege) NeedsSudo() bool {
+	return !IsRoot()
+}
+
+// Acquire prompts for the sudo password up front and starts refreshing the
+// sudo timestamp in the background so later commands do not prompt again.
+func (p *Privilege) Acquire() error {
+	if !p.NeedsSudo() {
+		return nil
+	}
+	if p.DryRun {
+		fmt.Println("[DRY RUN] Would request sudo privileges")
+		return nil
+	}
+	if _, err := exec.LookPath("sudo"); err != nil {
+		return ErrSudoUnavailable
+	}
+
+	fmt.Println("Some steps need root privileges; requesting sudo once for this run.")
+	cmd := exec.Command("sudo", "-v")
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("sudo authentication failed: %w", err)
+	}
+
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(sudoKeepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				_ = exec.Command("sudo", "-n", "-v").Run()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Release stops the sudo keep-alive started by Acquire.
+func (p *Privilege) Release() {
+	if p.stop == nil {
+		return
+	}
+	close(p.stop)
+	<-p.done
+	p.stop = nil
+}
+
+// command builds the command that runs script as root. extraEnv holds the
+// variables the session adds on top of dotm's own environment; sudo resets
+// the environment, so they are passed explicitly through env(1).
+func (p *Privilege) command(shell, script string, extraEnv []string) (*exec.Cmd, error) {
+	if !p.NeedsSudo() {
+		return exec.Command(shell, "-c", script), nil
+	}
+	if _, err := exec.LookPath("sudo"); err != nil {
+		return nil, ErrSudoUnavailable
+	}
+	args := []string{"env"}
+	args = append(args, extraEnv...)
+	args = append(args, shell, "-c", script)
+	return exec.Command("sudo", args...), nil
+}
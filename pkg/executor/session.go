@@ -0,0 +1,403 @@
+package executor
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// SupportedShells lists the shells a module may select with `shell:`.
+var SupportedShells = []string{"sh", "bash", "zsh"}
+
+// SessionOptions configures a Session.
+type SessionOptions struct {
+	// Name is used to label the scratch directory (usually the module name).
+	Name string
+	// Shell is the interpreter used for every command (default "sh").
+	Shell string
+	// Dir is the working directory. It may reference $DOTM_TMPDIR and "~".
+	Dir string
+	// Env holds extra variables layered on top of dotm's environment.
+	Env map[string]string
+	// DryRun prints commands instead of running them.
+	DryRun bool
+	// Privilege runs privileged commands. A nil Privilege uses sudo
+	// whenever dotm is not running as root.
+	Privilege *Privilege
+}
+
+// RunOptions controls how a single command is run within a session.
+type RunOptions struct {
+	// Privileged runs the command as root.
+	Privileged bool
+	// Interactive connects the command to the terminal instead of
+	// capturing its output, for installers that prompt for input.
+	Interactive bool
+	// Quiet discards the command's output and does not announce it. It is
+	// meant for probes such as `status` checks.
+	Quiet bool
+}
+
+// Session runs a sequence of commands that share a shell, a working
+// directory and an environment. Variables exported by one command are
+// visible to the commands that follow it. Each session owns a scratch
+// directory, exposed as $DOTM_TMPDIR, which is removed by Close.
+type Session struct {
+	Shell     string
+	Dir       string
+	Env       []string
+	DryRun    bool
+	Privilege *Privilege
+
+	tmpDir     string
+	captureEnv bool
+	// startEnv is Env as NewSession set it up, before any command ran.
+	startEnv []string
+}
+
+// NewSession prepares a session: it creates the scratch directory and
+// resolves the working directory and environment.
+func NewSession(opts SessionOptions) (*Session, error) {
+	shell := opts.Shell
+	if shell == "" {
+		shell = "sh"
+	}
+	if !slices.Contains(SupportedShells, shell) {
+		return nil, fmt.Errorf("unsupported shell %q (supported: %s)", shell, strings.Join(SupportedShells, ", "))
+	}
+
+	s := &Session{Shell: shell, DryRun: opts.DryRun, Privilege: opts.Privilege, captureEnv: true}
+
+	prefix := "dotm-"
+	if opts.Name != "" {
+		prefix += opts.Name + "-"
+	}
+	tmpDir, err := os.MkdirTemp("", prefix)
+	if err != nil {
+		return nil, fmt.Errorf("create scratch dir: %w", err)
+	}
+	s.tmpDir = tmpDir
+
+	env := envMap(os.Environ())
+	env["DOTM_TMPDIR"] = tmpDir
+	for _, key := range sortedKeys(opts.Env) {
+		env[key] = expand(opts.Env[key], env)
+	}
+	s.Env = envList(env)
+	s.startEnv = s.Env
+
+	if opts.Dir != "" {
+		dir := expand(opts.Dir, env)
+		if info, err := os.Stat(dir); err != nil {
+			s.Close()
+			return nil, fmt.Errorf("workdir %s: %w", dir, err)
+		} else if !info.IsDir() {
+			s.Close()
+			return nil, fmt.Errorf("workdir %s is not a directory", dir)
+		}
+		s.Dir = dir
+	}
+
+	return s, nil
+}
+
+// TempDir returns the session's scratch directory.
+func (s *Session) TempDir() string {
+	return s.tmpDir
+}
+
+// Expand expands "~", $VAR, ${VAR} and ${VAR:-default} in value using the
+// session's environment.
+func (s *Session) Expand(value string) string {
+	return expand(value, envMap(s.Env))
+}
+
+// Close removes the scratch directory.
+func (s *Session) Close() error {
+	if s.tmpDir == "" {
+		return nil
+	}
+	err := os.RemoveAll(s.tmpDir)
+	s.tmpDir = ""
+	return err
+}
+
+// Run executes a command in the session and streams its output to stdout.
+func (s *Session) Run(command string) error {
+	return s.RunWith(command, RunOptions{})
+}
+
+// RunWith executes a command with per-command options.
+func (s *Session) RunWith(command string, opts RunOptions) error {
+	if s.DryRun {
+		fmt.Printf("[DRY RUN] Would execute%s: %s\n", describe(opts), command)
+		// For checks in dry-run mode, we need to simulate failure
+		// to properly test the install path. A simple way is to check
+		// if the command is a 'check' command. This is a heuristic.
+		if isCheckCommand(command) {
+			return fmt.Errorf("simulating check failure for dry run")
+		}
+		return nil
+	}
+	if !opts.Quiet {
+		fmt.Printf("Executing%s: %s\n", describe(opts), command)
+	}
+
+	shell := s.Shell
+	if shell == "" {
+		shell = "sh"
+	}
+
+	script := command
+	envFile := ""
+	// Privileged commands run with root's environment, so their exports
+	// are not carried over.
+	if s.captureEnv && s.tmpDir != "" && !opts.Privileged {
+		// Dump the environment when the shell exits, even through an
+		// explicit exit, so exports carry over to the next command in
+		// this session.
+		envFile = filepath.Join(s.tmpDir, ".dotm-env")
+		os.Remove(envFile)
+		script = "trap " + ShellQuote("env > "+ShellQuote(envFile)) + " EXIT\n" + command
+	}
+
+	// Use a shell to properly handle commands with pipes or multiple parts.
+	var cmd *exec.Cmd
+	if opts.Privileged {
+		privilege := s.Privilege
+		if privilege == nil {
+			privilege = &Privilege{}
+		}
+		var err error
+		cmd, err = privilege.command(shell, script, s.extraEnv())
+		if err != nil {
+			return fmt.Errorf("command '%s' needs root: %w", command, err)
+		}
+	} else {
+		cmd = exec.Command(shell, "-c", script)
+	}
+	cmd.Dir = s.Dir
+	if s.Env != nil {
+		cmd.Env = s.Env
+	}
+
+	runFn := run
+	switch {
+	case opts.Interactive:
+		runFn = runInteractive
+	case opts.Quiet:
+		runFn = runQuiet
+	}
+	if err := runFn(cmd, command); err != nil {
+		return err
+	}
+
+	if envFile != "" {
+		// A command that ends with exec never runs the trap; its
+		// environment is lost, so keep the session's as it was.
+		if err := s.loadEnv(envFile); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("read environment after '%s': %w", command, err)
+		}
+	}
+	return nil
+}
+
+// Output runs a read-only probe, such as a version command, with the
+// session's shell, directory and environment, and returns its combined
+// stdout and stderr. Probes run even in a dry run and do not change the
+// session's environment.
+func (s *Session) Output(command string) (string, error) {
+	shell := s.Shell
+	if shell == "" {
+		shell = "sh"
+	}
+	cmd := exec.Command(shell, "-c", command)
+	cmd.Dir = s.Dir
+	if s.Env != nil {
+		cmd.Env = s.Env
+	}
+	if devNull, err := os.Open(os.DevNull); err == nil {
+		defer devNull.Close()
+		cmd.Stdin = devNull
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(out), fmt.Errorf("command '%s' failed: %w", command, err)
+	}
+	return string(out), nil
+}
+
+// describe renders the options that change how a command is run.
+func describe(opts RunOptions) string {
+	var parts []string
+	if opts.Privileged {
+		parts = append(parts, "as root")
+	}
+	if opts.Interactive {
+		parts = append(parts, "interactively")
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " " + strings.Join(parts, ", ")
+}
+
+// extraEnv returns the session variables that differ from dotm's own
+// environment.
+func (s *Session) extraEnv() []string {
+	base := envMap(os.Environ())
+	var extra []string
+	for _, kv := range s.Env {
+		key, value, _ := strings.Cut(kv, "=")
+		if current, ok := base[key]; !ok || current != value {
+			extra = append(extra, kv)
+		}
+	}
+	return extra
+}
+
+// EnvChanges returns the variables the session's commands set or changed,
+// and the ones they unset, since NewSession.
+func (s *Session) EnvChanges() (set map[string]string, unset []string) {
+	start, current := envMap(s.startEnv), envMap(s.Env)
+	set = make(map[string]string)
+	for key, value := range current {
+		if old, ok := start[key]; !ok || old != value {
+			set[key] = value
+		}
+	}
+	for _, key := range sortedKeys(start) {
+		if _, ok := current[key]; !ok {
+			unset = append(unset, key)
+		}
+	}
+	return set, unset
+}
+
+// RestoreEnv applies changes recorded by EnvChanges, e.g. in an earlier
+// run of the same commands.
+func (s *Session) RestoreEnv(set map[string]string, unset []string) {
+	env := envMap(s.Env)
+	for key, value := range set {
+		env[key] = value
+	}
+	for _, key := range unset {
+		delete(env, key)
+	}
+	s.Env = envList(env)
+}
+
+// loadEnv replaces the session environment with the one dumped by the
+// previous command.
+func (s *Session) loadEnv(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	defer os.Remove(path)
+
+	env := make(map[string]string)
+	last := ""
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || !isEnvName(key) {
+			// Continuation of a multi-line value.
+			if last != "" {
+				env[last] += "\n" + line
+			}
+			continue
+		}
+		env[key] = value
+		last = key
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// Drop variables the shell maintains for itself.
+	for _, key := range []string{"PWD", "OLDPWD", "SHLVL", "_"} {
+		delete(env, key)
+	}
+	s.Env = envList(env)
+	return nil
+}
+
+func isEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// expand resolves a leading "~" and $VAR references against env.
+func expand(value string, env map[string]string) string {
+	if value == "~" || strings.HasPrefix(value, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			value = home + strings.TrimPrefix(value, "~")
+		}
+	}
+	return os.Expand(value, func(key string) string {
+		if name, fallback, ok := strings.Cut(key, ":-"); ok {
+			if v := env[name]; v != "" {
+				return v
+			}
+			return expand(fallback, env)
+		}
+		return env[key]
+	})
+}
+
+// ShellQuote quotes s as a single word of a POSIX shell command. Words
+// made only of characters the shell treats literally are left unquoted.
+func ShellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.+:=@/", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func envMap(environ []string) map[string]string {
+	env := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			env[key] = value
+		}
+	}
+	return env
+}
+
+func envList(env map[string]string) []string {
+	list := make([]string, 0, len(env))
+	for _, key := range sortedKeys(env) {
+		list = append(list, key+"="+env[key])
+	}
+	return list
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,157 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSession_EnvAccumulatesAcrossCommands(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	s, err := NewSession(SessionOptions{Name: "test", Env: map[string]string{"GREETING": "hello"}})
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.Run("export TARGET=world"); err != nil {
+		t.Fatalf("Run export: %v", err)
+	}
+	if err := s.Run(`printf '%s %s' "$GREETING" "$TARGET" > ` + ShellQuote(out)); err != nil {
+		t.Fatalf("Run printf: %v", err)
+	}
+
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if string(got) != "hello world" {
+		t.Fatalf("got %q, want %q", got, "hello world")
+	}
+}
+
+func TestSession_WorkdirInScratchDir(t *testing.T) {
+	s, err := NewSession(SessionOptions{Name: "test", Dir: "$DOTM_TMPDIR"})
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+
+	if err := s.Run("touch download.tar.gz"); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	tmpDir := s.TempDir()
+	if _, err := os.Stat(filepath.Join(tmpDir, "download.tar.gz")); err != nil {
+		t.Fatalf("file not created in scratch dir: %v", err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := os.Stat(tmpDir); !os.IsNotExist(err) {
+		t.Fatalf("scratch dir %s still exists after Close", tmpDir)
+	}
+}
+
+func TestSession_FailedCommandKeepsEnvironment(t *testing.T) {
+	s, err := NewSession(SessionOptions{Name: "test", Env: map[string]string{"KEEP": "1"}})
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.Run("export KEEP=2; false"); err == nil {
+		t.Fatalf("expected failing command to return an error")
+	}
+	if !slices.Contains(s.Env, "KEEP=1") {
+		t.Fatalf("environment changed after a failed command: %v", s.Env)
+	}
+}
+
+func TestSession_ExitKeepsEnvironment(t *testing.T) {
+	s, err := NewSession(SessionOptions{Name: "test"})
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.Run("export STEP=1; exit 0"); err != nil {
+		t.Fatalf("Run with exit 0: %v", err)
+	}
+	if !slices.Contains(s.Env, "STEP=1") {
+		t.Fatalf("export before exit 0 was lost: %v", s.Env)
+	}
+	if err := s.Run("exit"); err != nil {
+		t.Fatalf("Run exit: %v", err)
+	}
+	if err := s.Run("exec true"); err != nil {
+		t.Fatalf("Run exec: %v", err)
+	}
+	if !slices.Contains(s.Env, "STEP=1") {
+		t.Fatalf("environment changed after exec: %v", s.Env)
+	}
+	if err := s.Run("exit 3"); err == nil {
+		t.Fatal("exit 3 did not fail")
+	}
+}
+
+func TestNewSession_RejectsUnknownShell(t *testing.T) {
+	if _, err := NewSession(SessionOptions{Shell: "fish"}); err == nil || !strings.Contains(err.Error(), "unsupported shell") {
+		t.Fatalf("got err=%v, want unsupported shell error", err)
+	}
+}
+
+func TestSession_NonInteractiveStdinIsEmpty(t *testing.T) {
+	s := &Session{}
+	if err := s.Run("read answer"); err == nil {
+		t.Fatalf("expected read from /dev/null to fail")
+	}
+}
+
+func TestSession_ExpandDefaults(t *testing.T) {
+	t.Setenv("HOME", "/home/test")
+	t.Setenv("ZSH_CUSTOM", "")
+	s, err := NewSession(SessionOptions{Name: "test", Env: map[string]string{"PLUGIN": "nvm"}})
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	defer s.Close()
+
+	got := s.Expand("${ZSH_CUSTOM:-~/.oh-my-zsh/custom}/plugins/$PLUGIN")
+	if want := "/home/test/.oh-my-zsh/custom/plugins/nvm"; got != want {
+		t.Fatalf("Expand = %q, want %q", got, want)
+	}
+}
+
+func TestSession_OutputRunsInDryRun(t *testing.T) {
+	s, err := NewSession(SessionOptions{Name: "test", DryRun: true, Env: map[string]string{"TOOL_VERSION": "1.2.3"}})
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	defer s.Close()
+
+	out, err := s.Output(`echo "tool $TOOL_VERSION" >&2`)
+	if err != nil {
+		t.Fatalf("Output: %v", err)
+	}
+	if strings.TrimSpace(out) != "tool 1.2.3" {
+		t.Fatalf("Output = %q", out)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := map[string]string{
+		"fd-find":                  "fd-find",
+		"golang.org/x/tools/gopls": "golang.org/x/tools/gopls",
+		"":                         "''",
+		"my file":                  "'my file'",
+		"it's":                     `'it'\''s'`,
+		"HEAD^{commit}":            "'HEAD^{commit}'",
+	}
+	for in, want := range tests {
+		if got := ShellQuote(in); got != want {
+			t.Errorf("ShellQuote(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
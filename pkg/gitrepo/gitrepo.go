@@ -0,0 +1,279 @@
+// Package gitrepo manages plain git checkouts, such as shell plugins, used
+// by the `git:` install type.
+package gitrepo
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/w31r4/dotm/pkg/executor"
+)
+
+// commitPattern matches refs that name a commit rather than a branch or tag.
+var commitPattern = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// IsCommit reports whether ref looks like a commit hash.
+func IsCommit(ref string) bool {
+	return commitPattern.MatchString(ref)
+}
+
+// IsRepo reports whether dest is a git checkout.
+func IsRepo(dest string) bool {
+	_, err := os.Stat(filepath.Join(dest, ".git"))
+	return err == nil
+}
+
+// Clone clones repo into dest and checks out ref. An empty ref uses the
+// remote's default branch. depth > 0 makes a shallow clone; it is ignored
+// for commit refs, which may not be reachable from a shallow history.
+func Clone(s *executor.Session, repo, dest, ref string, depth int) error {
+	if ref != "" && IsCommit(ref) {
+		if err := s.Run(command("git", "clone", "--no-checkout", repo, dest)); err != nil {
+			return err
+		}
+		return s.Run(git(dest, "checkout", "--detach", ref))
+	}
+
+	args := []string{"git", "clone"}
+	if depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(depth))
+	}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	return s.Run(command(append(args, repo, dest)...))
+}
+
+// Update fetches ref from origin and moves the checkout to it. Branches are
+// fast-forwarded; tags and commits are checked out detached. An empty ref
+// fast-forwards the current branch.
+func Update(s *executor.Session, dest, ref string, depth int) error {
+	var depthArgs []string
+	if depth > 0 {
+		depthArgs = []string{"--depth", strconv.Itoa(depth)}
+	}
+
+	if ref == "" {
+		return s.Run(git(dest, append([]string{"pull", "--ff-only"}, depthArgs...)...))
+	}
+
+	if IsCommit(ref) {
+		if !hasCommit(dest, ref) {
+			if err := s.Run(git(dest, append(append([]string{"fetch"}, depthArgs...), "origin", ref)...)); err != nil {
+				// Servers may refuse to serve a commit by hash; fall back
+				// to fetching everything.
+				if err := s.Run(git(dest, "fetch", "--tags", "origin")); err != nil {
+					return err
+				}
+			}
+		}
+		return s.Run(git(dest, "checkout", "--detach", ref))
+	}
+
+	kind, err := remoteRefKind(dest, ref)
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case "branch":
+		// Shallow clones only track the branch they were cloned with.
+		if !fetchesBranch(dest, ref) {
+			if err := s.Run(git(dest, "remote", "set-branches", "--add", "origin", ref)); err != nil {
+				return err
+			}
+		}
+		refspec := "+refs/heads/" + ref + ":refs/remotes/origin/" + ref
+		if err := s.Run(git(dest, append(append([]string{"fetch"}, depthArgs...), "origin", refspec)...)); err != nil {
+			return err
+		}
+		if CurrentBranch(dest) != ref {
+			checkout := []string{"checkout", ref}
+			if !hasRef(dest, "refs/heads/"+ref) {
+				checkout = []string{"checkout", "-b", ref, "--track", "origin/" + ref}
+			}
+			if err := s.Run(git(dest, checkout...)); err != nil {
+				return err
+			}
+		}
+		return s.Run(git(dest, "merge", "--ff-only", "origin/"+ref))
+	case "tag":
+		refspec := "+refs/tags/" + ref + ":refs/tags/" + ref
+		if err := s.Run(git(dest, append(append([]string{"fetch"}, depthArgs...), "origin", refspec)...)); err != nil {
+			return err
+		}
+		return s.Run(git(dest, "checkout", "--detach", "refs/tags/"+ref))
+	default:
+		return fmt.Errorf("ref %q not found on origin", ref)
+	}
+}
+
+// Resolve asks repo which commit ref points to. An empty ref resolves the
+// remote's default branch. Commit refs are returned unchanged.
+func Resolve(repo, ref string) (string, error) {
+	if IsCommit(ref) {
+		return ref, nil
+	}
+	patterns := []string{"HEAD"}
+	if ref != "" {
+		// Annotated tags only list their peeled commit when asked for it.
+		patterns = []string{ref, ref + "^{}"}
+	}
+	out, err := exec.Command("git", append([]string{"ls-remote", repo}, patterns...)...).Output()
+	if err != nil {
+		return "", fmt.Errorf("git ls-remote %s: %w", repo, err)
+	}
+	commit := ""
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		sha, name, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		switch name {
+		case "HEAD", "refs/heads/" + ref:
+			if commit == "" {
+				commit = sha
+			}
+		case "refs/tags/" + ref:
+			if commit == "" {
+				commit = sha
+			}
+		case "refs/tags/" + ref + "^{}":
+			// The peeled tag is the commit an annotated tag points to.
+			commit = sha
+		}
+	}
+	if commit == "" {
+		return "", fmt.Errorf("ref %q not found in %s", ref, repo)
+	}
+	return commit, nil
+}
+
+// Head returns the commit checked out in dest.
+func Head(dest string) (string, error) {
+	return output(dest, "rev-parse", "HEAD")
+}
+
+// CurrentBranch returns the branch checked out in dest, or "" when HEAD is
+// detached.
+func CurrentBranch(dest string) string {
+	branch, err := output(dest, "symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return branch
+}
+
+// AtRef reports whether dest has ref checked out. Only local refs are
+// consulted, so a branch that moved upstream still counts as checked out.
+func AtRef(dest, ref string) (bool, error) {
+	if ref == "" {
+		return true, nil
+	}
+	if branch := CurrentBranch(dest); branch != "" {
+		return branch == ref, nil
+	}
+	head, err := Head(dest)
+	if err != nil {
+		return false, err
+	}
+	want, err := output(dest, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		return false, nil
+	}
+	return head == want, nil
+}
+
+// Behind fetches the upstream of the current branch and returns how many
+// commits dest is missing. Detached checkouts (tags and commits) are never
+// behind.
+func Behind(dest string) (int, error) {
+	if CurrentBranch(dest) == "" {
+		return 0, nil
+	}
+	if _, err := output(dest, "fetch", "--quiet"); err != nil {
+		return 0, err
+	}
+	count, err := output(dest, "rev-list", "--count", "HEAD..@{upstream}")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(count)
+}
+
+// remoteRefKind asks origin whether ref is a branch or a tag. It returns
+// "" if origin has neither.
+func remoteRefKind(dest, ref string) (string, error) {
+	out, err := output(dest, "ls-remote", "--heads", "--tags", "origin", ref)
+	if err != nil {
+		return "", err
+	}
+	kind := ""
+	for _, line := range strings.Split(out, "\n") {
+		_, name, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		switch name {
+		case "refs/heads/" + ref:
+			return "branch", nil
+		case "refs/tags/" + ref, "refs/tags/" + ref + "^{}":
+			kind = "tag"
+		}
+	}
+	return kind, nil
+}
+
+// fetchesBranch reports whether origin's fetch refspecs cover branch.
+func fetchesBranch(dest, branch string) bool {
+	out, err := output(dest, "config", "--get-all", "remote.origin.fetch")
+	if err != nil {
+		return false
+	}
+	for _, spec := range strings.Split(out, "\n") {
+		src, _, _ := strings.Cut(strings.TrimPrefix(spec, "+"), ":")
+		if src == "refs/heads/*" || src == "refs/heads/"+branch {
+			return true
+		}
+	}
+	return false
+}
+
+func hasCommit(dest, ref string) bool {
+	_, err := output(dest, "cat-file", "-e", ref+"^{commit}")
+	return err == nil
+}
+
+func hasRef(dest, ref string) bool {
+	_, err := output(dest, "show-ref", "--verify", "--quiet", ref)
+	return err == nil
+}
+
+// output runs a read-only git command in dest and returns its trimmed
+// stdout.
+func output(dest string, args ...string) (string, error) {
+	out, err := exec.Command("git", append([]string{"-C", dest}, args...)...).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func git(dest string, args ...string) string {
+	return command(append([]string{"git", "-C", dest}, args...)...)
+}
+
+func command(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = executor.ShellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
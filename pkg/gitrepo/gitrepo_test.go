@@ -0,0 +1,219 @@
+package gitrepo
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/w31r4/dotm/pkg/executor"
+)
+
+// remote is a bare repository fed from a scratch work tree.
+type remote struct {
+	t    *testing.T
+	bare string
+	work string
+}
+
+func gitEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "dotm")
+	t.Setenv("GIT_AUTHOR_EMAIL", "dotm@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "dotm")
+	t.Setenv("GIT_COMMITTER_EMAIL", "dotm@example.com")
+}
+
+func run(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func newRemote(t *testing.T) *remote {
+	t.Helper()
+	gitEnv(t)
+	dir := t.TempDir()
+	r := &remote{t: t, bare: filepath.Join(dir, "remote.git"), work: filepath.Join(dir, "work")}
+	run(t, dir, "init", "--bare", "--initial-branch=main", r.bare)
+	run(t, dir, "clone", r.bare, r.work)
+	run(t, r.work, "checkout", "-b", "main")
+	return r
+}
+
+// commit adds a commit on the current branch, pushes it and returns its hash.
+func (r *remote) commit(file, body string) string {
+	r.t.Helper()
+	if err := os.WriteFile(filepath.Join(r.work, file), []byte(body), 0644); err != nil {
+		r.t.Fatal(err)
+	}
+	run(r.t, r.work, "add", file)
+	run(r.t, r.work, "commit", "-m", "update "+file)
+	run(r.t, r.work, "push", "origin", "HEAD")
+	return run(r.t, r.work, "rev-parse", "HEAD")
+}
+
+func (r *remote) url() string {
+	return "file://" + r.bare
+}
+
+func session(t *testing.T) *executor.Session {
+	t.Helper()
+	s, err := executor.NewSession(executor.SessionOptions{Name: "gitrepo-test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestCloneAndUpdateDefaultBranch(t *testing.T) {
+	r := newRemote(t)
+	first := r.commit("a", "1")
+	dest := filepath.Join(t.TempDir(), "plugins", "plugin")
+	s := session(t)
+
+	if err := Clone(s, r.url(), dest, "", 1); err != nil {
+		t.Fatalf("Clone: %v", err)
+	}
+	if !IsRepo(dest) {
+		t.Fatalf("%s is not a checkout", dest)
+	}
+	if head, _ := Head(dest); head != first {
+		t.Fatalf("Head = %s, want %s", head, first)
+	}
+
+	second := r.commit("a", "2")
+	if err := Update(s, dest, "", 0); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if head, _ := Head(dest); head != second {
+		t.Fatalf("Head after update = %s, want %s", head, second)
+	}
+}
+
+func TestUpdateSwitchesBetweenRefs(t *testing.T) {
+	r := newRemote(t)
+	first := r.commit("a", "1")
+	run(t, r.work, "tag", "v1")
+	run(t, r.work, "push", "origin", "v1")
+	second := r.commit("a", "2")
+	run(t, r.work, "checkout", "-b", "next")
+	next := r.commit("b", "next")
+
+	dest := filepath.Join(t.TempDir(), "plugin")
+	s := session(t)
+
+	if err := Clone(s, r.url(), dest, "v1", 1); err != nil {
+		t.Fatalf("Clone tag: %v", err)
+	}
+	if head, _ := Head(dest); head != first {
+		t.Fatalf("Head = %s, want tag v1 (%s)", head, first)
+	}
+	if ok, err := AtRef(dest, "v1"); err != nil || !ok {
+		t.Fatalf("AtRef(v1) = %v, %v", ok, err)
+	}
+	if ok, _ := AtRef(dest, "next"); ok {
+		t.Fatalf("AtRef(next) should be false before switching")
+	}
+
+	// A shallow single-branch clone can still move to another branch.
+	if err := Update(s, dest, "next", 1); err != nil {
+		t.Fatalf("Update to branch: %v", err)
+	}
+	if head, _ := Head(dest); head != next {
+		t.Fatalf("Head = %s, want branch next (%s)", head, next)
+	}
+	if branch := CurrentBranch(dest); branch != "next" {
+		t.Fatalf("CurrentBranch = %q, want next", branch)
+	}
+
+	if err := Update(s, dest, second, 0); err != nil {
+		t.Fatalf("Update to commit: %v", err)
+	}
+	if head, _ := Head(dest); head != second {
+		t.Fatalf("Head = %s, want commit %s", head, second)
+	}
+	if ok, _ := AtRef(dest, second); !ok {
+		t.Fatalf("AtRef(%s) should be true", second)
+	}
+}
+
+func TestCloneCommit(t *testing.T) {
+	r := newRemote(t)
+	first := r.commit("a", "1")
+	r.commit("a", "2")
+
+	dest := filepath.Join(t.TempDir(), "plugin")
+	if err := Clone(session(t), r.url(), dest, first[:10], 1); err != nil {
+		t.Fatalf("Clone: %v", err)
+	}
+	if head, _ := Head(dest); head != first {
+		t.Fatalf("Head = %s, want %s", head, first)
+	}
+}
+
+func TestUpdateUnknownRef(t *testing.T) {
+	r := newRemote(t)
+	r.commit("a", "1")
+	dest := filepath.Join(t.TempDir(), "plugin")
+	s := session(t)
+	if err := Clone(s, r.url(), dest, "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := Update(s, dest, "missing", 0); err == nil {
+		t.Fatal("expected an error for a ref origin does not have")
+	}
+}
+
+func TestBehind(t *testing.T) {
+	r := newRemote(t)
+	r.commit("a", "1")
+	dest := filepath.Join(t.TempDir(), "plugin")
+	if err := Clone(session(t), r.url(), dest, "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := Behind(dest); err != nil || n != 0 {
+		t.Fatalf("Behind = %d, %v; want 0", n, err)
+	}
+	r.commit("a", "2")
+	r.commit("a", "3")
+	if n, err := Behind(dest); err != nil || n != 2 {
+		t.Fatalf("Behind = %d, %v; want 2", n, err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	r := newRemote(t)
+	first := r.commit("a", "1")
+	run(t, r.work, "tag", "-a", "v1", "-m", "v1")
+	run(t, r.work, "push", "origin", "v1")
+	second := r.commit("a", "2")
+
+	tests := []struct{ ref, want string }{
+		{"", second},
+		{"main", second},
+		{"v1", first},
+		{first, first},
+	}
+	for _, tt := range tests {
+		got, err := Resolve(r.url(), tt.ref)
+		if err != nil {
+			t.Fatalf("Resolve(%q): %v", tt.ref, err)
+		}
+		if got != tt.want {
+			t.Errorf("Resolve(%q) = %s, want %s", tt.ref, got, tt.want)
+		}
+	}
+	if _, err := Resolve(r.url(), "missing"); err == nil {
+		t.Error("Resolve of a missing ref should fail")
+	}
+}
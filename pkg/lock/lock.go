@@ -0,0 +1,120 @@
+// Package lock reads and writes dotm.lock, which pins what each module
+// resolved to so installs are reproducible across machines.
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the lock file, kept next to config.yaml.
+const FileName = "dotm.lock"
+
+// FormatVersion is the version of the lock file format.
+const FormatVersion = 1
+
+const header = "# Generated by `dotm lock`. Do not edit by hand.\n"
+
+// File is the content of a lock file.
+type File struct {
+	Version int                `yaml:"version"`
+	Modules map[string]*Module `yaml:"modules"`
+}
+
+// Module is what a module resolved to when the lock was written.
+type Module struct {
+	// Hash is the hash of the module's definition in config.yaml. A module
+	// whose definition changed is no longer covered by the lock.
+	Hash string `yaml:"hash"`
+	// Type lists the module's install types, e.g. "git" or "packages,commands".
+	Type string `yaml:"type"`
+	// Versions maps an install type (archive, xcmd, go, cargo, uv_tool,
+	// npm) to the version it resolved to.
+	Versions map[string]string `yaml:"versions,omitempty"`
+	URL      string            `yaml:"url,omitempty"`
+	SHA256   string            `yaml:"sha256,omitempty"`
+	// Packages maps a package manager to the installed version of each
+	// of the module's packages.
+	Packages map[string]map[string]string `yaml:"packages,omitempty"`
+	// Commit is the git commit a `git:` module resolved to.
+	Commit string `yaml:"commit,omitempty"`
+	// Artifacts maps a platform to the release archive for it.
+	Artifacts map[string]Artifact `yaml:"artifacts,omitempty"`
+}
+
+// Artifact is a download pinned by its checksum.
+type Artifact struct {
+	URL    string `yaml:"url"`
+	SHA256 string `yaml:"sha256"`
+}
+
+// Path returns the lock file that belongs to a config file.
+func Path(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), FileName)
+}
+
+// New returns an empty lock file.
+func New() *File {
+	return &File{Version: FormatVersion, Modules: make(map[string]*Module)}
+}
+
+// Load reads a lock file.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := New()
+	if err := yaml.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if f.Version > FormatVersion {
+		return nil, fmt.Errorf("%s uses lock format %d; this dotm supports up to %d", path, f.Version, FormatVersion)
+	}
+	if f.Modules == nil {
+		f.Modules = make(map[string]*Module)
+	}
+	return f, nil
+}
+
+// Save writes the lock file atomically.
+func (f *File) Save(path string) error {
+	data, err := yaml.Marshal(f)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append([]byte(header), data...)); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ErrNotCovered is returned by Check for modules the lock does not cover.
+var ErrNotCovered = errors.New("not covered by the lock file")
+
+// Check returns the locked entry for a module, or ErrNotCovered if the
+// module is missing from the lock or its definition changed since.
+func (f *File) Check(name, hash string) (*Module, error) {
+	m, ok := f.Modules[name]
+	if !ok {
+		return nil, fmt.Errorf("module '%s' is %w", name, ErrNotCovered)
+	}
+	if m.Hash != hash {
+		return nil, fmt.Errorf("module '%s' changed in config.yaml since it was locked: %w", name, ErrNotCovered)
+	}
+	return m, nil
+}
@@ -0,0 +1,54 @@
+package lock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	f := New()
+	f.Modules["plugin"] = &Module{Hash: "abc", Type: "git", URL: "https://example.com/plugin.git", Commit: "0123456789abcdef"}
+	if err := f.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), header) {
+		t.Errorf("lock file does not start with the header:\n%s", data)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := loaded.Check("plugin", "abc")
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if m.Commit != "0123456789abcdef" {
+		t.Errorf("Commit = %q", m.Commit)
+	}
+	if _, err := loaded.Check("plugin", "changed"); !errors.Is(err, ErrNotCovered) {
+		t.Errorf("Check with a changed hash = %v, want ErrNotCovered", err)
+	}
+	if _, err := loaded.Check("other", "abc"); !errors.Is(err, ErrNotCovered) {
+		t.Errorf("Check of a missing module = %v, want ErrNotCovered", err)
+	}
+}
+
+func TestLoadRejectsNewerFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, []byte("version: 99\nmodules: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Fatal("Load of a newer lock format should fail")
+	}
+}
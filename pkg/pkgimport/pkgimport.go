@@ -0,0 +1,151 @@
+// Package pkgimport reads package lists exported from other tools, such as
+// Brewfiles and `apt-mark showmanual` output, so they can be turned into
+// modules.
+package pkgimport
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Package is a package found in an imported list.
+type Package struct {
+	// Name is the package as its manager knows it, e.g. "user/tap/tool".
+	Name string
+	// Source is "brew", "cask", "apt", "pacman" or "pipx".
+	Source string
+}
+
+// ModuleName is the name used for the package's module: the package name
+// without a tap prefix.
+func (p Package) ModuleName() string {
+	return path.Base(p.Name)
+}
+
+// Formats lists the supported input formats.
+var Formats = []string{"brewfile", "apt", "pacman", "pipx"}
+
+// Parse reads a package list. Duplicates are dropped and the order of the
+// input is kept.
+func Parse(format string, r io.Reader) ([]Package, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var pkgs []Package
+	switch format {
+	case "brewfile":
+		pkgs = parseBrewfile(string(data))
+	case "apt":
+		pkgs = parseLines(string(data), "apt", parseAptLine)
+	case "pacman":
+		pkgs = parseLines(string(data), "pacman", firstField)
+	case "pipx":
+		pkgs, err = parsePipx(string(data))
+	default:
+		return nil, fmt.Errorf("unknown format %q (supported: %s)", format, strings.Join(Formats, ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return dedupe(pkgs), nil
+}
+
+var brewfileEntry = regexp.MustCompile(`^(brew|cask)\s+["']([^"']+)["']`)
+
+// parseBrewfile reads `brew` and `cask` entries. Taps, Mac App Store apps
+// and other entry kinds are ignored.
+func parseBrewfile(data string) []Package {
+	var pkgs []Package
+	for _, line := range strings.Split(data, "\n") {
+		if m := brewfileEntry.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			pkgs = append(pkgs, Package{Name: m[2], Source: m[1]})
+		}
+	}
+	return pkgs
+}
+
+// parseLines reads one package per line, as printed by `apt-mark
+// showmanual` or `pacman -Qqe`. name extracts the package from a line and
+// returns "" for lines to skip.
+func parseLines(data, source string, name func(line string) string) []Package {
+	var pkgs []Package
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if n := name(line); n != "" {
+			pkgs = append(pkgs, Package{Name: n, Source: source})
+		}
+	}
+	return pkgs
+}
+
+// parseAptLine accepts `apt-mark showmanual` and `dpkg --get-selections`
+// lines and drops the architecture suffix, e.g. "libc6:amd64".
+func parseAptLine(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) > 1 && fields[1] != "install" && fields[1] != "hold" {
+		return ""
+	}
+	name, _, _ := strings.Cut(fields[0], ":")
+	return name
+}
+
+func firstField(line string) string {
+	return strings.Fields(line)[0]
+}
+
+// parsePipx reads `pipx list --json`, `pipx list --short` ("black 24.1.0")
+// or plain `pipx list` ("package black 24.1.0, installed using ...").
+func parsePipx(data string) ([]Package, error) {
+	if strings.HasPrefix(strings.TrimSpace(data), "{") {
+		var list struct {
+			Venvs map[string]json.RawMessage `json:"venvs"`
+		}
+		if err := json.Unmarshal([]byte(data), &list); err != nil {
+			return nil, fmt.Errorf("parse pipx list --json output: %w", err)
+		}
+		names := make([]string, 0, len(list.Venvs))
+		for name := range list.Venvs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		pkgs := make([]Package, len(names))
+		for i, name := range names {
+			pkgs[i] = Package{Name: name, Source: "pipx"}
+		}
+		return pkgs, nil
+	}
+	return parseLines(data, "pipx", func(line string) string {
+		fields := strings.Fields(line)
+		switch {
+		case fields[0] == "package" && len(fields) > 1:
+			return fields[1]
+		case len(fields) == 2 && fields[0] != "-":
+			return fields[0]
+		}
+		// Headers and the "- app" lines of the long format.
+		return ""
+	}), nil
+}
+
+func dedupe(pkgs []Package) []Package {
+	seen := make(map[Package]bool)
+	var result []Package
+	for _, p := range pkgs {
+		if !seen[p] {
+			seen[p] = true
+			result = append(result, p)
+		}
+	}
+	return result
+}
@@ -0,0 +1,53 @@
+package pkgimport
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		format, input string
+		want          []Package
+	}{
+		{"brewfile", `tap "homebrew/bundle"
+# tools
+brew "git"
+brew "user/tap/tool", args: ["HEAD"]
+cask 'firefox'
+mas "Xcode", id: 497799835
+brew "git"
+`, []Package{{"git", "brew"}, {"user/tap/tool", "brew"}, {"firefox", "cask"}}},
+		{"apt", "zsh\nlibc6:amd64\n\nwget\n", []Package{{"zsh", "apt"}, {"libc6", "apt"}, {"wget", "apt"}}},
+		{"apt", "zsh\t\t\tinstall\nold\t\t\tdeinstall\n", []Package{{"zsh", "apt"}}},
+		{"pacman", "base-devel\nneovim 0.10.2-1\n", []Package{{"base-devel", "pacman"}, {"neovim", "pacman"}}},
+		{"pipx", "black 24.10.0\nruff 0.7.1\n", []Package{{"black", "pipx"}, {"ruff", "pipx"}}},
+		{"pipx", `venvs are in /home/me/.local/share/pipx/venvs
+apps are exposed on your $PATH at /home/me/.local/bin
+   package black 24.10.0, installed using Python 3.12.3
+    - black
+    - blackd
+`, []Package{{"black", "pipx"}}},
+		{"pipx", `{"venvs": {"ruff": {}, "black": {}}}`, []Package{{"black", "pipx"}, {"ruff", "pipx"}}},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.format, strings.NewReader(tt.input))
+		if err != nil {
+			t.Fatalf("Parse(%s): %v", tt.format, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%s, %q) = %v, want %v", tt.format, tt.input, got, tt.want)
+		}
+	}
+
+	if _, err := Parse("gem", strings.NewReader("")); err == nil {
+		t.Error("Parse of an unknown format should fail")
+	}
+}
+
+func TestModuleName(t *testing.T) {
+	if got := (Package{Name: "user/tap/tool"}).ModuleName(); got != "tool" {
+		t.Errorf("ModuleName = %q, want tool", got)
+	}
+}
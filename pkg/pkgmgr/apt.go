@@ -0,0 +1,90 @@
+package pkgmgr
+
+import (
+	"strings"
+
+	"github.com/w31r4/dotm/pkg/executor"
+)
+
+// Apt manages Debian/Ubuntu packages. The package index is refreshed at
+// most once per run, before the first install or upgrade.
+type Apt struct {
+	updated bool
+}
+
+func (a *Apt) Name() string { return "apt" }
+
+func (a *Apt) Privileged() bool { return true }
+
+func (a *Apt) Installed(pkg string) (bool, error) {
+	name, want, pinned := strings.Cut(pkg, "=")
+	v, err := a.Version(name)
+	if err != nil {
+		return false, err
+	}
+	return v != "" && (!pinned || v == want), nil
+}
+
+func (a *Apt) Version(pkg string) (string, error) {
+	out, err := query("dpkg-query", "-W", "-f=${Status}\n${Version}", pkg)
+	if err != nil {
+		return "", err
+	}
+	status, version, _ := strings.Cut(out, "\n")
+	if !strings.HasSuffix(status, "install ok installed") {
+		return "", nil
+	}
+	return version, nil
+}
+
+// Pin uses apt's pkg=version syntax.
+func (a *Apt) Pin(pkg, version string) (string, bool) {
+	return pkg + "=" + version, true
+}
+
+func (a *Apt) Install(s *executor.Session, pkgs []string) error {
+	if len(pkgs) == 0 {
+		return nil
+	}
+	if err := a.update(s); err != nil {
+		return err
+	}
+	return runPackages(s, "DEBIAN_FRONTEND=noninteractive apt-get install -y", pkgs, true)
+}
+
+func (a *Apt) Upgrade(s *executor.Session, pkgs []string) error {
+	if len(pkgs) == 0 {
+		return nil
+	}
+	if err := a.update(s); err != nil {
+		return err
+	}
+	return runPackages(s, "DEBIAN_FRONTEND=noninteractive apt-get install -y --only-upgrade", pkgs, true)
+}
+
+// Outdated simulates an upgrade, which needs no root, and reports the
+// packages apt would upgrade.
+func (a *Apt) Outdated(pkgs []string) ([]string, error) {
+	return outdated(pkgs, func(line string) string {
+		// Inst git [1:2.39.2-1] (1:2.39.5-0+deb12u1 Debian:12.8/stable [amd64])
+		if name, ok := strings.CutPrefix(line, "Inst "); ok {
+			return firstField(name)
+		}
+		return ""
+	}, nil, "apt-get", "-s", "upgrade")
+}
+
+func (a *Apt) Remove(s *executor.Session, pkgs []string) error {
+	return runPackages(s, "DEBIAN_FRONTEND=noninteractive apt-get remove -y", pkgs, true)
+}
+
+func (a *Apt) update(s *executor.Session) error {
+	if a.updated {
+		return nil
+	}
+	if err := s.RunWith("apt-get update", executor.RunOptions{Privileged: true}); err != nil {
+		return err
+	}
+	a.updated = true
+	return nil
+}
@@ -0,0 +1,119 @@
+package pkgmgr
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/w31r4/dotm/pkg/executor"
+)
+
+// fakeBin installs an executable shell script on PATH for the test.
+func fakeBin(t *testing.T, dir, name, script string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatalf("write fake %s: %v", name, err)
+	}
+}
+
+func setupFakeApt(t *testing.T) (logPath string) {
+	t.Helper()
+	dir := t.TempDir()
+	logPath = filepath.Join(dir, "calls.log")
+	fakeBin(t, dir, "apt-get", `echo "apt-get $*" >> "`+logPath+`"`+"\n")
+	fakeBin(t, dir, "dpkg-query", `
+case "$3" in
+  zsh) printf 'install ok installed\n5.9-4' ;;
+  vim) printf 'deinstall ok config-files\n2:9.0.1378-2' ;;
+  *) exit 1 ;;
+esac
+`)
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return logPath
+}
+
+func TestApt_UpdatesOncePerRun(t *testing.T) {
+	if !executor.IsRoot() {
+		t.Skip("apt commands are privileged; test needs to run as root")
+	}
+	logPath := setupFakeApt(t)
+	s := &executor.Session{}
+	apt := &Apt{}
+
+	if err := apt.Install(s, []string{"git", "ripgrep"}); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if err := apt.Install(s, []string{"fd-find"}); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	calls := strings.Split(strings.TrimSpace(string(data)), "\n")
+	want := []string{
+		"apt-get update",
+		"apt-get install -y git ripgrep",
+		"apt-get install -y fd-find",
+	}
+	if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got calls:\n%s\nwant:\n%s", strings.Join(calls, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestMissing(t *testing.T) {
+	setupFakeApt(t)
+	got, err := Missing(&Apt{}, []string{"zsh", "git"})
+	if err != nil {
+		t.Fatalf("Missing: %v", err)
+	}
+	if len(got) != 1 || got[0] != "git" {
+		t.Fatalf("got %v, want [git]", got)
+	}
+}
+
+func TestApt_PinnedVersion(t *testing.T) {
+	setupFakeApt(t)
+	apt := &Apt{}
+	for pkg, want := range map[string]string{"zsh": "5.9-4", "vim": "", "git": ""} {
+		if got, err := apt.Version(pkg); err != nil || got != want {
+			t.Errorf("Version(%s) = %q, %v, want %q", pkg, got, err, want)
+		}
+	}
+	pinned, ok := apt.Pin("zsh", "5.9-4")
+	if !ok || pinned != "zsh=5.9-4" {
+		t.Fatalf("Pin = %q, %v", pinned, ok)
+	}
+	got, err := Missing(apt, []string{pinned, "zsh=5.8-1"})
+	if err != nil {
+		t.Fatalf("Missing: %v", err)
+	}
+	if len(got) != 1 || got[0] != "zsh=5.8-1" {
+		t.Fatalf("Missing with pins = %v, want [zsh=5.8-1]", got)
+	}
+}
+
+func TestApt_Outdated(t *testing.T) {
+	dir := t.TempDir()
+	fakeBin(t, dir, "apt-get", `
+[ "$1 $2" = "-s upgrade" ] || exit 2
+cat <<'OUT'
+Reading package lists...
+Inst git [1:2.39.2-1] (1:2.39.5-0+deb12u1 Debian:12.8/stable [amd64])
+Inst libc6 [2.36-9] (2.36-9+deb12u9 Debian:12.8/stable [amd64])
+Conf git (1:2.39.5-0+deb12u1 Debian:12.8/stable [amd64])
+OUT
+`)
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	got, err := (&Apt{}).Outdated([]string{"zsh", "git"})
+	if err != nil {
+		t.Fatalf("Outdated: %v", err)
+	}
+	if len(got) != 1 || got[0] != "git" {
+		t.Fatalf("got %v, want [git]", got)
+	}
+}
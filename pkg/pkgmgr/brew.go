@@ -0,0 +1,53 @@
+package pkgmgr
+
+import (
+	"strings"
+
+	"github.com/w31r4/dotm/pkg/executor"
+)
+
+// Brew manages Homebrew formulae. Homebrew refuses to run as root, so its
+// commands are never privileged.
+type Brew struct{}
+
+func (b *Brew) Name() string { return "brew" }
+
+func (b *Brew) Privileged() bool { return false }
+
+func (b *Brew) Installed(pkg string) (bool, error) {
+	return succeeds("brew", "list", "--versions", pkg)
+}
+
+func (b *Brew) Version(pkg string) (string, error) {
+	// "git 2.47.1": the formula followed by its installed versions.
+	out, err := query("brew", "list", "--versions", pkg)
+	if fields := strings.Fields(out); len(fields) > 1 {
+		return fields[1], err
+	}
+	return "", err
+}
+
+// Pin fails: Homebrew only installs the current version of a formula.
+func (b *Brew) Pin(pkg, version string) (string, bool) {
+	return "", false
+}
+
+func (b *Brew) Install(s *executor.Session, pkgs []string) error {
+	return runPackages(s, "brew install", pkgs, false)
+}
+
+func (b *Brew) Upgrade(s *executor.Session, pkgs []string) error {
+	return runPackages(s, "brew upgrade", pkgs, false)
+}
+
+func (b *Brew) Outdated(pkgs []string) ([]string, error) {
+	return outdated(pkgs, func(line string) string {
+		// Formulae from taps are listed as user/tap/name.
+		name := firstField(line)
+		return name[strings.LastIndex(name, "/")+1:]
+	}, nil, "brew", "outdated", "--quiet")
+}
+
+func (b *Brew) Remove(s *executor.Session, pkgs []string) error {
+	return runPackages(s, "brew uninstall", pkgs, false)
+}
@@ -0,0 +1,56 @@
+package pkgmgr
+
+import (
+	"strings"
+
+	"github.com/w31r4/dotm/pkg/executor"
+)
+
+// Dnf manages Fedora/RHEL packages.
+type Dnf struct{}
+
+func (d *Dnf) Name() string { return "dnf" }
+
+func (d *Dnf) Privileged() bool { return true }
+
+// Installed also accepts the name-version-release specs Pin returns,
+// which rpm matches exactly.
+func (d *Dnf) Installed(pkg string) (bool, error) {
+	return succeeds("rpm", "-q", pkg)
+}
+
+func (d *Dnf) Version(pkg string) (string, error) {
+	return query("rpm", "-q", "--queryformat", "%{VERSION}-%{RELEASE}", pkg)
+}
+
+// Pin uses the name-version-release syntax dnf and rpm share.
+func (d *Dnf) Pin(pkg, version string) (string, bool) {
+	return pkg + "-" + version, true
+}
+
+func (d *Dnf) Install(s *executor.Session, pkgs []string) error {
+	return runPackages(s, "dnf install -y", pkgs, true)
+}
+
+func (d *Dnf) Upgrade(s *executor.Session, pkgs []string) error {
+	return runPackages(s, "dnf upgrade -y", pkgs, true)
+}
+
+// Outdated lists upgradable packages with `dnf check-update`, which exits
+// 100 when updates are available.
+func (d *Dnf) Outdated(pkgs []string) ([]string, error) {
+	return outdated(pkgs, func(line string) string {
+		// git.x86_64  2.47.1-1.fc41  updates
+		// Names may contain dots (python3.11), so cut at the last one.
+		field := firstField(line)
+		i := strings.LastIndex(field, ".")
+		if i < 0 || strings.HasSuffix(line, ":") {
+			return ""
+		}
+		return field[:i]
+	}, []int{100}, "dnf", "-q", "check-update")
+}
+
+func (d *Dnf) Remove(s *executor.Session, pkgs []string) error {
+	return runPackages(s, "dnf remove -y", pkgs, true)
+}
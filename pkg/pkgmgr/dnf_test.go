@@ -0,0 +1,31 @@
+package pkgmgr
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestDnf_Outdated(t *testing.T) {
+	dir := t.TempDir()
+	fakeBin(t, dir, "dnf", `
+[ "$1 $2" = "-q check-update" ] || exit 2
+cat <<'OUT'
+
+git.x86_64                 2.47.1-1.fc41      updates
+python3.11.x86_64          3.11.11-1.fc41     updates
+python3.11-libs.x86_64     3.11.11-1.fc41     updates
+Obsoleting Packages:
+OUT
+exit 100
+`)
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	got, err := (&Dnf{}).Outdated([]string{"zsh", "git", "python3.11", "python3"})
+	if err != nil {
+		t.Fatalf("Outdated: %v", err)
+	}
+	if want := []string{"git", "python3.11"}; !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
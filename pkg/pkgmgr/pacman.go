@@ -0,0 +1,49 @@
+package pkgmgr
+
+import (
+	"strings"
+
+	"github.com/w31r4/dotm/pkg/executor"
+)
+
+// Pacman manages Arch Linux packages.
+type Pacman struct{}
+
+func (p *Pacman) Name() string { return "pacman" }
+
+func (p *Pacman) Privileged() bool { return true }
+
+func (p *Pacman) Installed(pkg string) (bool, error) {
+	return succeeds("pacman", "-Q", pkg)
+}
+
+func (p *Pacman) Version(pkg string) (string, error) {
+	out, err := query("pacman", "-Q", pkg)
+	if fields := strings.Fields(out); len(fields) > 1 {
+		return fields[1], err
+	}
+	return "", err
+}
+
+// Pin fails: pacman only installs the version in the current repositories.
+func (p *Pacman) Pin(pkg, version string) (string, bool) {
+	return "", false
+}
+
+func (p *Pacman) Install(s *executor.Session, pkgs []string) error {
+	return runPackages(s, "pacman -S --needed --noconfirm", pkgs, true)
+}
+
+func (p *Pacman) Upgrade(s *executor.Session, pkgs []string) error {
+	return runPackages(s, "pacman -S --noconfirm", pkgs, true)
+}
+
+// Outdated lists upgradable packages with `pacman -Qu`, which exits 1
+// when there are none.
+func (p *Pacman) Outdated(pkgs []string) ([]string, error) {
+	return outdated(pkgs, firstField, []int{1}, "pacman", "-Qu")
+}
+
+func (p *Pacman) Remove(s *executor.Session, pkgs []string) error {
+	return runPackages(s, "pacman -R --noconfirm", pkgs, true)
+}
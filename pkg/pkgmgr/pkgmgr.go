@@ -0,0 +1,175 @@
+// Package pkgmgr implements the system package manager backends used by
+// the `packages:` field of a module.
+package pkgmgr
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/w31r4/dotm/pkg/executor"
+)
+
+// Manager is a system package manager backend.
+type Manager interface {
+	// Name is the key used under `packages:` in config.yaml.
+	Name() string
+	// Installed reports whether a package is installed. pkg may be a spec
+	// returned by Pin, which is only installed at that version.
+	Installed(pkg string) (bool, error)
+	// Version returns the installed version of a package, or "" if it is
+	// not installed.
+	Version(pkg string) (string, error)
+	// Pin returns the spec that installs exactly version of pkg, or false
+	// if the manager cannot install a given version.
+	Pin(pkg, version string) (string, bool)
+	// Install installs packages in a single invocation.
+	Install(s *executor.Session, pkgs []string) error
+	// Upgrade upgrades installed packages to their latest version.
+	Upgrade(s *executor.Session, pkgs []string) error
+	// Outdated returns the packages among pkgs that have a newer version
+	// available. It only reads the manager's current package index.
+	Outdated(pkgs []string) ([]string, error)
+	// Remove uninstalls packages.
+	Remove(s *executor.Session, pkgs []string) error
+	// Privileged reports whether the manager's commands need root.
+	Privileged() bool
+}
+
+// Names lists the supported managers in detection order.
+var Names = []string{"apt", "dnf", "pacman", "brew"}
+
+// New returns a fresh backend by name. Backends keep per-run state (for
+// example whether the apt index was refreshed), so callers should reuse
+// the instance for the whole run.
+func New(name string) (Manager, error) {
+	switch name {
+	case "apt":
+		return &Apt{}, nil
+	case "dnf":
+		return &Dnf{}, nil
+	case "pacman":
+		return &Pacman{}, nil
+	case "brew":
+		return &Brew{}, nil
+	default:
+		return nil, fmt.Errorf("unknown package manager %q (supported: %s)", name, strings.Join(Names, ", "))
+	}
+}
+
+// Detect returns the package manager available on this machine.
+func Detect() (Manager, error) {
+	if runtime.GOOS == "darwin" {
+		if available("brew") {
+			return New("brew")
+		}
+		return nil, fmt.Errorf("no supported package manager found (install Homebrew)")
+	}
+
+	binaries := map[string]string{"apt": "apt-get", "dnf": "dnf", "pacman": "pacman", "brew": "brew"}
+	for _, name := range Names {
+		if available(binaries[name]) {
+			return New(name)
+		}
+	}
+	return nil, fmt.Errorf("no supported package manager found (supported: %s)", strings.Join(Names, ", "))
+}
+
+// Missing returns the packages that are not installed yet.
+func Missing(m Manager, pkgs []string) ([]string, error) {
+	var missing []string
+	for _, pkg := range pkgs {
+		ok, err := m.Installed(pkg)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			missing = append(missing, pkg)
+		}
+	}
+	return missing, nil
+}
+
+// outdated runs a read-only query listing upgradable packages and returns
+// the requested packages it mentions. name extracts the package name from
+// a line of output, returning "" for lines to ignore. Exit codes listed in
+// ok are not errors; some managers signal "updates available" that way.
+func outdated(pkgs []string, name func(line string) string, ok []int, command string, args ...string) ([]string, error) {
+	out, err := exec.Command(command, args...).Output()
+	if err != nil {
+		exitErr, isExit := err.(*exec.ExitError)
+		if !isExit || !slices.Contains(ok, exitErr.ExitCode()) {
+			return nil, fmt.Errorf("%s: %w", command, err)
+		}
+	}
+
+	upgradable := make(map[string]bool)
+	for _, line := range strings.Split(string(out), "\n") {
+		if n := name(strings.TrimSpace(line)); n != "" {
+			upgradable[n] = true
+		}
+	}
+	var result []string
+	for _, pkg := range pkgs {
+		if upgradable[pkg] {
+			result = append(result, pkg)
+		}
+	}
+	return result, nil
+}
+
+// firstField returns the first whitespace-separated field of line.
+func firstField(line string) string {
+	if fields := strings.Fields(line); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+func available(binary string) bool {
+	_, err := exec.LookPath(binary)
+	return err == nil
+}
+
+// query runs a read-only query and returns its output, or "" if it exited
+// with an error, e.g. because the package is not installed.
+func query(name string, args ...string) (string, error) {
+	out, err := exec.Command(name, args...).Output()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return "", nil
+		}
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// succeeds runs a read-only query and reports whether it exited with 0.
+func succeeds(name string, args ...string) (bool, error) {
+	err := exec.Command(name, args...).Run()
+	if err == nil {
+		return true, nil
+	}
+	if _, ok := err.(*exec.ExitError); ok {
+		return false, nil
+	}
+	return false, fmt.Errorf("%s: %w", name, err)
+}
+
+// runPackages runs a package manager command for all packages at once.
+func runPackages(s *executor.Session, command string, pkgs []string, privileged bool) error {
+	if len(pkgs) == 0 {
+		return nil
+	}
+	return s.RunWith(command+" "+joinPackages(pkgs), executor.RunOptions{Privileged: privileged})
+}
+
+func joinPackages(pkgs []string) string {
+	quoted := make([]string, len(pkgs))
+	for i, pkg := range pkgs {
+		quoted[i] = executor.ShellQuote(pkg)
+	}
+	return strings.Join(quoted, " ")
+}
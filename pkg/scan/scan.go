@@ -0,0 +1,191 @@
+// Package scan guards the dotfiles repository against secrets: it matches
+// paths against a deny list and looks for private keys and tokens in file
+// contents.
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultDeny lists paths, relative to the home directory, that hold
+// credentials and must never be committed. A pattern ending in "/**"
+// matches everything below a directory; other patterns use path.Match
+// syntax and are also matched against the file name alone when they have
+// no slash.
+var DefaultDeny = []string{
+	".ssh/id_*",
+	".ssh/*_key",
+	".gnupg/**",
+	".password-store/**",
+	".aws/credentials",
+	".azure/**",
+	".config/gcloud/**",
+	".kube/config",
+	".docker/config.json",
+	".config/gh/hosts.yml",
+	".git-credentials",
+	".netrc",
+	".pgpass",
+	".vault-token",
+	"*.pem",
+	"*.p12",
+	"*.pfx",
+	"*.key",
+}
+
+// allowed are exceptions to DefaultDeny: public halves of key pairs.
+// They do not override patterns added by the user.
+var allowed = []string{"*.pub"}
+
+// Denied reports the first pattern in patterns that matches rel, a
+// slash-separated path relative to the home directory.
+func Denied(rel string, patterns []string) (string, bool) {
+	rel = path.Clean(strings.TrimPrefix(rel, "./"))
+	for _, pattern := range patterns {
+		if match(pattern, rel) && !(slices.Contains(DefaultDeny, pattern) && isAllowed(rel)) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// isAllowed reports whether rel is an exception to DefaultDeny.
+func isAllowed(rel string) bool {
+	for _, pattern := range allowed {
+		if match(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+func match(pattern, rel string) bool {
+	pattern = strings.TrimPrefix(pattern, "~/")
+	if dir, ok := strings.CutSuffix(pattern, "/**"); ok {
+		return rel == dir || strings.HasPrefix(rel, dir+"/")
+	}
+	if ok, _ := path.Match(pattern, rel); ok {
+		return true
+	}
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	return false
+}
+
+// A Rule recognizes one kind of secret.
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// Rules are the secrets Scan looks for.
+var Rules = []Rule{
+	{"private key", regexp.MustCompile(`-----BEGIN ((RSA|DSA|EC|OPENSSH|ENCRYPTED|PGP) )?PRIVATE KEY( BLOCK)?-----`)},
+	{"AWS access key", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"AWS secret key", regexp.MustCompile(`(?i)aws_secret_access_key\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}`)},
+	{"GitHub token", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{60,})\b`)},
+	{"GitLab token", regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"Stripe key", regexp.MustCompile(`\b[rs]k_live_[0-9A-Za-z]{24,}\b`)},
+	{"npm token", regexp.MustCompile(`\bnpm_[A-Za-z0-9]{36}\b`)},
+	{"OpenAI key", regexp.MustCompile(`\bsk-(proj-)?[A-Za-z0-9_-]{32,}\b`)},
+}
+
+// A Finding is a line that looks like it holds a secret.
+type Finding struct {
+	Path string
+	Line int
+	Rule string
+}
+
+// File scans the file at p. Binary files are skipped.
+func File(p string) ([]Finding, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	head := make([]byte, 8000)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	if bytes.IndexByte(head[:n], 0) >= 0 {
+		return nil, nil
+	}
+	return Reader(p, io.MultiReader(bytes.NewReader(head[:n]), f))
+}
+
+// Reader scans r line by line, reporting findings against name.
+func Reader(name string, r io.Reader) ([]Finding, error) {
+	var findings []Finding
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if f, ok := Line(scanner.Text()); ok {
+			findings = append(findings, Finding{Path: name, Line: line, Rule: f})
+		}
+	}
+	return findings, scanner.Err()
+}
+
+// Diff scans the lines a git diff adds, reporting findings against the
+// new file and line numbers. Removed and context lines are ignored.
+func Diff(r io.Reader) ([]Finding, error) {
+	var findings []Finding
+	var name string
+	var line int
+	// Between a "diff" line and the first hunk, "+++ " names the file
+	// instead of adding a line.
+	header := false
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		text := scanner.Text()
+		switch {
+		case strings.HasPrefix(text, "diff "):
+			header = true
+		case header && strings.HasPrefix(text, "+++ "):
+			name = strings.TrimPrefix(strings.TrimPrefix(text, "+++ "), "b/")
+		case strings.HasPrefix(text, "@@ "):
+			header = false
+			// @@ -a,b +c,d @@: the next added line is line c.
+			fields := strings.Fields(text)
+			if len(fields) > 2 {
+				start, _, _ := strings.Cut(strings.TrimPrefix(fields[2], "+"), ",")
+				line, _ = strconv.Atoi(start)
+			}
+		case header:
+		case strings.HasPrefix(text, "+"):
+			if f, ok := Line(text[1:]); ok {
+				findings = append(findings, Finding{Path: name, Line: line, Rule: f})
+			}
+			line++
+		case strings.HasPrefix(text, " "):
+			line++
+		}
+	}
+	return findings, scanner.Err()
+}
+
+// Line returns the name of the first rule that matches text.
+func Line(text string) (string, bool) {
+	for _, rule := range Rules {
+		if rule.Pattern.MatchString(text) {
+			return rule.Name, true
+		}
+	}
+	return "", false
+}
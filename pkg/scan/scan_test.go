@@ -0,0 +1,104 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDenied(t *testing.T) {
+	tests := []struct {
+		rel  string
+		want bool
+	}{
+		{".ssh/id_ed25519", true},
+		{".ssh/id_ed25519.pub", false},
+		{".ssh/config", false},
+		{".aws/credentials", true},
+		{".aws/config", false},
+		{".gnupg/private-keys-v1.d/key", true},
+		{"certs/server.pem", true},
+		{".zshrc", false},
+		{".netrc", true},
+	}
+	for _, tt := range tests {
+		if _, got := Denied(tt.rel, DefaultDeny); got != tt.want {
+			t.Errorf("Denied(%q) = %v, want %v", tt.rel, got, tt.want)
+		}
+	}
+
+	extra := append(DefaultDeny, "~/.config/work/**", "*.secret")
+	for _, rel := range []string{".config/work/token", "notes/api.secret", ".config/work/deploy.pub"} {
+		if _, denied := Denied(rel, extra); !denied {
+			t.Errorf("Denied(%q) with custom patterns = false", rel)
+		}
+	}
+	if _, denied := Denied(".ssh/id_ed25519.pub", extra); denied {
+		t.Error("custom patterns dropped the *.pub exception to DefaultDeny")
+	}
+}
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "env.sh")
+	// Split the token so this file does not look like it holds one.
+	token := "ghp_" + strings.Repeat("a1B2", 9)
+	body := "export EDITOR=vim\nexport GITHUB_TOKEN=" + token + "\n-----BEGIN OPENSSH " + "PRIVATE KEY-----\n"
+	if err := os.WriteFile(secret, []byte(body), 0600); err != nil {
+		t.Fatal(err)
+	}
+	findings, err := File(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 2 || findings[0].Line != 2 || findings[0].Rule != "GitHub token" || findings[1].Rule != "private key" {
+		t.Fatalf("findings = %+v", findings)
+	}
+
+	clean := filepath.Join(dir, "zshrc")
+	if err := os.WriteFile(clean, []byte("alias ll='ls -l'\nexport PATH=$HOME/bin:$PATH\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if findings, err := File(clean); err != nil || len(findings) != 0 {
+		t.Fatalf("clean file: %+v, %v", findings, err)
+	}
+
+	binary := filepath.Join(dir, "blob")
+	if err := os.WriteFile(binary, append([]byte{0, 1, 2}, token...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if findings, err := File(binary); err != nil || len(findings) != 0 {
+		t.Fatalf("binary file: %+v, %v", findings, err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	// Split the token so this file does not look like it holds one.
+	token := "ghp_" + strings.Repeat("a1B2", 9)
+	diff := `diff --git a/.zshrc b/.zshrc
+index 1111111..2222222 100644
+--- a/.zshrc
++++ b/.zshrc
+@@ -3,0 +4,2 @@ alias ll='ls -l'
++export EDITOR=vim
++export GITHUB_TOKEN=` + token + `
+@@ -9 +11 @@
+-export OLD_TOKEN=` + token + `
+++++ not a header
+diff --git a/.env b/.env
+new file mode 100644
+--- /dev/null
++++ b/.env
+@@ -0,0 +1 @@
++-----BEGIN RSA ` + "PRIVATE KEY-----\n"
+	findings, err := Diff(strings.NewReader(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Finding{{Path: ".zshrc", Line: 5, Rule: "GitHub token"}, {Path: ".env", Line: 1, Rule: "private key"}}
+	if !slices.Equal(findings, want) {
+		t.Fatalf("findings = %+v, want %+v", findings, want)
+	}
+}
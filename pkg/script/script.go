@@ -0,0 +1,86 @@
+// Package script downloads installer scripts into a local cache so they
+// can be verified, reviewed and rerun without fetching them again.
+package script
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/w31r4/dotm/pkg/archive"
+)
+
+// CacheDir returns the script cache directory. It honours $XDG_CACHE_HOME
+// and falls back to ~/.cache/dotm/scripts.
+func CacheDir() (string, error) {
+	base := os.Getenv("XDG_CACHE_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(base, "dotm", "scripts"), nil
+}
+
+// Fetch returns the path to a cached copy of the script at url and its
+// sha256. A cached copy is reused when present, so reruns execute exactly
+// the script that ran before. When want is set, the script must match it;
+// a cached copy that does not match is downloaded again.
+func Fetch(url, want string) (path, digest string, err error) {
+	dir, err := CacheDir()
+	if err != nil {
+		return "", "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", "", fmt.Errorf("create script cache: %w", err)
+	}
+
+	path = filepath.Join(dir, cacheKey(url)+".sh")
+	if digest, err := fileSHA256(path); err == nil {
+		if want == "" || archive.Verify(digest, want) == nil {
+			return path, digest, nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", "", err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".download-*")
+	if err != nil {
+		return "", "", err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	digest, err = archive.Download(url, tmp.Name())
+	if err != nil {
+		return "", "", err
+	}
+	if want != "" {
+		if err := archive.Verify(digest, want); err != nil {
+			return "", "", fmt.Errorf("%s: %w", url, err)
+		}
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", "", err
+	}
+	return path, digest, nil
+}
+
+func cacheKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:8])
+}
+
+func fileSHA256(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,91 @@
+package script
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+)
+
+func serve(t *testing.T, body *string) (*httptest.Server, *int32) {
+	t.Helper()
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write([]byte(*body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func digestOf(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestFetchCachesScript(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	body := "echo one\n"
+	srv, hits := serve(t, &body)
+
+	path, digest, err := Fetch(srv.URL+"/install.sh", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest != digestOf("echo one\n") {
+		t.Fatalf("digest = %s", digest)
+	}
+
+	// The server changes the script; the cached copy must still be used.
+	body = "echo two\n"
+	again, digest2, err := Fetch(srv.URL+"/install.sh", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != path || digest2 != digest {
+		t.Fatalf("cache not reused: %s %s", again, digest2)
+	}
+	if *hits != 1 {
+		t.Fatalf("downloads = %d, want 1", *hits)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "echo one\n" {
+		t.Fatalf("cached script = %q", data)
+	}
+}
+
+func TestFetchRefreshesStaleCacheForPin(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	body := "echo one\n"
+	srv, _ := serve(t, &body)
+
+	if _, _, err := Fetch(srv.URL, ""); err != nil {
+		t.Fatal(err)
+	}
+	body = "echo two\n"
+	_, digest, err := Fetch(srv.URL, digestOf("echo two\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest != digestOf("echo two\n") {
+		t.Fatalf("digest = %s", digest)
+	}
+}
+
+func TestFetchRejectsChecksumMismatch(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	body := "echo evil\n"
+	srv, _ := serve(t, &body)
+
+	if _, _, err := Fetch(srv.URL, digestOf("echo good\n")); err == nil {
+		t.Fatal("expected checksum mismatch")
+	}
+	dir, _ := CacheDir()
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Fatalf("cache should be empty after a mismatch, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,260 @@
+// Package semver parses tool versions and checks them against constraints
+// such as ">=1.25 <2". Parsing is lenient because tools rarely print strict
+// semantic versions: a leading "v" is ignored and missing minor or patch
+// numbers count as zero.
+package semver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed version number.
+type Version struct {
+	Major, Minor, Patch int
+	// Prerelease is the part after "-", e.g. "rc1" in 1.25.0-rc1.
+	Prerelease string
+	// parts is the number of numeric components given (1 to 3).
+	parts int
+	raw   string
+}
+
+var versionPattern = regexp.MustCompile(`^v?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
+
+// Parse parses a version such as "1.25.3", "v2", "1.25" or "1.0.0-rc.1".
+func Parse(s string) (Version, error) {
+	m := versionPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return Version{}, fmt.Errorf("invalid version %q", s)
+	}
+	v := Version{Prerelease: m[4], raw: s}
+	for i, field := range []*int{&v.Major, &v.Minor, &v.Patch} {
+		if m[i+1] == "" {
+			break
+		}
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid version %q: %w", s, err)
+		}
+		*field = n
+		v.parts = i + 1
+	}
+	return v, nil
+}
+
+// String returns the version as it was parsed.
+func (v Version) String() string {
+	if v.raw != "" {
+		return v.raw
+	}
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 depending on whether v is lower than, equal
+// to or higher than o. Prereleases sort before the release they precede.
+func (v Version) Compare(o Version) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+	return comparePrerelease(v.Prerelease, o.Prerelease)
+}
+
+func comparePrerelease(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				return sign(an - bn)
+			}
+		case aErr == nil:
+			return -1 // numeric identifiers sort first
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return sign(len(as) - len(bs))
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// Constraint is a set of alternatives ("||"), each of which is a list of
+// comparisons that must all hold.
+type Constraint struct {
+	alternatives [][]comparison
+	raw          string
+}
+
+type comparison struct {
+	op      string
+	version Version
+}
+
+var comparisonPattern = regexp.MustCompile(`^(>=|<=|!=|==|=|>|<|~|\^)?\s*(\S+)$`)
+
+// ParseConstraint parses a constraint. Comparisons are separated by spaces
+// or commas and all must hold; "||" separates alternatives. Supported
+// operators are =, !=, >, >=, <, <=, ~ (same minor) and ^ (same major).
+// A bare version matches every version it is a prefix of, so "1.25"
+// accepts 1.25.0 and 1.25.3.
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{raw: s}
+	for _, alt := range strings.Split(s, "||") {
+		var comparisons []comparison
+		tokens := strings.Fields(strings.ReplaceAll(alt, ",", " "))
+		for i := 0; i < len(tokens); i++ {
+			token := tokens[i]
+			// Allow a space between the operator and the version.
+			if isOperator(token) && i+1 < len(tokens) {
+				i++
+				token += tokens[i]
+			}
+			m := comparisonPattern.FindStringSubmatch(token)
+			if m == nil {
+				return Constraint{}, fmt.Errorf("invalid constraint %q", s)
+			}
+			v, err := Parse(m[2])
+			if err != nil {
+				return Constraint{}, fmt.Errorf("invalid constraint %q: %w", s, err)
+			}
+			comparisons = append(comparisons, comparison{op: m[1], version: v})
+		}
+		if len(comparisons) == 0 {
+			return Constraint{}, fmt.Errorf("invalid constraint %q: empty alternative", s)
+		}
+		c.alternatives = append(c.alternatives, comparisons)
+	}
+	return c, nil
+}
+
+func isOperator(s string) bool {
+	switch s {
+	case "=", "==", "!=", ">", ">=", "<", "<=", "~", "^":
+		return true
+	}
+	return false
+}
+
+// String returns the constraint as it was written.
+func (c Constraint) String() string {
+	return c.raw
+}
+
+// Check reports whether v satisfies the constraint.
+func (c Constraint) Check(v Version) bool {
+	for _, alt := range c.alternatives {
+		ok := true
+		for _, cmp := range alt {
+			if !cmp.check(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (cmp comparison) check(v Version) bool {
+	want := cmp.version
+	switch cmp.op {
+	case "", "=", "==":
+		return matchesPrefix(v, want)
+	case "!=":
+		return !matchesPrefix(v, want)
+	case ">":
+		// ">1.25" excludes all of 1.25.x.
+		return v.Compare(want) > 0 && !matchesPrefix(v, want)
+	case ">=":
+		return v.Compare(want) >= 0
+	case "<":
+		return v.Compare(want) < 0
+	case "<=":
+		return v.Compare(want) <= 0 || matchesPrefix(v, want)
+	case "~":
+		// ~1.25.3 := >=1.25.3 <1.26; ~1 := >=1 <2
+		if v.Compare(want) < 0 || v.Major != want.Major {
+			return false
+		}
+		return want.parts == 1 || v.Minor == want.Minor
+	case "^":
+		// ^1.25.3 := >=1.25.3 <2; ^0.3 := >=0.3 <0.4
+		if v.Compare(want) < 0 || v.Major != want.Major {
+			return false
+		}
+		return want.Major != 0 || want.parts == 1 || v.Minor == want.Minor
+	}
+	return false
+}
+
+// matchesPrefix reports whether v equals want in every component want
+// specifies.
+func matchesPrefix(v, want Version) bool {
+	if v.Major != want.Major {
+		return false
+	}
+	if want.parts >= 2 && v.Minor != want.Minor {
+		return false
+	}
+	if want.parts >= 3 && (v.Patch != want.Patch || v.Prerelease != want.Prerelease) {
+		return false
+	}
+	return true
+}
+
+// defaultPattern finds the first version-like token in command output,
+// e.g. "1.25.3" in "go version go1.25.3 linux/amd64".
+var defaultPattern = regexp.MustCompile(`\d+(?:\.\d+)+(?:-[0-9A-Za-z.]+)?`)
+
+// Extract finds a version in output. With a pattern, the first capture
+// group (or the whole match if it has none) is used; without one, the
+// first dotted number is.
+func Extract(output, pattern string) (string, error) {
+	re := defaultPattern
+	if pattern != "" {
+		var err error
+		if re, err = regexp.Compile(pattern); err != nil {
+			return "", fmt.Errorf("invalid version regex: %w", err)
+		}
+	}
+	m := re.FindStringSubmatch(output)
+	if m == nil {
+		return "", fmt.Errorf("no version found in %q", strings.TrimSpace(output))
+	}
+	if len(m) > 1 && m[1] != "" {
+		return m[1], nil
+	}
+	return m[0], nil
+}
@@ -0,0 +1,104 @@
+package semver
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.25.3", "1.25.3", 0},
+		{"v1.25", "1.25.0", 0},
+		{"1.19.13", "1.25", -1},
+		{"2", "1.99.99", 1},
+		{"1.0.0-rc.1", "1.0.0", -1},
+		{"1.0.0-rc.2", "1.0.0-rc.10", -1},
+		{"1.0.0-alpha", "1.0.0-1", 1},
+	}
+	for _, tt := range tests {
+		a, err := Parse(tt.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := Parse(tt.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := a.Compare(b); got != tt.want {
+			t.Errorf("Compare(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{">=1.25 <2", "1.25.0", true},
+		{">=1.25 <2", "1.25.3", true},
+		{">=1.25 <2", "1.19.13", false},
+		{">=1.25 <2", "2.0.0", false},
+		{">=1.25, <2", "1.30", true},
+		{">= 1.25", "1.26", true},
+		{"1.25", "1.25.7", true},
+		{"1.25", "1.26.0", false},
+		{"=1.25.3", "1.25.4", false},
+		{"!=1.24", "1.24.9", false},
+		{">1.25", "1.25.9", false},
+		{">1.25", "1.26.0", true},
+		{"<=1.25", "1.25.9", true},
+		{"~1.25.3", "1.25.9", true},
+		{"~1.25.3", "1.26.0", false},
+		{"^1.25", "1.99.0", true},
+		{"^1.25", "2.0.0", false},
+		{"^0.3", "0.4.0", false},
+		{"<1.20 || >=1.25", "1.22", false},
+		{"<1.20 || >=1.25", "1.19", true},
+	}
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Fatalf("ParseConstraint(%q): %v", tt.constraint, err)
+		}
+		v, err := Parse(tt.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Check(v); got != tt.want {
+			t.Errorf("%q.Check(%s) = %v, want %v", tt.constraint, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestParseConstraintErrors(t *testing.T) {
+	for _, s := range []string{"", ">=", "latest", ">=1.x", "1.2 ||"} {
+		if _, err := ParseConstraint(s); err == nil {
+			t.Errorf("ParseConstraint(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		output, pattern, want string
+	}{
+		{"go version go1.25.3 linux/amd64", "", "1.25.3"},
+		{"git version 2.39.5", "", "2.39.5"},
+		{"Python 3.12.1\n", `Python (\S+)`, "3.12.1"},
+		{"zsh 5.9 (x86_64-pc-linux-gnu)", "", "5.9"},
+	}
+	for _, tt := range tests {
+		got, err := Extract(tt.output, tt.pattern)
+		if err != nil {
+			t.Fatalf("Extract(%q): %v", tt.output, err)
+		}
+		if got != tt.want {
+			t.Errorf("Extract(%q, %q) = %q, want %q", tt.output, tt.pattern, got, tt.want)
+		}
+	}
+	if _, err := Extract("command not found", ""); err == nil {
+		t.Error("Extract without a version should fail")
+	}
+}
@@ -0,0 +1,63 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Installed records what dotm's built-in install types put on the machine,
+// keyed by module name.
+type Installed struct {
+	Modules map[string]*InstalledModule `json:"modules"`
+
+	path string
+}
+
+// InstalledModule describes one module installed by a built-in type.
+type InstalledModule struct {
+	Type        string    `json:"type"`
+	Version     string    `json:"version,omitempty"`
+	URL         string    `json:"url,omitempty"`
+	SHA256      string    `json:"sha256,omitempty"`
+	Files       []string  `json:"files,omitempty"`
+	InstalledAt time.Time `json:"installed_at"`
+}
+
+// InstalledPath returns the location of the installed-modules record.
+func InstalledPath() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "installed.json"), nil
+}
+
+// LoadInstalled reads the record at path. A missing file yields an empty
+// record.
+func LoadInstalled(path string) (*Installed, error) {
+	s := &Installed{Modules: make(map[string]*InstalledModule), path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if s.Modules == nil {
+		s.Modules = make(map[string]*InstalledModule)
+	}
+	return s, nil
+}
+
+// Save writes the record back to disk.
+func (s *Installed) Save() error {
+	if s.path == "" {
+		return errors.New("installed state has no path")
+	}
+	return writeJSON(s.path, s)
+}
@@ -0,0 +1,96 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Journal records the progress of an install run so an interrupted run can
+// be resumed with `dotm install --resume`.
+type Journal struct {
+	ConfigPath string                   `json:"config_path"`
+	Modules    []string                 `json:"modules"`
+	StartedAt  time.Time                `json:"started_at"`
+	UpdatedAt  time.Time                `json:"updated_at"`
+	Finished   bool                     `json:"finished"`
+	Entries    map[string]*JournalEntry `json:"entries"`
+
+	path string
+}
+
+// JournalEntry is the checkpoint for a single module.
+type JournalEntry struct {
+	// Hash is the config hash of the module when the entry was written.
+	// Entries whose hash no longer matches the config are discarded.
+	Hash         string `json:"hash"`
+	CheckPassed  bool   `json:"check_passed,omitempty"`
+	Installed    bool   `json:"installed,omitempty"`
+	CommandsDone int    `json:"commands_done,omitempty"`
+	// Env and Unset are the environment changes made by the completed
+	// commands, restored when a resumed run skips them.
+	Env   map[string]string `json:"env,omitempty"`
+	Unset []string          `json:"unset,omitempty"`
+}
+
+// JournalPath returns the location of the run journal.
+func JournalPath() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "journal.json"), nil
+}
+
+// NewJournal starts a fresh journal for the given plan at path.
+func NewJournal(path, configPath string, modules []string) *Journal {
+	now := time.Now()
+	return &Journal{
+		ConfigPath: configPath,
+		Modules:    modules,
+		StartedAt:  now,
+		UpdatedAt:  now,
+		Entries:    make(map[string]*JournalEntry),
+		path:       path,
+	}
+}
+
+// LoadJournal reads the journal at path. It returns an error wrapping
+// os.ErrNotExist if no run has been recorded yet.
+func LoadJournal(path string) (*Journal, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var j Journal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, err
+	}
+	if j.Entries == nil {
+		j.Entries = make(map[string]*JournalEntry)
+	}
+	j.path = path
+	return &j, nil
+}
+
+// Entry returns the checkpoint for a module, resetting it if the module's
+// config hash has changed since it was recorded.
+func (j *Journal) Entry(module, hash string) *JournalEntry {
+	entry, ok := j.Entries[module]
+	if !ok || entry.Hash != hash {
+		entry = &JournalEntry{Hash: hash}
+		j.Entries[module] = entry
+	}
+	return entry
+}
+
+// Save writes the journal back to disk.
+func (j *Journal) Save() error {
+	if j.path == "" {
+		return errors.New("journal has no path")
+	}
+	j.UpdatedAt = time.Now()
+	return writeJSON(j.path, j)
+}
@@ -0,0 +1,32 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestJournal_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.json")
+	j := NewJournal(path, "/tmp/config.yaml", []string{"zsh", "fzf"})
+	j.Entry("zsh", "abc").Installed = true
+	if err := j.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded, err := LoadJournal(path)
+	if err != nil {
+		t.Fatalf("LoadJournal: %v", err)
+	}
+	if !loaded.Entry("zsh", "abc").Installed {
+		t.Fatalf("entry for zsh lost its Installed flag")
+	}
+}
+
+func TestJournal_EntryResetsOnHashChange(t *testing.T) {
+	j := NewJournal(filepath.Join(t.TempDir(), "journal.json"), "config.yaml", nil)
+	j.Entry("go", "old").CommandsDone = 2
+
+	if got := j.Entry("go", "new"); got.CommandsDone != 0 || got.Hash != "new" {
+		t.Fatalf("got %+v, want a fresh entry for hash %q", got, "new")
+	}
+}
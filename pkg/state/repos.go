@@ -0,0 +1,70 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Repos records activity on dotfiles repositories, keyed by the path of
+// the bare repository.
+type Repos struct {
+	Repos map[string]*RepoState `json:"repos"`
+
+	path string
+}
+
+// RepoState describes one dotfiles repository.
+type RepoState struct {
+	// LastSync is when `dotm repo sync` last completed.
+	LastSync time.Time `json:"last_sync"`
+}
+
+// ReposPath returns the location of the repository record.
+func ReposPath() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "repos.json"), nil
+}
+
+// LoadRepos reads the record at path. A missing file yields an empty
+// record.
+func LoadRepos(path string) (*Repos, error) {
+	r := &Repos{Repos: make(map[string]*RepoState), path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, err
+	}
+	if r.Repos == nil {
+		r.Repos = make(map[string]*RepoState)
+	}
+	return r, nil
+}
+
+// Repo returns the state of the repository at gitDir, creating it if
+// needed.
+func (r *Repos) Repo(gitDir string) *RepoState {
+	st, ok := r.Repos[gitDir]
+	if !ok {
+		st = &RepoState{}
+		r.Repos[gitDir] = st
+	}
+	return st
+}
+
+// Save writes the record back to disk.
+func (r *Repos) Save() error {
+	if r.path == "" {
+		return errors.New("repo state has no path")
+	}
+	return writeJSON(r.path, r)
+}
@@ -0,0 +1,102 @@
+package toolchain
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/w31r4/dotm/pkg/executor"
+)
+
+// Cargo installs crates with `cargo install`.
+type Cargo struct{}
+
+func (c *Cargo) Name() string { return "cargo" }
+
+func (c *Cargo) Toolchain() string { return "rust" }
+
+func (c *Cargo) cargoBinary() (string, error) {
+	return binary("cargo", "~/.cargo/bin")
+}
+
+func (c *Cargo) Version(pkg string) (string, error) {
+	bin, err := c.cargoBinary()
+	if err != nil {
+		return "", nil
+	}
+	out, err := query(bin, "install", "--list")
+	if err != nil {
+		return "", err
+	}
+	return parseCargoList(out)[pkg], nil
+}
+
+func (c *Cargo) Latest(pkg string) (string, error) {
+	bin, err := c.cargoBinary()
+	if err != nil {
+		return "", err
+	}
+	out, err := query(bin, "search", "--limit", "1", pkg)
+	if err != nil {
+		return "", err
+	}
+	return parseCargoSearch(out, pkg), nil
+}
+
+// Install uses --locked so crates build with the dependency versions they
+// were released with.
+func (c *Cargo) Install(s *executor.Session, pkg, version string) error {
+	bin, err := c.cargoBinary()
+	if err != nil && !s.DryRun {
+		return err
+	}
+	args := []string{"install", "--locked", pkg}
+	if version != "" {
+		args = append(args, "--version", strings.TrimPrefix(version, "v"))
+	}
+	return run(s, bin, args...)
+}
+
+// Upgrade reinstalls the crate; cargo only rebuilds it when a newer
+// version is available.
+func (c *Cargo) Upgrade(s *executor.Session, pkg string) error {
+	return c.Install(s, pkg, "")
+}
+
+func (c *Cargo) Uninstall(s *executor.Session, pkg string) error {
+	bin, err := c.cargoBinary()
+	if err != nil && !s.DryRun {
+		return err
+	}
+	return run(s, bin, "uninstall", pkg)
+}
+
+// parseCargoList maps crates to versions from `cargo install --list`:
+//
+//	ripgrep v14.1.1:
+//	    rg
+func parseCargoList(out string) map[string]string {
+	installed := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		if line == "" || line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		fields := strings.Fields(strings.TrimSuffix(line, ":"))
+		if len(fields) >= 2 {
+			installed[fields[0]] = strings.TrimSuffix(strings.TrimPrefix(fields[1], "v"), ":")
+		}
+	}
+	return installed
+}
+
+var cargoSearchPattern = regexp.MustCompile(`^(\S+) = "([^"]+)"`)
+
+// parseCargoSearch finds pkg in `cargo search` output, which lists one
+// crate per line as: ripgrep = "14.1.1"    # description
+func parseCargoSearch(out, pkg string) string {
+	for _, line := range strings.Split(out, "\n") {
+		if m := cargoSearchPattern.FindStringSubmatch(line); m != nil && m[1] == pkg {
+			return m[2]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,165 @@
+package toolchain
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/w31r4/dotm/pkg/executor"
+)
+
+// Go installs tools with `go install`. Packages are import paths such as
+// golang.org/x/tools/gopls.
+type Go struct{}
+
+func (g *Go) Name() string { return "go" }
+
+func (g *Go) Toolchain() string { return "go" }
+
+func (g *Go) goBinary() (string, error) {
+	return binary("go", "/usr/local/go/bin", "~/go/bin", "~/.local/go/bin")
+}
+
+// Version reads the module version embedded in the installed binary.
+func (g *Go) Version(pkg string) (string, error) {
+	_, version, err := g.installedModule(pkg)
+	return version, err
+}
+
+// Latest asks the module proxy for the newest version of the module that
+// provides pkg.
+func (g *Go) Latest(pkg string) (string, error) {
+	bin, err := g.goBinary()
+	if err != nil {
+		return "", err
+	}
+	mod, _, err := g.installedModule(pkg)
+	if err != nil {
+		return "", err
+	}
+	if mod == "" {
+		mod = pkg
+	}
+	out, err := queryOutsideModule(bin, "list", "-m", "-f", "{{.Version}}", mod+"@latest")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func (g *Go) Install(s *executor.Session, pkg, version string) error {
+	if version == "" {
+		version = "latest"
+	}
+	bin, err := g.goBinary()
+	if err != nil && !s.DryRun {
+		return err
+	}
+	return run(s, bin, "install", pkg+"@"+version)
+}
+
+func (g *Go) Upgrade(s *executor.Session, pkg string) error {
+	return g.Install(s, pkg, "latest")
+}
+
+// Uninstall removes the installed binary; Go keeps no other record of it.
+func (g *Go) Uninstall(s *executor.Session, pkg string) error {
+	path, err := g.binaryPath(pkg)
+	if err != nil {
+		return err
+	}
+	return run(s, "rm", "-f", path)
+}
+
+// installedModule returns the module path and version recorded in the
+// installed binary for pkg, or empty strings if it is not installed.
+func (g *Go) installedModule(pkg string) (mod, version string, err error) {
+	path, err := g.binaryPath(pkg)
+	if err != nil {
+		return "", "", nil
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", "", nil
+	}
+	bin, err := g.goBinary()
+	if err != nil {
+		return "", "", nil
+	}
+	out, err := query(bin, "version", "-m", path)
+	if err != nil {
+		return "", "", err
+	}
+	mod, version = parseBuildInfo(out)
+	return mod, version, nil
+}
+
+// binaryPath is where `go install` puts the binary for pkg: $GOBIN, or
+// the bin directory of the first GOPATH entry.
+func (g *Go) binaryPath(pkg string) (string, error) {
+	bin, err := g.goBinary()
+	if err != nil {
+		return "", err
+	}
+	out, err := query(bin, "env", "GOBIN", "GOPATH")
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	dir := ""
+	if len(lines) > 0 {
+		dir = strings.TrimSpace(lines[0])
+	}
+	if dir == "" && len(lines) > 1 {
+		gopath := filepath.SplitList(strings.TrimSpace(lines[1]))
+		if len(gopath) > 0 {
+			dir = filepath.Join(gopath[0], "bin")
+		}
+	}
+	return filepath.Join(dir, binaryName(pkg)), nil
+}
+
+var majorVersionPattern = regexp.MustCompile(`^v[0-9]+$`)
+
+// binaryName is the name `go install` gives the binary for pkg: the last
+// element of the import path, skipping a major version suffix such as /v2.
+func binaryName(pkg string) string {
+	pkg, _, _ = strings.Cut(pkg, "@")
+	name := path.Base(pkg)
+	if majorVersionPattern.MatchString(name) {
+		if parent := path.Base(path.Dir(pkg)); parent != "." && parent != "/" {
+			name = parent
+		}
+	}
+	return name
+}
+
+// parseBuildInfo extracts the main module from `go version -m` output:
+//
+//	/home/me/go/bin/gopls: go1.25.3
+//		path	golang.org/x/tools/gopls
+//		mod	golang.org/x/tools/gopls	v0.20.0	h1:...
+func parseBuildInfo(out string) (mod, version string) {
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && fields[0] == "mod" {
+			return fields[1], fields[2]
+		}
+	}
+	return "", ""
+}
+
+// queryOutsideModule runs a go command from a directory outside of any
+// module, so the current directory's go.mod does not interfere.
+func queryOutsideModule(bin string, args ...string) (string, error) {
+	cmd := exec.Command(bin, args...)
+	cmd.Dir = os.TempDir()
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("go %s: %w", strings.Join(args, " "), err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,87 @@
+package toolchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/w31r4/dotm/pkg/executor"
+)
+
+// Npm installs global packages with `npm install -g`.
+type Npm struct{}
+
+func (n *Npm) Name() string { return "npm" }
+
+func (n *Npm) Toolchain() string { return "node" }
+
+func (n *Npm) npmBinary() (string, error) {
+	return binary("npm", "~/.local/bin", "/usr/local/bin")
+}
+
+func (n *Npm) Version(pkg string) (string, error) {
+	bin, err := n.npmBinary()
+	if err != nil {
+		return "", nil
+	}
+	// npm exits with 1 when the package is missing, but still prints JSON.
+	out, err := exec.Command(bin, "ls", "--global", "--depth=0", "--json", pkg).Output()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return "", fmt.Errorf("npm ls: %w", err)
+	}
+	return parseNpmList(out, pkg)
+}
+
+func (n *Npm) Latest(pkg string) (string, error) {
+	bin, err := n.npmBinary()
+	if err != nil {
+		return "", err
+	}
+	out, err := query(bin, "view", pkg, "version")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func (n *Npm) Install(s *executor.Session, pkg, version string) error {
+	bin, err := n.npmBinary()
+	if err != nil && !s.DryRun {
+		return err
+	}
+	if version != "" {
+		pkg += "@" + strings.TrimPrefix(version, "v")
+	}
+	return run(s, bin, "install", "--global", pkg)
+}
+
+func (n *Npm) Upgrade(s *executor.Session, pkg string) error {
+	return n.Install(s, pkg, "latest")
+}
+
+func (n *Npm) Uninstall(s *executor.Session, pkg string) error {
+	bin, err := n.npmBinary()
+	if err != nil && !s.DryRun {
+		return err
+	}
+	return run(s, bin, "uninstall", "--global", pkg)
+}
+
+// parseNpmList reads the version of pkg from `npm ls --json` output.
+func parseNpmList(out []byte, pkg string) (string, error) {
+	if len(strings.TrimSpace(string(out))) == 0 {
+		return "", nil
+	}
+	var list struct {
+		Dependencies map[string]struct {
+			Version string `json:"version"`
+		} `json:"dependencies"`
+	}
+	if err := json.Unmarshal(out, &list); err != nil {
+		return "", fmt.Errorf("parse npm ls output: %w", err)
+	}
+	return list.Dependencies[pkg].Version, nil
+}
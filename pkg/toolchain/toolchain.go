@@ -0,0 +1,108 @@
+// Package toolchain installs command-line tools with language package
+// managers. It backs the `go:`, `cargo:`, `uv_tool:` and `npm:` install
+// types.
+package toolchain
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/w31r4/dotm/pkg/executor"
+)
+
+// Backend installs tools with one language package manager.
+type Backend interface {
+	// Name is the config key of the install type, e.g. "uv_tool".
+	Name() string
+	// Toolchain is the module that provides the package manager, e.g. "uv".
+	Toolchain() string
+	// Version returns the installed version of pkg, or "" if it is not
+	// installed (or the toolchain itself is missing).
+	Version(pkg string) (string, error)
+	// Latest asks the package registry for the newest version of pkg.
+	Latest(pkg string) (string, error)
+	// Install installs pkg at version, or the latest version if version is
+	// empty. An installed tool is replaced.
+	Install(s *executor.Session, pkg, version string) error
+	// Upgrade upgrades an installed tool to its latest version.
+	Upgrade(s *executor.Session, pkg string) error
+	// Uninstall removes an installed tool.
+	Uninstall(s *executor.Session, pkg string) error
+}
+
+// Names lists the supported backends.
+var Names = []string{"go", "cargo", "uv_tool", "npm"}
+
+// New returns a backend by name.
+func New(name string) (Backend, error) {
+	switch name {
+	case "go":
+		return &Go{}, nil
+	case "cargo":
+		return &Cargo{}, nil
+	case "uv_tool":
+		return &UvTool{}, nil
+	case "npm":
+		return &Npm{}, nil
+	default:
+		return nil, fmt.Errorf("unknown toolchain %q (supported: %s)", name, strings.Join(Names, ", "))
+	}
+}
+
+// ErrNoToolchain is returned when the package manager is not installed.
+var ErrNoToolchain = errors.New("toolchain is not installed")
+
+// VersionMatches reports whether an installed version satisfies a pin. An
+// empty pin matches any version; a leading "v" is ignored on both sides.
+func VersionMatches(installed, pinned string) bool {
+	if pinned == "" {
+		return true
+	}
+	return strings.TrimPrefix(installed, "v") == strings.TrimPrefix(pinned, "v")
+}
+
+// binary locates a toolchain executable on PATH, falling back to the
+// places its installer puts it. A toolchain installed earlier in the same
+// run is usually not on PATH yet. When it is missing, the bare name is
+// returned with the error so dry runs can still show the command.
+func binary(name string, fallbacks ...string) (string, error) {
+	if path, err := exec.LookPath(name); err == nil {
+		return path, nil
+	}
+	home, _ := os.UserHomeDir()
+	for _, dir := range fallbacks {
+		if rest, ok := strings.CutPrefix(dir, "~/"); ok {
+			if home == "" {
+				continue
+			}
+			dir = filepath.Join(home, rest)
+		}
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() && info.Mode()&0111 != 0 {
+			return path, nil
+		}
+	}
+	return name, fmt.Errorf("%s: %w", name, ErrNoToolchain)
+}
+
+// query runs a read-only command and returns its stdout.
+func query(bin string, args ...string) (string, error) {
+	out, err := exec.Command(bin, args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("%s %s: %w", filepath.Base(bin), strings.Join(args, " "), err)
+	}
+	return string(out), nil
+}
+
+// run runs a package manager command in the session.
+func run(s *executor.Session, bin string, args ...string) error {
+	command := executor.ShellQuote(bin)
+	for _, arg := range args {
+		command += " " + executor.ShellQuote(arg)
+	}
+	return s.Run(command)
+}
@@ -0,0 +1,143 @@
+package toolchain
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/w31r4/dotm/pkg/executor"
+)
+
+// fakeBin installs an executable shell script on PATH for the test.
+func fakeBin(t *testing.T, dir, name, script string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatalf("write fake %s: %v", name, err)
+	}
+}
+
+func TestBinaryName(t *testing.T) {
+	tests := map[string]string{
+		"golang.org/x/tools/gopls":                  "gopls",
+		"github.com/go-delve/delve/cmd/dlv@v1.23.0": "dlv",
+		"github.com/foo/bar/v2":                     "bar",
+		"mvdan.cc/gofumpt":                          "gofumpt",
+	}
+	for pkg, want := range tests {
+		if got := binaryName(pkg); got != want {
+			t.Errorf("binaryName(%q) = %q, want %q", pkg, got, want)
+		}
+	}
+}
+
+func TestParseBuildInfo(t *testing.T) {
+	out := "/home/me/go/bin/gopls: go1.25.3\n\tpath\tgolang.org/x/tools/gopls\n\tmod\tgolang.org/x/tools/gopls\tv0.20.0\th1:abc=\n\tdep\tgolang.org/x/mod\tv0.27.0\th1:def=\n"
+	mod, version := parseBuildInfo(out)
+	if mod != "golang.org/x/tools/gopls" || version != "v0.20.0" {
+		t.Fatalf("parseBuildInfo = %q, %q", mod, version)
+	}
+}
+
+func TestParseCargo(t *testing.T) {
+	list := parseCargoList("ripgrep v14.1.1:\n    rg\nlocal-tool v0.1.0 (/src/local-tool):\n    local-tool\n")
+	if list["ripgrep"] != "14.1.1" || list["local-tool"] != "0.1.0" {
+		t.Fatalf("parseCargoList = %v", list)
+	}
+	search := "ripgrep_all = \"0.10.6\"    # rga: ripgrep, but also search in PDFs\nripgrep = \"14.1.1\"    # fast grep\n"
+	if got := parseCargoSearch(search, "ripgrep"); got != "14.1.1" {
+		t.Fatalf("parseCargoSearch = %q", got)
+	}
+}
+
+func TestParseUvToolList(t *testing.T) {
+	list := parseUvToolList("ruff v0.6.9\n- ruff\nPre_Commit v4.0.1\n- pre-commit\n")
+	if list["ruff"] != "0.6.9" || list["pre-commit"] != "4.0.1" {
+		t.Fatalf("parseUvToolList = %v", list)
+	}
+}
+
+func TestParseNpmList(t *testing.T) {
+	v, err := parseNpmList([]byte(`{"name":"lib","dependencies":{"@biomejs/biome":{"version":"1.9.4","overridden":false}}}`), "@biomejs/biome")
+	if err != nil || v != "1.9.4" {
+		t.Fatalf("parseNpmList = %q, %v", v, err)
+	}
+	if v, err := parseNpmList([]byte(`{"name":"lib"}`), "prettier"); err != nil || v != "" {
+		t.Fatalf("parseNpmList of a missing package = %q, %v", v, err)
+	}
+}
+
+func TestUvTool_Latest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ruff/json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"info":{"version":"0.7.0"}}`))
+	}))
+	defer srv.Close()
+	old := PyPIURL
+	PyPIURL = srv.URL
+	defer func() { PyPIURL = old }()
+
+	u := &UvTool{}
+	if v, err := u.Latest("ruff"); err != nil || v != "0.7.0" {
+		t.Fatalf("Latest = %q, %v", v, err)
+	}
+	if _, err := u.Latest("missing"); err == nil {
+		t.Fatal("Latest of an unknown package should fail")
+	}
+}
+
+func TestCargo_InstallAndVersion(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "calls.log")
+	fakeBin(t, dir, "cargo", `echo "cargo $*" >> "`+logPath+`"
+if [ "$1 $2" = "install --list" ]; then
+  printf 'ripgrep v14.1.1:\n    rg\n'
+fi
+`)
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	c := &Cargo{}
+	if v, err := c.Version("ripgrep"); err != nil || v != "14.1.1" {
+		t.Fatalf("Version(ripgrep) = %q, %v", v, err)
+	}
+	if v, err := c.Version("fd-find"); err != nil || v != "" {
+		t.Fatalf("Version(fd-find) = %q, %v", v, err)
+	}
+
+	s := &executor.Session{}
+	if err := c.Install(s, "fd-find", "v10.2.0"); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if err := c.Uninstall(s, "ripgrep"); err != nil {
+		t.Fatalf("Uninstall: %v", err)
+	}
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"cargo install --locked fd-find --version 10.2.0", "cargo uninstall ripgrep"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("calls do not include %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestMissingToolchain(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	for _, name := range Names {
+		b, err := New(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, err := b.Version("anything"); err != nil || v != "" {
+			t.Errorf("%s: Version without the toolchain = %q, %v; want not installed", name, v, err)
+		}
+	}
+}
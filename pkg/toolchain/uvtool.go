@@ -0,0 +1,111 @@
+package toolchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/w31r4/dotm/pkg/executor"
+)
+
+// PyPIURL is the JSON API used to look up the latest version of a Python
+// package.
+var PyPIURL = "https://pypi.org/pypi"
+
+// UvTool installs Python command-line tools with `uv tool`, each in its
+// own virtual environment.
+type UvTool struct{}
+
+func (u *UvTool) Name() string { return "uv_tool" }
+
+func (u *UvTool) Toolchain() string { return "uv" }
+
+func (u *UvTool) uvBinary() (string, error) {
+	return binary("uv", "~/.local/bin", "~/.cargo/bin")
+}
+
+func (u *UvTool) Version(pkg string) (string, error) {
+	bin, err := u.uvBinary()
+	if err != nil {
+		return "", nil
+	}
+	out, err := query(bin, "tool", "list")
+	if err != nil {
+		return "", err
+	}
+	return parseUvToolList(out)[normalizePythonName(pkg)], nil
+}
+
+func (u *UvTool) Latest(pkg string) (string, error) {
+	resp, err := http.Get(PyPIURL + "/" + url.PathEscape(pkg) + "/json")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("look up %s on PyPI: %s", pkg, resp.Status)
+	}
+	var info struct {
+		Info struct {
+			Version string `json:"version"`
+		} `json:"info"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return "", fmt.Errorf("look up %s on PyPI: %w", pkg, err)
+	}
+	return info.Info.Version, nil
+}
+
+// Install installs the tool; with a version, an installed tool is
+// replaced by that version.
+func (u *UvTool) Install(s *executor.Session, pkg, version string) error {
+	bin, err := u.uvBinary()
+	if err != nil && !s.DryRun {
+		return err
+	}
+	if version == "" {
+		return run(s, bin, "tool", "install", pkg)
+	}
+	return run(s, bin, "tool", "install", pkg+"=="+strings.TrimPrefix(version, "v"))
+}
+
+func (u *UvTool) Upgrade(s *executor.Session, pkg string) error {
+	bin, err := u.uvBinary()
+	if err != nil && !s.DryRun {
+		return err
+	}
+	return run(s, bin, "tool", "upgrade", pkg)
+}
+
+func (u *UvTool) Uninstall(s *executor.Session, pkg string) error {
+	bin, err := u.uvBinary()
+	if err != nil && !s.DryRun {
+		return err
+	}
+	return run(s, bin, "tool", "uninstall", pkg)
+}
+
+// parseUvToolList maps tools to versions from `uv tool list`:
+//
+//	ruff v0.6.9
+//	- ruff
+func parseUvToolList(out string) map[string]string {
+	installed := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] == "-" {
+			continue
+		}
+		installed[normalizePythonName(fields[0])] = strings.TrimPrefix(fields[1], "v")
+	}
+	return installed
+}
+
+// normalizePythonName applies PEP 503 normalization, so "Black" and
+// "black" name the same package.
+func normalizePythonName(name string) string {
+	name = strings.ToLower(name)
+	return strings.NewReplacer("_", "-", ".", "-").Replace(name)
+}
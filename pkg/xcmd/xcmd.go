@@ -0,0 +1,127 @@
+// Package xcmd drives x-cmd (https://x-cmd.com), the package manager used
+// by the `xcmd:` install type.
+package xcmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/w31r4/dotm/pkg/executor"
+)
+
+// ProviderModule is the name of the module expected to install x-cmd
+// itself, usually with the script: install type. Modules that use xcmd:
+// depend on it when it exists.
+const ProviderModule = "x-cmd"
+
+// ErrNotBootstrapped is returned when x-cmd is not installed yet.
+var ErrNotBootstrapped = errors.New("x-cmd is not installed")
+
+// Client runs x-cmd commands.
+type Client struct {
+	// Root is the x-cmd root directory (default ~/.x-cmd.root).
+	Root string
+}
+
+// New returns a client for the default x-cmd root.
+func New() *Client {
+	root := os.Getenv("X_CMD_ROOT")
+	if root == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			root = filepath.Join(home, ".x-cmd.root")
+		}
+	}
+	return &Client{Root: root}
+}
+
+// Binary locates the x executable: on PATH first, then inside the root.
+func (c *Client) Binary() (string, error) {
+	if path, err := exec.LookPath("x"); err == nil {
+		return path, nil
+	}
+	if c.Root != "" {
+		path := filepath.Join(c.Root, "bin", "x")
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+	}
+	return "", ErrNotBootstrapped
+}
+
+// Installed lists the packages installed through `x env`, mapped to their
+// version. Lines of `x env ls` are expected in the form "name version",
+// "name=version" or "name@version".
+func (c *Client) Installed() (map[string]string, error) {
+	bin, err := c.Binary()
+	if err != nil {
+		return nil, err
+	}
+	out, err := exec.Command(bin, "env", "ls").Output()
+	if err != nil {
+		return nil, fmt.Errorf("x env ls: %w", err)
+	}
+	return parseList(string(out)), nil
+}
+
+// Version returns the installed version of pkg, or "" if it is missing.
+func (c *Client) Version(pkg string) (string, error) {
+	installed, err := c.Installed()
+	if err != nil {
+		return "", err
+	}
+	return installed[pkg], nil
+}
+
+// Use installs pkg (pinned to version, if given) and makes it the active
+// version.
+func (c *Client) Use(s *executor.Session, pkg, version string) error {
+	bin, err := c.Binary()
+	if err != nil && !s.DryRun {
+		return err
+	}
+	if bin == "" {
+		bin = "x"
+	}
+	return s.Run(executor.ShellQuote(bin) + " env use " + executor.ShellQuote(Spec(pkg, version)))
+}
+
+// Spec renders a package reference for `x env use`.
+func Spec(pkg, version string) string {
+	if version == "" {
+		return pkg
+	}
+	return pkg + "=" + version
+}
+
+// VersionMatches reports whether an installed version satisfies a pin.
+// An empty pin matches any version; a leading "v" is ignored.
+func VersionMatches(installed, pinned string) bool {
+	if pinned == "" {
+		return true
+	}
+	return strings.TrimPrefix(installed, "v") == strings.TrimPrefix(pinned, "v")
+}
+
+func parseList(out string) map[string]string {
+	installed := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.FieldsFunc(strings.TrimSpace(scanner.Text()), func(r rune) bool {
+			return r == ' ' || r == '\t' || r == '=' || r == '@'
+		})
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		version := ""
+		if len(fields) > 1 {
+			version = fields[1]
+		}
+		installed[fields[0]] = version
+	}
+	return installed
+}
@@ -0,0 +1,90 @@
+package xcmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/w31r4/dotm/pkg/executor"
+)
+
+// fakeX puts an `x` executable on PATH that keeps its installed packages
+// in a text file, one "name version" per line.
+func fakeX(t *testing.T) (dbPath string) {
+	t.Helper()
+	dir := t.TempDir()
+	dbPath = filepath.Join(dir, "installed")
+	if err := os.WriteFile(dbPath, []byte("# installed packages\nfzf 0.46.1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	script := `#!/bin/sh
+db="` + dbPath + `"
+case "$1 $2" in
+  "env ls") cat "$db" ;;
+  "env use")
+    name="${3%%=*}"; version="${3#*=}"
+    [ "$version" = "$3" ] && version="latest"
+    grep -v "^$name " "$db" > "$db.tmp"; mv "$db.tmp" "$db"
+    echo "$name $version" >> "$db" ;;
+  *) exit 2 ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "x"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return dbPath
+}
+
+func TestClient_Installed(t *testing.T) {
+	fakeX(t)
+	c := &Client{}
+
+	got, err := c.Installed()
+	if err != nil {
+		t.Fatalf("Installed: %v", err)
+	}
+	if len(got) != 1 || got["fzf"] != "0.46.1" {
+		t.Fatalf("got %v, want map[fzf:0.46.1]", got)
+	}
+}
+
+func TestClient_UsePinsVersion(t *testing.T) {
+	fakeX(t)
+	c := &Client{}
+
+	if err := c.Use(&executor.Session{}, "node", "v20.11.0"); err != nil {
+		t.Fatalf("Use: %v", err)
+	}
+	v, err := c.Version("node")
+	if err != nil {
+		t.Fatalf("Version: %v", err)
+	}
+	if v != "v20.11.0" {
+		t.Fatalf("got version %q, want %q", v, "v20.11.0")
+	}
+}
+
+func TestClient_NotBootstrapped(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	c := &Client{Root: t.TempDir()}
+	if _, err := c.Installed(); err != ErrNotBootstrapped {
+		t.Fatalf("got err=%v, want ErrNotBootstrapped", err)
+	}
+}
+
+func TestVersionMatches(t *testing.T) {
+	tests := []struct {
+		installed, pinned string
+		want              bool
+	}{
+		{"0.46.1", "", true},
+		{"v0.46.1", "0.46.1", true},
+		{"0.45.0", "0.46.1", false},
+	}
+	for _, tt := range tests {
+		if got := VersionMatches(tt.installed, tt.pinned); got != tt.want {
+			t.Errorf("VersionMatches(%q, %q) = %v, want %v", tt.installed, tt.pinned, got, tt.want)
+		}
+	}
+}